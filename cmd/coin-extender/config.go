@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/noah-blockchain/noah-extender/internal/env"
+)
+
+type configCommand struct {
+	action string
+}
+
+// Action follows the command: config print
+func newConfigCommand() *configCommand {
+	return &configCommand{
+		action: parseCommand(),
+	}
+}
+
+func (c *configCommand) run(envData *env.Environment) error {
+	if c.action != "print" {
+		return fmt.Errorf("unknown config action %q, expected print", c.action)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SETTING\tVALUE\tSOURCE")
+	for _, setting := range envData.Settings {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", setting.Name, setting.Redacted(), setting.Source)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return envData.Validate()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/noah-blockchain/noah-extender/internal/core"
+	"github.com/noah-blockchain/noah-extender/internal/dlq"
+	"github.com/noah-blockchain/noah-extender/internal/env"
+	"github.com/noah-blockchain/noah-extender/internal/failure"
+)
+
+type dlqCommand struct {
+	action string
+	stage  *string
+}
+
+// Action follows the command: dlq list|replay|purge
+func newDlqCommand() *dlqCommand {
+	return &dlqCommand{
+		action: parseCommand(),
+		stage:  flag.String("stage", "", "Stage of dead-letter jobs, all stages if empty"),
+	}
+}
+
+// Dead-letter store is kept in badger DB, so the extender has to be stopped first
+func (c *dlqCommand) run(envData *env.Environment) error {
+	switch c.action {
+	case "list":
+		return c.list()
+	case "purge":
+		return c.purge()
+	case "replay":
+		return c.replay(envData)
+	}
+	return fmt.Errorf("unknown dlq action %q, expected list, replay or purge", c.action)
+}
+
+func (c *dlqCommand) list() error {
+	dbBadger, err := openBadger()
+	if err != nil {
+		return err
+	}
+	defer dbBadger.Close()
+
+	entries, err := dlq.NewStore(dbBadger).List(failure.Stage(*c.stage))
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STAGE\tHEIGHT\tCREATED AT\tERROR")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", entry.Stage, entry.Height, entry.CreatedAt.Format(time.RFC3339), entry.Error)
+	}
+	return w.Flush()
+}
+
+func (c *dlqCommand) purge() error {
+	dbBadger, err := openBadger()
+	if err != nil {
+		return err
+	}
+	defer dbBadger.Close()
+
+	count, err := dlq.NewStore(dbBadger).Purge(failure.Stage(*c.stage))
+	fmt.Printf("%d jobs purged\n", count)
+	return err
+}
+
+func (c *dlqCommand) replay(envData *env.Environment) error {
+	dbBadger, err := openBadger()
+	if err != nil {
+		return err
+	}
+	ns, err := connectNats(envData.ExtenderEnvironment)
+	if err != nil {
+		dbBadger.Close()
+		return err
+	}
+
+	ctx := shutdownContext()
+	ext := core.NewExtender(envData, connectDB(envData.ExtenderEnvironment), dbBadger, ns, connectNode(ctx, envData))
+	replayed, kept, err := ext.ReplayDeadLetters(ctx, failure.Stage(*c.stage))
+	if shutdownErr := ext.Shutdown(envData.ShutdownTimeout); err == nil {
+		err = shutdownErr
+	}
+	fmt.Printf("%d jobs replayed, %d jobs kept\n", replayed, kept)
+	return err
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"flag"
+
+	"github.com/noah-blockchain/noah-extender/internal/core"
+	"github.com/noah-blockchain/noah-extender/internal/env"
+)
+
+type importGenesisCommand struct {
+	file     *string
+	fromNode *bool
+}
+
+func newImportGenesisCommand() *importGenesisCommand {
+	return &importGenesisCommand{
+		file:     flag.String("file", "", "Path to genesis.json"),
+		fromNode: flag.Bool("from-node", false, "Request genesis from the node API"),
+	}
+}
+
+// Seed DB from genesis before the first run of the extender, it can be run again if it fails
+func (c *importGenesisCommand) run(envData *env.Environment) error {
+	if (*c.file == "") == !*c.fromNode {
+		return errors.New("either --file or --from-node is required")
+	}
+
+	genesis, err := c.load(envData)
+	if err != nil {
+		return err
+	}
+
+	if err = runMigrations(envData.ExtenderEnvironment); err != nil {
+		return err
+	}
+	ext := core.NewExtender(envData, connectDB(envData.ExtenderEnvironment), nil, nil, nil)
+	defer ext.Close()
+	return ext.ImportGenesis(genesis)
+}
+
+func (c *importGenesisCommand) load(envData *env.Environment) (*core.Genesis, error) {
+	if *c.file != "" {
+		return core.LoadGenesisFile(*c.file)
+	}
+	if len(envData.NodeApiLinks) == 0 {
+		return nil, errors.New("NOAH_API_NODE is not set")
+	}
+	return core.FetchGenesis(envData.NodeApiLinks[0])
+}
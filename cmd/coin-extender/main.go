@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/dgraph-io/badger"
@@ -18,7 +22,9 @@ import (
 	"github.com/noah-blockchain/noah-extender/internal/api"
 	"github.com/noah-blockchain/noah-extender/internal/core"
 	"github.com/noah-blockchain/noah-extender/internal/env"
+	"github.com/noah-blockchain/noah-extender/internal/node"
 	noah_node_go_api "github.com/noah-blockchain/noah-node-go-api"
+	"github.com/sirupsen/logrus"
 )
 
 const (
@@ -29,28 +35,141 @@ const (
 )
 
 func main() {
-	envData := env.New()
+	command := parseCommand()
 
-	if err := runMigrations(envData); err != nil {
+	var run func(envData *env.Environment) error
+	switch command {
+	case "":
+		run = runExtender
+	case "reindex":
+		run = newReindexCommand().run
+	case "dlq":
+		run = newDlqCommand().run
+	case "import-genesis":
+		run = newImportGenesisCommand().run
+	case "verify":
+		run = newVerifyCommand().run
+	case "config":
+		run = newConfigCommand().run
+	default:
+		log.Fatalf("Unknown command %q", command)
+	}
+
+	envData, err := env.New()
+	// effective settings are printed even if they are invalid
+	if command == "config" {
+		if envData != nil {
+			printErr := run(envData)
+			if err == nil {
+				err = printErr
+			} else if printErr != nil {
+				log.Println(printErr)
+			}
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err = envData.Validate(); err != nil {
+		log.Fatalln(err)
+	}
+	if err = run(envData); err != nil {
 		log.Panicln(err)
 	}
+}
+
+func runExtender(envData *env.Environment) error {
+	if err := runMigrations(envData.ExtenderEnvironment); err != nil {
+		return err
+	}
 
-	extenderApi := api.New(envData.ApiHost, envData.ApiPort)
+	extenderApi := api.New(envData.ApiHost, envData.ApiPort, envData.ReadyMaxLag)
+	err := extenderApi.EnableAdmin(api.AdminConfig{
+		Token:         envData.AdminToken,
+		CertFile:      envData.ApiTLSCert,
+		KeyFile:       envData.ApiTLSKey,
+		ClientCAFile:  envData.ApiClientCA,
+		AllowInsecure: envData.AdminInsecure,
+		AuditLog:      envData.AdminAuditLog,
+	})
+	if err != nil {
+		return err
+	}
 	go extenderApi.Run()
 
-	db, dbBadger, ns, nodeAPI, err := prepareDependencies(envData)
+	ctx := shutdownContext()
+	db, dbBadger, ns, nodeAPI, err := prepareDependencies(ctx, envData)
 	if err != nil {
-		log.Panicln(err)
+		return err
 	}
 
 	ext := core.NewExtender(envData, db, dbBadger, ns, nodeAPI)
-	defer ext.Close()
+	extenderApi.SetExtender(ext)
+	extenderApi.SetAdmin(ext)
+	err = ext.Run(ctx)
+	// admin endpoints send jobs to the workers, so they are stopped first
+	if apiErr := extenderApi.Shutdown(envData.ShutdownTimeout); apiErr != nil {
+		log.Println(apiErr)
+	}
+	if shutdownErr := ext.Shutdown(envData.ShutdownTimeout); err == nil {
+		err = shutdownErr
+	}
+	return err
+}
+
+// Return context which is cancelled on SIGINT or SIGTERM
+func shutdownContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-signals
+		log.Printf("Received %s, shutting down", sig)
+		cancel()
+	}()
+	return ctx
+}
+
+// Take command from the first argument if it is not a flag, flags of the command follow it
+func parseCommand() string {
+	if len(os.Args) < 2 || strings.HasPrefix(os.Args[1], "-") {
+		return ""
+	}
+	command := os.Args[1]
+	os.Args = append(os.Args[:1], os.Args[2:]...)
+	return command
+}
+
+func prepareDependencies(ctx context.Context, envData *env.Environment) (*pg.DB, *badger.DB, stan.Conn, node.Client, error) {
+	db := connectDB(envData.ExtenderEnvironment)
+
+	dbBadger, err := openBadger()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	ns, err := connectNats(envData.ExtenderEnvironment)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	return db, dbBadger, ns, connectNode(ctx, envData), nil
+}
 
-	ext.Run()
+// Badger DB can be opened by a single process only
+func openBadger() (*badger.DB, error) {
+	if err := os.MkdirAll(badgerFolder, 0774); err != nil {
+		return nil, err
+	}
+	return badger.Open(badger.DefaultOptions(badgerFolder))
 }
 
-func prepareDependencies(env *models.ExtenderEnvironment) (*pg.DB, *badger.DB, stan.Conn, *noah_node_go_api.NoahNodeApi, error) {
-	db := pg.Connect(&pg.Options{
+func connectDB(env *models.ExtenderEnvironment) *pg.DB {
+	return pg.Connect(&pg.Options{
 		Addr:            fmt.Sprintf("%s:%d", env.DbHost, env.DbPort),
 		User:            env.DbUser,
 		Password:        env.DbPassword,
@@ -64,30 +183,51 @@ func prepareDependencies(env *models.ExtenderEnvironment) (*pg.DB, *badger.DB, s
 			return nil
 		},
 	})
+}
 
-	if err := os.MkdirAll(badgerFolder, 0774); err != nil {
-		return nil, nil, nil, nil, err
-	}
-	dbBadger, err := badger.Open(badger.DefaultOptions(badgerFolder))
-	if err != nil {
-		return nil, nil, nil, nil, err
-	}
-
-	ns, err := stan.Connect(
+func connectNats(env *models.ExtenderEnvironment) (stan.Conn, error) {
+	return stan.Connect(
 		env.NatsClusterID,
 		uuid.New().String(),
 		stan.NatsURL(env.NatsAddr),
 		stan.Pings(5, 15),
 		stan.SetConnectionLostHandler(func(con stan.Conn, reason error) {
-			log.Panicln("Connection lost, reason: %v", reason)
+			log.Panicf("Connection lost, reason: %v", reason)
 		}),
 	)
-	if err != nil {
-		return nil, nil, nil, nil, err
+}
+
+// Connect to the node and record its responses if it is requested, or replay recorded responses instead
+func connectNode(ctx context.Context, envData *env.Environment) node.Client {
+	logger := logrus.WithField("app", "Coin Explorer Extender")
+	if envData.NodeReplayDir != "" {
+		return node.NewReplay(node.NewArchive(envData.NodeReplayDir))
+	}
+	client := node.NewMetered(connectNodeApi(ctx, envData, logger))
+	if envData.NodeRecordDir != "" {
+		return node.NewRecorder(client, node.NewArchive(envData.NodeRecordDir), logger)
+	}
+	return client
+}
+
+// Connect to a single node with fallback retries or to several nodes with failover between them
+func connectNodeApi(ctx context.Context, envData *env.Environment, logger *logrus.Entry) node.Client {
+	links := envData.NodeApiLinks
+	if len(links) <= 1 {
+		if envData.NodeCrossCheck {
+			log.Println("Block hash cross-check requires at least two nodes")
+		}
+		return noah_node_go_api.NewWithFallbackRetries(envData.NodeApi, fallbackCount, fallbackTimeout)
 	}
 
-	nodeApi := noah_node_go_api.NewWithFallbackRetries(env.NodeApi, fallbackCount, fallbackTimeout)
-	return db, dbBadger, ns, nodeApi, nil
+	clients := make([]node.Client, len(links))
+	for i, link := range links {
+		clients[i] = noah_node_go_api.New(link)
+	}
+	multiClient := node.NewMultiClient(links, clients, fallbackCount, fallbackTimeout, envData.NodeCrossCheck, logger)
+	multiClient.CheckHealth()
+	go multiClient.HealthWorker(ctx, envData.NodeHealthCheckTime)
+	return multiClient
 }
 
 func runMigrations(envData *models.ExtenderEnvironment) error {
@@ -123,6 +263,8 @@ func runMigrations(envData *models.ExtenderEnvironment) error {
 		return err
 	}
 
-	_ = m.Steps(2)
+	if err = m.Up(); err != nil && err != migrate.ErrNoChange {
+		return err
+	}
 	return nil
 }
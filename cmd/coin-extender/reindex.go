@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/noah-blockchain/noah-extender/internal/core"
+	"github.com/noah-blockchain/noah-extender/internal/env"
+)
+
+type reindexCommand struct {
+	from   *uint64
+	to     *uint64
+	stages *string
+}
+
+func newReindexCommand() *reindexCommand {
+	return &reindexCommand{
+		from:   flag.Uint64("from", 0, "First height to reindex"),
+		to:     flag.Uint64("to", 0, "Last height to reindex"),
+		stages: flag.String("stages", strings.Join(core.ReindexStages, ","), "Comma separated stages to reindex"),
+	}
+}
+
+// Re-ingest heights from..to without migrations, badger and periodic workers
+// so it does not interfere with the running extender
+func (c *reindexCommand) run(envData *env.Environment) error {
+	ns, err := connectNats(envData.ExtenderEnvironment)
+	if err != nil {
+		return err
+	}
+
+	ctx := shutdownContext()
+	ext := core.NewExtender(envData, connectDB(envData.ExtenderEnvironment), nil, ns, connectNode(ctx, envData))
+	err = ext.Reindex(ctx, *c.from, *c.to, strings.Split(*c.stages, ","))
+	if shutdownErr := ext.Shutdown(envData.ShutdownTimeout); err == nil {
+		err = shutdownErr
+	}
+	return err
+}
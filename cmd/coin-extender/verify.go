@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/noah-blockchain/noah-extender/internal/core"
+	"github.com/noah-blockchain/noah-extender/internal/env"
+	"github.com/noah-blockchain/noah-extender/internal/verify"
+)
+
+type verifyCommand struct {
+	height *uint64
+	sample *int
+	repair *bool
+}
+
+func newVerifyCommand() *verifyCommand {
+	return &verifyCommand{
+		height: flag.Uint64("height", 0, "Height to compare the state at, height of the state in DB if 0"),
+		sample: flag.Int("sample", 100, "Count of random addresses and coins to compare"),
+		repair: flag.Bool("repair", false, "Update values which differ with the latest state of the node"),
+	}
+}
+
+// Compare DB with the node and print mismatches, it can run along with the extender
+func (c *verifyCommand) run(envData *env.Environment) error {
+	ns, err := connectNats(envData.ExtenderEnvironment)
+	if err != nil {
+		return err
+	}
+	defer ns.Close()
+
+	ctx := shutdownContext()
+	ext := core.NewExtender(envData, connectDB(envData.ExtenderEnvironment), nil, ns, connectNode(ctx, envData))
+	defer ext.Close()
+
+	report, err := ext.Verify(*c.height, *c.sample, *c.repair)
+	if report != nil {
+		printReport(report)
+	}
+	return err
+}
+
+func printReport(report *verify.Report) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tSUBJECT\tFIELD\tDB\tNODE")
+	for _, m := range report.Mismatches {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", m.Kind, m.Subject, m.Field, m.DB, m.Node)
+	}
+	w.Flush()
+
+	fmt.Printf("Height %d, compared values:", report.Height)
+	for _, kind := range verify.Kinds {
+		fmt.Printf(" %s %d,", kind, report.Checked[kind])
+	}
+	fmt.Printf(" mismatches %d\n", len(report.Mismatches))
+}
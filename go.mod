@@ -5,11 +5,14 @@ go 1.12
 replace mellium.im/sasl v0.2.1 => github.com/mellium/sasl v0.2.1
 
 require (
+	github.com/BurntSushi/toml v0.3.1
+	github.com/MinterTeam/go-amino v0.14.1-m
 	github.com/dgraph-io/badger v1.6.0
 	github.com/go-pg/pg v8.0.5+incompatible
 	github.com/golang-migrate/migrate/v4 v4.7.0
 	github.com/golang/protobuf v1.3.2
 	github.com/google/uuid v1.1.1
+	github.com/gorilla/websocket v1.4.1
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.1 // indirect
@@ -23,5 +26,6 @@ require (
 	github.com/pkg/errors v0.8.1
 	github.com/prometheus/client_golang v1.1.0
 	github.com/sirupsen/logrus v1.4.2
+	gopkg.in/yaml.v2 v2.2.2
 	mellium.im/sasl v0.2.1 // indirect
 )
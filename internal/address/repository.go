@@ -1,30 +1,30 @@
 package address
 
 import (
-	"sync"
-
 	"github.com/go-pg/pg"
 	"github.com/noah-blockchain/coinExplorer-tools/models"
+	"github.com/noah-blockchain/noah-extender/internal/cache"
 )
 
 type Repository struct {
 	db       *pg.DB
-	cache    *sync.Map
-	invCache *sync.Map
+	cache    *cache.LRU
+	invCache *cache.LRU
 }
 
-func NewRepository(db *pg.DB) *Repository {
+// Each cache keeps up to cacheSize addresses, 0 means caches are not bounded
+func NewRepository(db *pg.DB, cacheSize int) *Repository {
 	return &Repository{
 		db:       db,
-		cache:    new(sync.Map), //TODO: добавить реализацию очистки
-		invCache: new(sync.Map), //TODO: добавить реализацию очистки
+		cache:    cache.New("address", cacheSize),
+		invCache: cache.New("address_by_id", cacheSize),
 	}
 }
 
 //Find address id
 func (r *Repository) FindId(address string) (uint64, error) {
 	//First look in the cache
-	id, ok := r.cache.Load(address)
+	id, ok := r.cache.Get(address)
 	if ok {
 		return id.(uint64), nil
 	}
@@ -33,14 +33,14 @@ func (r *Repository) FindId(address string) (uint64, error) {
 	if err != nil {
 		return 0, err
 	}
-	r.cache.Store(address, adr.ID)
+	r.cache.Add(address, adr.ID)
 	return adr.ID, nil
 }
 
 //Find address id or create if not exist
 func (r *Repository) FindIdOrCreate(address string) (uint64, error) {
 	//First look in the cache
-	id, ok := r.cache.Load(address)
+	id, ok := r.cache.Get(address)
 	if ok {
 		return id.(uint64), nil
 	}
@@ -55,13 +55,13 @@ func (r *Repository) FindIdOrCreate(address string) (uint64, error) {
 		return 0, err
 	}
 
-	r.cache.Store(address, adr.ID)
+	r.cache.Add(address, adr.ID)
 	return adr.ID, err
 }
 
 func (r *Repository) FindById(id uint64) (string, error) {
 	//First look in the cache
-	address, ok := r.invCache.Load(id)
+	address, ok := r.invCache.Get(id)
 	if ok {
 		return address.(string), nil
 	}
@@ -70,8 +70,8 @@ func (r *Repository) FindById(id uint64) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	r.cache.Store(a.Address, id)
-	r.invCache.Store(id, a.Address)
+	r.cache.Add(a.Address, id)
+	r.invCache.Add(id, a.Address)
 	return a.Address, nil
 }
 
@@ -85,18 +85,86 @@ func (r *Repository) FindAll(addresses []string) ([]*models.Address, error) {
 	return aList, err
 }
 
+// Find addresses which were touched by transactions or events in blocks with id >= height
+func (r *Repository) FindAllChangedFromBlock(height uint64) ([]string, error) {
+	var addresses []string
+	_, err := r.db.Query(&addresses, `
+select a.address
+from addresses a
+where a.id in (select from_address_id from transactions where block_id >= ?0
+               union
+               select o.to_address_id
+               from transaction_outputs o
+                      inner join transactions t on o.transaction_id = t.id
+               where t.block_id >= ?0
+               union
+               select address_id from rewards where block_id >= ?0
+               union
+               select address_id from slashes where block_id >= ?0);
+	`, height)
+	return addresses, err
+}
+
+// Return up to count addresses picked at random
+func (r *Repository) FindRandom(count int) ([]string, error) {
+	var addresses []string
+	_, err := r.db.Query(&addresses, `
+select address
+from addresses
+where id in (select (random() * (select max(id) from addresses))::bigint + 1
+             from generate_series(1, ?));
+	`, count)
+	return addresses, err
+}
+
+// Return ids of up to limit addresses with the most transactions in blocks with id >= height, most active first
+func (r *Repository) FindMostActiveIds(height uint64, limit int) ([]uint64, error) {
+	var ids []uint64
+	_, err := r.db.Query(&ids, `
+select address_id
+from index_transaction_by_address
+where block_id >= ?
+group by address_id
+order by count(*) desc
+limit ?;
+	`, height, limit)
+	return ids, err
+}
+
+// Load addresses by ids into caches, returns count of loaded addresses
+func (r *Repository) LoadToCache(ids []uint64) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	var aList []*models.Address
+	err := r.db.Model(&aList).Column("id", "address").Where("id in (?)", pg.In(ids)).Select()
+	if err != nil {
+		return 0, err
+	}
+	r.addToCache(aList)
+	return len(aList), nil
+}
+
 func (r *Repository) SaveAllIfNotExist(addresses []string) error {
 	// if all addresses exists in cache do nothing
 	loadFromDb := r.checkNotInCache(addresses)
 	if len(loadFromDb) == 0 {
 		return nil
 	}
+	// existence is decided by found rows, cache may evict them before they are checked
+	found, err := r.FindAll(loadFromDb)
+	if err != nil {
+		return err
+	}
+	exist := make(map[string]bool, len(found))
+	for _, a := range found {
+		exist[a.Address] = true
+	}
 	var args []interface{}
-	var aList []*models.Address  // need for cache update after insert
-	_, _ = r.FindAll(loadFromDb) //use for update cache
-	for _, a := range addresses {
-		_, exist := r.cache.Load(a)
-		if !exist {
+	var aList []*models.Address // need for cache update after insert
+	for _, a := range loadFromDb {
+		if !exist[a] {
+			exist[a] = true
 			address := &models.Address{Address: a}
 			args = append(args, address)
 			aList = append(aList, address)
@@ -106,8 +174,8 @@ func (r *Repository) SaveAllIfNotExist(addresses []string) error {
 	if len(args) == 0 {
 		return nil
 	}
-	err := r.db.Insert(args...)
-	if err != nil {
+	err = r.db.Insert(args...)
+	if err == nil {
 		r.addToCache(aList)
 	}
 
@@ -128,18 +196,15 @@ func (r Repository) SaveFromMapIfNotExists(addresses map[string]struct{}) error
 
 func (r *Repository) addToCache(addresses []*models.Address) {
 	for _, a := range addresses {
-		_, exist := r.cache.Load(a)
-		if !exist {
-			r.cache.Store(a.Address, a.ID)
-			r.invCache.Store(a.ID, a.Address)
-		}
+		r.cache.Add(a.Address, a.ID)
+		r.invCache.Add(a.ID, a.Address)
 	}
 }
 
 func (r *Repository) checkNotInCache(addresses []string) []string {
 	var list []string
 	for _, a := range addresses {
-		_, exist := r.cache.Load(a)
+		_, exist := r.cache.Peek(a)
 		if !exist {
 			list = append(list, a)
 		}
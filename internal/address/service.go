@@ -10,6 +10,8 @@ import (
 	"github.com/noah-blockchain/coinExplorer-tools/helpers"
 	"github.com/noah-blockchain/coinExplorer-tools/models"
 	node_models "github.com/noah-blockchain/noah-explorer-tools/models"
+	"github.com/noah-blockchain/noah-extender/internal/cursor"
+	"github.com/noah-blockchain/noah-extender/internal/failure"
 	"github.com/noah-blockchain/noah-go-node/core/check"
 	"github.com/noah-blockchain/noah-node-go-api/responses"
 	"github.com/sirupsen/logrus"
@@ -19,33 +21,36 @@ type Service struct {
 	env                *models.ExtenderEnvironment
 	repository         *Repository
 	chBalanceAddresses chan<- models.BlockAddresses
-	jobSaveAddresses   chan []string
+	cursorService      *cursor.Service
+	failureService     *failure.Service
+	jobSaveAddresses   chan models.BlockAddresses
 	wgAddresses        sync.WaitGroup
 	logger             *logrus.Entry
 }
 
-func NewService(env *models.ExtenderEnvironment, repository *Repository, chBalanceAddresses chan<- models.BlockAddresses, logger *logrus.Entry) *Service {
+func NewService(env *models.ExtenderEnvironment, repository *Repository, chBalanceAddresses chan<- models.BlockAddresses,
+	cursorService *cursor.Service, failureService *failure.Service, logger *logrus.Entry) *Service {
 	return &Service{
 		env:                env,
 		repository:         repository,
 		chBalanceAddresses: chBalanceAddresses,
-		jobSaveAddresses:   make(chan []string, env.WrkSaveAddressesCount),
+		cursorService:      cursorService,
+		failureService:     failureService,
+		jobSaveAddresses:   make(chan models.BlockAddresses, env.WrkSaveAddressesCount),
 		logger:             logger,
 	}
 }
 
-func (s *Service) GetSaveAddressesJobChannel() chan []string {
+func (s *Service) GetSaveAddressesJobChannel() chan models.BlockAddresses {
 	return s.jobSaveAddresses
 }
 
-func (s *Service) SaveAddressesWorker(jobs <-chan []string) {
-	for addresses := range jobs {
-		err := s.repository.SaveAllIfNotExist(addresses)
-		if err != nil {
-			s.logger.Error(err)
-		}
-		helpers.HandleError(err)
-
+// Addresses which could not be saved are reported by stages which refer to them
+func (s *Service) SaveAddressesWorker(jobs <-chan models.BlockAddresses) {
+	for job := range jobs {
+		_ = s.failureService.Do(failure.StageAddresses, job.Height, func() error {
+			return s.repository.SaveAllIfNotExist(job.Addresses)
+		})
 		s.wgAddresses.Done()
 	}
 }
@@ -55,7 +60,7 @@ func (s *Service) ExtractAddressesFromTransactions(transactions []responses.Tran
 	for _, tx := range transactions {
 		if tx.Data == nil {
 			s.logger.Error("empty transaction data")
-			return nil, errors.New("empty transaction data"), nil
+			return nil, failure.Permanent(errors.New("empty transaction data")), nil
 		}
 		mapAddresses[helpers.RemovePrefixFromAddress(tx.From)] = struct{}{}
 		if tx.Type == node_models.TxTypeSend {
@@ -153,11 +158,12 @@ func (s *Service) HandleResponses(blockResponse *responses.BlockResponse, events
 				end = len(addresses)
 			}
 			s.wgAddresses.Add(1)
-			s.GetSaveAddressesJobChannel() <- addresses[start:end]
+			s.GetSaveAddressesJobChannel() <- models.BlockAddresses{Height: height, Addresses: addresses[start:end]}
 		}
 		s.wgAddresses.Wait()
 
 		if height != 0 {
+			s.cursorService.Add(cursor.StageBalances, height)
 			s.chBalanceAddresses <- models.BlockAddresses{Height: height, Addresses: addresses}
 		}
 	}
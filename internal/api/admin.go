@@ -0,0 +1,274 @@
+package api
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Max count of addresses in a single balance refresh
+const AdminMaxAddresses = 1000
+
+var addressPattern = regexp.MustCompile(`^NOAHx[0-9a-fA-F]{40}$`)
+
+// Operational actions of the running extender
+type Admin interface {
+	RefreshBalances(addresses []string) error
+	RefreshCoin(symbol string) error
+	AggregateRewards(from, to uint64) error
+	PauseIngestion()
+	ResumeIngestion()
+	EnqueueReindex(from, to uint64, stages []string) error
+}
+
+// Admin endpoints are enabled when Token or ClientCAFile is set.
+// Server uses TLS when CertFile and KeyFile are set, client certificates signed by ClientCAFile
+// are accepted instead of the token. Token requires TLS unless AllowInsecure is set.
+type AdminConfig struct {
+	Token         string
+	CertFile      string
+	KeyFile       string
+	ClientCAFile  string
+	AllowInsecure bool
+	// Audit log is written to stdout if it is empty
+	AuditLog string
+}
+
+type badRequestError struct {
+	err error
+}
+
+func (e badRequestError) Error() string {
+	return e.err.Error()
+}
+
+// Wrap error of invalid input of an admin action, it is answered with 400 Bad Request
+func BadRequest(err error) error {
+	return badRequestError{err}
+}
+
+type rangeRequest struct {
+	From uint64 `json:"from"`
+	To   uint64 `json:"to"`
+}
+
+type reindexRequest struct {
+	From   uint64   `json:"from"`
+	To     uint64   `json:"to"`
+	Stages []string `json:"stages"`
+}
+
+type balancesRequest struct {
+	Addresses []string `json:"addresses"`
+}
+
+type coinRequest struct {
+	Symbol string `json:"symbol"`
+}
+
+// Configure TLS and admin endpoints, has to be called before Run
+func (api *Api) EnableAdmin(config AdminConfig) error {
+	if (config.CertFile == "") != (config.KeyFile == "") {
+		return errors.New("both TLS certificate and key are required")
+	}
+	if config.ClientCAFile != "" && config.CertFile == "" {
+		return errors.New("client certificates require TLS certificate of the server")
+	}
+	if config.Token != "" && config.CertFile == "" && !config.AllowInsecure {
+		return errors.New("admin token requires TLS certificate of the server unless insecure admin API is allowed")
+	}
+
+	if config.CertFile != "" {
+		api.tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		if config.ClientCAFile != "" {
+			ca, err := ioutil.ReadFile(config.ClientCAFile)
+			if err != nil {
+				return err
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(ca) {
+				return fmt.Errorf("no certificates found in %s", config.ClientCAFile)
+			}
+			api.tlsConfig.ClientCAs = pool
+			// metrics and health endpoints stay available without client certificate
+			api.tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+	api.adminConfig = config
+
+	if config.Token == "" && config.ClientCAFile == "" {
+		return nil
+	}
+	audit := logrus.New()
+	audit.SetFormatter(&logrus.JSONFormatter{})
+	audit.SetOutput(os.Stdout)
+	if config.AuditLog != "" {
+		file, err := os.OpenFile(config.AuditLog, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		audit.SetOutput(file)
+	}
+	if config.Token != "" && config.CertFile == "" {
+		audit.Warn("admin token is accepted over plain HTTP")
+	}
+	api.audit = audit
+	return nil
+}
+
+// Set the extender actions, admin endpoints respond with 503 until then
+func (api *Api) SetAdmin(admin Admin) {
+	api.mu.Lock()
+	api.admin = admin
+	api.mu.Unlock()
+}
+
+func (api *Api) getAdmin() Admin {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return api.admin
+}
+
+func (api *Api) handleAdmin() {
+	if api.audit == nil {
+		return
+	}
+	http.HandleFunc("/admin/balances/refresh", api.adminAction("refresh_balances", refreshBalances))
+	http.HandleFunc("/admin/coins/refresh", api.adminAction("refresh_coin", refreshCoin))
+	http.HandleFunc("/admin/rewards/aggregate", api.adminAction("aggregate_rewards", aggregateRewards))
+	http.HandleFunc("/admin/ingestion/pause", api.adminAction("pause_ingestion", pauseIngestion))
+	http.HandleFunc("/admin/ingestion/resume", api.adminAction("resume_ingestion", resumeIngestion))
+	http.HandleFunc("/admin/reindex", api.adminAction("enqueue_reindex", enqueueReindex))
+}
+
+// Authenticate the request, run the action and write it to the audit log.
+// Action returns its decoded request which is kept in the audit log.
+func (api *Api) adminAction(action string, run func(admin Admin, r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entry := api.audit.WithFields(logrus.Fields{
+			"action": action,
+			"remote": r.RemoteAddr,
+		})
+		if r.Method != http.MethodPost {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		identity := api.authenticate(r)
+		if identity == "" {
+			entry.Warn("unauthorized")
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		entry = entry.WithField("identity", identity)
+
+		admin := api.getAdmin()
+		if admin == nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"extender": "starting"})
+			return
+		}
+
+		request, err := run(admin, r)
+		entry = entry.WithField("request", request)
+		if err != nil {
+			entry.WithField("error", err.Error()).Error("failed")
+			code := http.StatusInternalServerError
+			if _, ok := err.(badRequestError); ok {
+				code = http.StatusBadRequest
+			}
+			writeJSON(w, code, map[string]string{"error": err.Error()})
+			return
+		}
+		entry.Info("done")
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	}
+}
+
+// Return name of the client certificate or "token", empty string if the request is not authenticated
+func (api *Api) authenticate(r *http.Request) string {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return "cert:" + r.TLS.VerifiedChains[0][0].Subject.CommonName
+	}
+	token := api.adminConfig.Token
+	header := r.Header.Get("Authorization")
+	if token != "" && strings.HasPrefix(header, "Bearer ") &&
+		subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(header, "Bearer ")), []byte(token)) == 1 {
+		return "token"
+	}
+	return ""
+}
+
+func decodeRequest(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return badRequestError{err}
+	}
+	return nil
+}
+
+func refreshBalances(admin Admin, r *http.Request) (interface{}, error) {
+	request := new(balancesRequest)
+	if err := decodeRequest(r, request); err != nil {
+		return nil, err
+	}
+	if len(request.Addresses) == 0 || len(request.Addresses) > AdminMaxAddresses {
+		return request, badRequestError{fmt.Errorf("from 1 to %d addresses expected", AdminMaxAddresses)}
+	}
+	for _, address := range request.Addresses {
+		if !addressPattern.MatchString(address) {
+			return request, badRequestError{fmt.Errorf("invalid address %q", address)}
+		}
+	}
+	return request, admin.RefreshBalances(request.Addresses)
+}
+
+func refreshCoin(admin Admin, r *http.Request) (interface{}, error) {
+	request := new(coinRequest)
+	if err := decodeRequest(r, request); err != nil {
+		return nil, err
+	}
+	if request.Symbol == "" {
+		return request, badRequestError{errors.New("symbol expected")}
+	}
+	return request, admin.RefreshCoin(request.Symbol)
+}
+
+func aggregateRewards(admin Admin, r *http.Request) (interface{}, error) {
+	request := new(rangeRequest)
+	if err := decodeRequest(r, request); err != nil {
+		return nil, err
+	}
+	if request.From == 0 || request.To < request.From {
+		return request, badRequestError{fmt.Errorf("invalid height range %d..%d", request.From, request.To)}
+	}
+	return request, admin.AggregateRewards(request.From, request.To)
+}
+
+func pauseIngestion(admin Admin, r *http.Request) (interface{}, error) {
+	admin.PauseIngestion()
+	return nil, nil
+}
+
+func resumeIngestion(admin Admin, r *http.Request) (interface{}, error) {
+	admin.ResumeIngestion()
+	return nil, nil
+}
+
+func enqueueReindex(admin Admin, r *http.Request) (interface{}, error) {
+	request := new(reindexRequest)
+	if err := decodeRequest(r, request); err != nil {
+		return nil, err
+	}
+	if request.From == 0 || request.To < request.From {
+		return request, badRequestError{fmt.Errorf("invalid height range %d..%d", request.From, request.To)}
+	}
+	return request, admin.EnqueueReindex(request.From, request.To, request.Stages)
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeAdmin struct {
+	paused    bool
+	addresses []string
+}
+
+func (f *fakeAdmin) RefreshBalances(addresses []string) error {
+	f.addresses = addresses
+	return nil
+}
+
+func (f *fakeAdmin) RefreshCoin(symbol string) error {
+	return nil
+}
+
+func (f *fakeAdmin) AggregateRewards(from, to uint64) error {
+	return nil
+}
+
+func (f *fakeAdmin) PauseIngestion() {
+	f.paused = true
+}
+
+func (f *fakeAdmin) ResumeIngestion() {
+	f.paused = false
+}
+
+func (f *fakeAdmin) EnqueueReindex(from, to uint64, stages []string) error {
+	return nil
+}
+
+func newTestAdminApi(t *testing.T, admin Admin) (*Api, *bytes.Buffer) {
+	api := New("", 0, 10)
+	if err := api.EnableAdmin(AdminConfig{Token: "secret", AllowInsecure: true}); err != nil {
+		t.Fatal(err)
+	}
+	audit := new(bytes.Buffer)
+	api.audit.SetOutput(audit)
+	api.SetAdmin(admin)
+	return api, audit
+}
+
+func adminRequest(handler http.HandlerFunc, token, body string) int {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	handler(w, r)
+	return w.Code
+}
+
+func TestAdminRequiresToken(t *testing.T) {
+	admin := new(fakeAdmin)
+	api, audit := newTestAdminApi(t, admin)
+	pause := api.adminAction("pause_ingestion", pauseIngestion)
+
+	if adminRequest(pause, "", "") != http.StatusUnauthorized || adminRequest(pause, "wrong", "") != http.StatusUnauthorized {
+		t.Error("Request without valid token must be rejected")
+	}
+	if admin.paused {
+		t.Error("Rejected action must not be run")
+	}
+	if adminRequest(pause, "secret", "") != http.StatusOK || !admin.paused {
+		t.Error("Request with valid token must run the action")
+	}
+	if strings.Count(audit.String(), "pause_ingestion") != 3 {
+		t.Error("Every request must be written to the audit log, got ", audit.String())
+	}
+}
+
+func TestAdminRefreshBalances(t *testing.T) {
+	admin := new(fakeAdmin)
+	api, audit := newTestAdminApi(t, admin)
+	refresh := api.adminAction("refresh_balances", refreshBalances)
+
+	if adminRequest(refresh, "secret", `{"addresses": []}`) != http.StatusBadRequest ||
+		adminRequest(refresh, "secret", `not json`) != http.StatusBadRequest {
+		t.Error("Invalid request must be rejected")
+	}
+	if adminRequest(refresh, "secret", `{"addresses": ["NOAHx00000000000000000000000000000000000000aa"]}`) != http.StatusOK || len(admin.addresses) != 1 {
+		t.Error("Balances of the addresses must be refreshed")
+	}
+	if adminRequest(refresh, "secret", `{"addresses": ["NOAHxaa"]}`) != http.StatusBadRequest || len(admin.addresses) != 1 {
+		t.Error("Invalid address must be rejected")
+	}
+	if !strings.Contains(audit.String(), "NOAHx00000000000000000000000000000000000000aa") {
+		t.Error("Request must be written to the audit log, got ", audit.String())
+	}
+}
+
+func TestAdminDisabledWithoutCredentials(t *testing.T) {
+	api := New("", 0, 10)
+	if err := api.EnableAdmin(AdminConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if api.audit != nil {
+		t.Error("Admin endpoints must be disabled without token or client CA")
+	}
+	if err := api.EnableAdmin(AdminConfig{ClientCAFile: "ca.pem"}); err == nil {
+		t.Error("Client CA must require TLS certificate")
+	}
+	if err := api.EnableAdmin(AdminConfig{Token: "secret"}); err == nil {
+		t.Error("Token must require TLS certificate unless insecure admin API is allowed")
+	}
+}
+
+func TestAdminUnavailableAfterShutdown(t *testing.T) {
+	admin := new(fakeAdmin)
+	api, _ := newTestAdminApi(t, admin)
+	pause := api.adminAction("pause_ingestion", pauseIngestion)
+
+	if err := api.Shutdown(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if adminRequest(pause, "secret", "") != http.StatusServiceUnavailable || admin.paused {
+		t.Error("Actions must not be run after shutdown")
+	}
+}
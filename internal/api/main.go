@@ -1,28 +1,172 @@
 package api
 
 import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
 	"net/http"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/noah-blockchain/coinExplorer-tools/helpers"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
 )
 
+// Status of ingestion served by /status
+type Status struct {
+	Height      uint64            `json:"height"`
+	NodeHeight  uint64            `json:"node_height"`
+	ChasingMode bool              `json:"chasing_mode"`
+	Paused      bool              `json:"paused"`
+	Halted      bool              `json:"halted"`
+	Cursors     map[string]uint64 `json:"cursors"`
+	Queues      map[string]int    `json:"queues"`
+	LastError   string            `json:"last_error,omitempty"`
+	LastErrorAt *time.Time        `json:"last_error_at,omitempty"`
+}
+
+// Extender reports its status and health of its dependencies
+type Extender interface {
+	Status() Status
+	// Return errors of unreachable dependencies by name, nil error if dependency is reachable
+	CheckHealth() map[string]error
+}
+
 type Api struct {
-	Host string
-	Port int
+	Host        string
+	Port        int
+	ReadyMaxLag uint64
+	mu          sync.RWMutex
+	extender    Extender
+	admin       Admin
+	adminConfig AdminConfig
+	tlsConfig   *tls.Config
+	audit       *logrus.Logger
+	server      *http.Server
 }
 
-func New(host string, port int) *Api {
-	return &Api{host, port}
+func New(host string, port int, readyMaxLag uint64) *Api {
+	return &Api{
+		Host:        host,
+		Port:        port,
+		ReadyMaxLag: readyMaxLag,
+	}
 }
 
-func (api Api) GetLink() string {
+func (api *Api) GetLink() string {
 	return api.Host + ":" + strconv.Itoa(api.Port)
 }
 
-func (api Api) Run() {
+// Set the extender once it is created, health endpoints respond with 503 until then
+func (api *Api) SetExtender(extender Extender) {
+	api.mu.Lock()
+	api.extender = extender
+	api.mu.Unlock()
+}
+
+func (api *Api) Run() {
 	http.Handle("/metrics", promhttp.Handler())
-	err := http.ListenAndServe(api.GetLink(), nil)
+	http.HandleFunc("/healthz", api.healthz)
+	http.HandleFunc("/readyz", api.readyz)
+	http.HandleFunc("/status", api.status)
+	api.handleAdmin()
+
+	server := &http.Server{Addr: api.GetLink(), TLSConfig: api.tlsConfig}
+	api.mu.Lock()
+	api.server = server
+	api.mu.Unlock()
+
+	var err error
+	if api.tlsConfig != nil {
+		err = server.ListenAndServeTLS(api.adminConfig.CertFile, api.adminConfig.KeyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err == http.ErrServerClosed {
+		return
+	}
 	helpers.HandleError(err)
 }
+
+// Stop accepting requests and wait for the running ones within timeout,
+// so admin actions do not send jobs to workers which are being drained
+func (api *Api) Shutdown(timeout time.Duration) error {
+	api.mu.Lock()
+	api.admin = nil
+	server := api.server
+	api.mu.Unlock()
+	if server == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return server.Shutdown(ctx)
+}
+
+func (api *Api) getExtender() Extender {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return api.extender
+}
+
+// Process is alive and DB, NATS and node are reachable
+func (api *Api) healthz(w http.ResponseWriter, r *http.Request) {
+	extender := api.getExtender()
+	if extender == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"extender": "starting"})
+		return
+	}
+
+	code := http.StatusOK
+	result := make(map[string]string)
+	for name, err := range extender.CheckHealth() {
+		result[name] = "ok"
+		if err != nil {
+			result[name] = err.Error()
+			code = http.StatusServiceUnavailable
+		}
+	}
+	writeJSON(w, code, result)
+}
+
+// Ingestion is running and is behind the node by no more than ReadyMaxLag blocks
+func (api *Api) readyz(w http.ResponseWriter, r *http.Request) {
+	extender := api.getExtender()
+	if extender == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"extender": "starting"})
+		return
+	}
+
+	status := extender.Status()
+	var lag uint64
+	if status.NodeHeight > status.Height {
+		lag = status.NodeHeight - status.Height
+	}
+	code := http.StatusOK
+	if status.Height == 0 || status.Halted || lag > api.ReadyMaxLag {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, map[string]interface{}{
+		"height":      status.Height,
+		"node_height": status.NodeHeight,
+		"lag":         lag,
+		"halted":      status.Halted,
+	})
+}
+
+func (api *Api) status(w http.ResponseWriter, r *http.Request) {
+	extender := api.getExtender()
+	if extender == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"extender": "starting"})
+		return
+	}
+	writeJSON(w, http.StatusOK, extender.Status())
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeExtender struct {
+	status Status
+	health map[string]error
+}
+
+func (f *fakeExtender) Status() Status {
+	return f.status
+}
+
+func (f *fakeExtender) CheckHealth() map[string]error {
+	return f.health
+}
+
+func request(handler http.HandlerFunc) int {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	return w.Code
+}
+
+func TestHealthEndpointsBeforeStart(t *testing.T) {
+	api := New("", 0, 10)
+	if request(api.healthz) != http.StatusServiceUnavailable || request(api.readyz) != http.StatusServiceUnavailable {
+		t.Error("Extender must not be healthy or ready before it is set")
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	extender := &fakeExtender{health: map[string]error{"db": nil, "node": nil}}
+	api := New("", 0, 10)
+	api.SetExtender(extender)
+	if request(api.healthz) != http.StatusOK {
+		t.Error("Extender with reachable dependencies must be healthy")
+	}
+	extender.health["node"] = errors.New("connection refused")
+	if request(api.healthz) != http.StatusServiceUnavailable {
+		t.Error("Extender with unreachable node must not be healthy")
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	extender := &fakeExtender{status: Status{Height: 95, NodeHeight: 100}}
+	api := New("", 0, 10)
+	api.SetExtender(extender)
+	if request(api.readyz) != http.StatusOK {
+		t.Error("Extender within lag threshold must be ready")
+	}
+	extender.status.Height = 80
+	if request(api.readyz) != http.StatusServiceUnavailable {
+		t.Error("Extender behind the threshold must not be ready")
+	}
+	extender.status = Status{Height: 100, NodeHeight: 100, Halted: true}
+	if request(api.readyz) != http.StatusServiceUnavailable {
+		t.Error("Halted extender must not be ready")
+	}
+}
@@ -0,0 +1,43 @@
+package balance
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// Change of the balance of address and coin at the height, zero NewValue means the balance has been removed
+type History struct {
+	tableName struct{}  `sql:"balance_history"`
+	AddressID uint64    `json:"address_id" sql:",pk"`
+	CoinID    uint64    `json:"coin_id"    sql:",pk"`
+	Height    uint64    `json:"height"     sql:",pk"`
+	OldValue  string    `json:"old_value"  sql:"type:numeric(70),notnull"`
+	NewValue  string    `json:"new_value"  sql:"type:numeric(70),notnull"`
+	Delta     string    `json:"delta"      sql:"type:numeric(70),notnull"`
+	CreatedAt time.Time `json:"created_at" sql:"default:now()"`
+}
+
+// Return change of the balance from oldValue to newValue, nil if the value has not changed
+func newHistory(height, addressID, coinID uint64, oldValue, newValue string) (*History, error) {
+	oldInt, ok := new(big.Int).SetString(oldValue, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid balance value %q", oldValue)
+	}
+	newInt, ok := new(big.Int).SetString(newValue, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid balance value %q", newValue)
+	}
+	delta := new(big.Int).Sub(newInt, oldInt)
+	if delta.Sign() == 0 {
+		return nil, nil
+	}
+	return &History{
+		AddressID: addressID,
+		CoinID:    coinID,
+		Height:    height,
+		OldValue:  oldInt.String(),
+		NewValue:  newInt.String(),
+		Delta:     delta.String(),
+	}, nil
+}
@@ -0,0 +1,25 @@
+package balance
+
+import "testing"
+
+func TestNewHistory(t *testing.T) {
+	change, err := newHistory(10, 1, 2, "1000000000000000000000", "400000000000000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if change.Height != 10 || change.AddressID != 1 || change.CoinID != 2 ||
+		change.OldValue != "1000000000000000000000" || change.NewValue != "400000000000000000000" ||
+		change.Delta != "-600000000000000000000" {
+		t.Errorf("Unexpected change %+v", change)
+	}
+
+	if change, err = newHistory(10, 1, 2, "5", "5"); err != nil || change != nil {
+		t.Errorf("Unchanged balance must not be recorded, got %+v, %v", change, err)
+	}
+	if change, err = newHistory(10, 1, 2, "0", "5"); err != nil || change.Delta != "5" {
+		t.Errorf("Created balance must be recorded from zero, got %+v, %v", change, err)
+	}
+	if _, err = newHistory(10, 1, 2, "0", "1.5"); err == nil {
+		t.Error("Invalid value must be rejected")
+	}
+}
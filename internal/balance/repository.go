@@ -2,11 +2,12 @@ package balance
 
 import (
 	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
 	"github.com/noah-blockchain/coinExplorer-tools/models"
 )
 
 type Repository struct {
-	db *pg.DB
+	db orm.DB
 }
 
 func NewRepository(db *pg.DB) *Repository {
@@ -15,6 +16,13 @@ func NewRepository(db *pg.DB) *Repository {
 	}
 }
 
+// Return copy of repository which executes all queries within DB transaction
+func (r *Repository) WithTx(tx *pg.Tx) *Repository {
+	return &Repository{
+		db: tx,
+	}
+}
+
 func (r *Repository) FindAllByAddress(addresses []string) ([]*models.Balance, error) {
 	var balances []*models.Balance
 	err := r.db.Model(&balances).
@@ -36,6 +44,18 @@ func (r *Repository) SaveAll(balances []*models.Balance) error {
 	return r.db.Insert(args...)
 }
 
+// Insert balances or update values of existing ones by address and coin
+func (r *Repository) SaveAllOrUpdate(balances []*models.Balance) error {
+	if len(balances) == 0 {
+		return nil
+	}
+	_, err := r.db.Model(&balances).
+		OnConflict("(address_id, coin_id) DO UPDATE").
+		Set("value = EXCLUDED.value").
+		Insert()
+	return err
+}
+
 func (r *Repository) UpdateAll(balances []*models.Balance) error {
 	_, err := r.db.Model(&balances).Update()
 	return err
@@ -46,7 +66,53 @@ func (r *Repository) DeleteAll(balances []*models.Balance) error {
 	return err
 }
 
-func (r Repository) DeleteByCoinId(coinId uint64) error {
-	_, err := r.db.Model(new(models.Balance)).Where("coin_id = ?", coinId).Delete()
+// Delete balances of the coin and record their removal into history at the height
+func (r Repository) DeleteByCoinId(coinId, height uint64) error {
+	_, err := r.db.Exec(`
+with deleted as (delete from balances where coin_id = ?0 returning address_id, coin_id, value)
+insert into balance_history (address_id, coin_id, height, old_value, new_value, delta)
+select address_id, coin_id, ?1, value, 0, -value
+from deleted
+where value <> 0
+on conflict (address_id, coin_id, height) do update set new_value = 0, delta = -balance_history.old_value;
+	`, coinId, height)
 	return err
 }
+
+// Save changes of balances, changes already saved at the same height are skipped
+func (r *Repository) SaveHistory(history []*History) error {
+	if len(history) == 0 {
+		return nil
+	}
+	_, err := r.db.Model(&history).OnConflict("DO NOTHING").Insert()
+	return err
+}
+
+// Return the last change of every coin balance of the address at or below the height,
+// balances which have been removed by the height are skipped
+func (r *Repository) GetHistoryAtHeight(addressID, height uint64) ([]*History, error) {
+	var history []*History
+	_, err := r.db.Query(&history, `
+select *
+from (select distinct on (coin_id) *
+      from balance_history
+      where address_id = ?
+        and height <= ?
+      order by coin_id, height desc) last
+where new_value <> 0
+order by coin_id;
+	`, addressID, height)
+	return history, err
+}
+
+// Return changes of the coin balance of the address at heights from..to in height order
+func (r *Repository) GetHistoryTimeline(addressID, coinID, from, to uint64) ([]*History, error) {
+	var history []*History
+	err := r.db.Model(&history).
+		Where("address_id = ?", addressID).
+		Where("coin_id = ?", coinID).
+		Where("height BETWEEN ? AND ?", from, to).
+		Order("height ASC").
+		Select()
+	return history, err
+}
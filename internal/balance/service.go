@@ -1,24 +1,30 @@
 package balance
 
 import (
+	"errors"
 	"math"
+	"strconv"
 	"sync"
 
 	"github.com/noah-blockchain/coinExplorer-tools/helpers"
 	"github.com/noah-blockchain/coinExplorer-tools/models"
 	"github.com/noah-blockchain/noah-extender/internal/address"
 	"github.com/noah-blockchain/noah-extender/internal/coin"
-	"github.com/noah-blockchain/noah-node-go-api"
+	"github.com/noah-blockchain/noah-extender/internal/cursor"
+	"github.com/noah-blockchain/noah-extender/internal/failure"
+	"github.com/noah-blockchain/noah-extender/internal/node"
 	"github.com/noah-blockchain/noah-node-go-api/responses"
 	"github.com/sirupsen/logrus"
 )
 
 type Service struct {
 	env                    *models.ExtenderEnvironment
-	nodeApi                *noah_node_go_api.NoahNodeApi
+	nodeApi                node.Client
 	repository             *Repository
 	addressRepository      *address.Repository
 	coinRepository         *coin.Repository
+	cursorService          *cursor.Service
+	failureService         *failure.Service
 	jobGetBalancesFromNode chan models.BlockAddresses
 	jobUpdateBalance       chan AddressesBalancesContainer
 	chAddresses            chan models.BlockAddresses
@@ -27,23 +33,27 @@ type Service struct {
 }
 
 type AddressesBalancesContainer struct {
+	Height            uint64
 	Addresses         []string
 	Balances          []*models.Balance
-	nodeApi           *noah_node_go_api.NoahNodeApi
+	nodeApi           node.Client
 	repository        *Repository
 	addressRepository *address.Repository
 	coinRepository    *coin.Repository
 	chAddresses       chan models.BlockAddresses
 }
 
-func NewService(env *models.ExtenderEnvironment, repository *Repository, nodeApi *noah_node_go_api.NoahNodeApi,
-	addressRepository *address.Repository, coinRepository *coin.Repository, logger *logrus.Entry) *Service {
+func NewService(env *models.ExtenderEnvironment, repository *Repository, nodeApi node.Client,
+	addressRepository *address.Repository, coinRepository *coin.Repository, cursorService *cursor.Service,
+	failureService *failure.Service, logger *logrus.Entry) *Service {
 	return &Service{
 		env:                    env,
 		repository:             repository,
 		nodeApi:                nodeApi,
 		addressRepository:      addressRepository,
 		coinRepository:         coinRepository,
+		cursorService:          cursorService,
+		failureService:         failureService,
 		chAddresses:            make(chan models.BlockAddresses),
 		jobUpdateBalance:       make(chan AddressesBalancesContainer, env.WrkUpdateBalanceCount),
 		jobGetBalancesFromNode: make(chan models.BlockAddresses, env.WrkGetBalancesFromNodeCount),
@@ -64,9 +74,9 @@ func (s *Service) GetUpdateBalancesJobChannel() chan AddressesBalancesContainer
 }
 
 func (s *Service) Run() {
-	for {
-		addresses := <-s.chAddresses
+	for addresses := range s.chAddresses {
 		s.HandleAddresses(addresses)
+		s.cursorService.Done(cursor.StageBalances, addresses.Height)
 	}
 }
 
@@ -87,31 +97,68 @@ func (s *Service) HandleAddresses(blockAddresses models.BlockAddresses) {
 
 func (s *Service) GetBalancesFromNodeWorker(jobs <-chan models.BlockAddresses, result chan<- AddressesBalancesContainer) {
 	for blockAddresses := range jobs {
-		addresses := make([]string, len(blockAddresses.Addresses))
-		for i, adr := range blockAddresses.Addresses {
-			addresses[i] = `"NOAHx` + adr + `"`
-		}
-		response, err := s.nodeApi.GetAddresses(addresses, blockAddresses.Height)
+		var balances []*models.Balance
+		err := s.failureService.DoPayload(failure.StageBalances, blockAddresses.Height, blockAddresses, func() error {
+			response, err := s.nodeApi.GetAddresses(nodeAddresses(blockAddresses.Addresses), blockAddresses.Height)
+			if err != nil {
+				return err
+			}
+			balances, err = s.HandleBalanceResponse(response)
+			return err
+		})
 		if err != nil {
-			s.logger.Error(err)
+			s.wgBalances.Done()
 			continue
 		}
-		balances, err := s.HandleBalanceResponse(response)
-		if err != nil {
-			s.logger.Error(err)
-		} else {
-			result <- AddressesBalancesContainer{Addresses: blockAddresses.Addresses, Balances: balances}
+		result <- AddressesBalancesContainer{
+			Height:    blockAddresses.Height,
+			Addresses: blockAddresses.Addresses,
+			Balances:  balances,
 		}
 	}
 }
 
 func (s *Service) UpdateBalancesWorker(jobs <-chan AddressesBalancesContainer) {
 	for container := range jobs {
-		err := s.updateBalances(container.Addresses, container.Balances)
-		if err != nil {
-			s.logger.Error(err)
-		}
+		job := models.BlockAddresses{Height: container.Height, Addresses: container.Addresses}
+		_ = s.failureService.DoPayload(failure.StageBalances, container.Height, job, func() error {
+			return s.updateBalances(container.Height, container.Addresses, container.Balances)
+		})
+		s.wgBalances.Done()
+	}
+}
+
+// Request the latest balances of the addresses from the node and save them.
+// Node does not return height of the latest balances, their changes are recorded at the node height requested before them.
+func (s *Service) UpdateBalances(addresses []string) error {
+	status, err := s.nodeApi.GetStatus()
+	if err != nil {
+		return err
+	}
+	if status.Error != nil {
+		return errors.New(status.Error.Message)
+	}
+	height, err := strconv.ParseUint(status.Result.LatestBlockHeight, 10, 64)
+	if err != nil {
+		return err
+	}
+	response, err := s.nodeApi.GetAddresses(nodeAddresses(addresses), 0)
+	if err != nil {
+		return err
+	}
+	balances, err := s.HandleBalanceResponse(response)
+	if err != nil {
+		return err
 	}
+	return s.updateBalances(height, addresses, balances)
+}
+
+func nodeAddresses(addresses []string) []string {
+	list := make([]string, len(addresses))
+	for i, adr := range addresses {
+		list[i] = `"NOAHx` + adr + `"`
+	}
+	return list
 }
 
 func (s *Service) HandleBalanceResponse(response *responses.BalancesResponse) ([]*models.Balance, error) {
@@ -144,32 +191,55 @@ func (s *Service) HandleBalanceResponse(response *responses.BalancesResponse) ([
 	return balances, nil
 }
 
-func (s *Service) updateBalances(addresses []string, nodeBalances []*models.Balance) error {
-	defer s.wgBalances.Done()
-
+// Save balances of the height and record their changes into history.
+// History is saved first, so changes are not lost when saving of balances is retried.
+func (s *Service) updateBalances(height uint64, addresses []string, nodeBalances []*models.Balance) error {
 	dbBalances, err := s.repository.FindAllByAddress(addresses)
 	if err != nil {
 		s.logger.Error(err)
 		return err
 	}
-	//If no balances in DB save all
-	if dbBalances == nil {
-		return s.repository.SaveAll(nodeBalances)
-	}
 
 	mapAddressBalances := makeAddressBalanceMap(dbBalances)
 	var forCreate, forUpdate, forDelete []*models.Balance
+	var history []*History
+	addChange := func(addressID, coinID uint64, oldValue, newValue string) error {
+		change, err := newHistory(height, addressID, coinID, oldValue, newValue)
+		if change != nil {
+			history = append(history, change)
+		}
+		return err
+	}
 
 	for _, nodeBalance := range nodeBalances {
-		if mapAddressBalances[nodeBalance.AddressID][nodeBalance.CoinID] != nil {
-			mapAddressBalances[nodeBalance.AddressID][nodeBalance.CoinID].Value = nodeBalance.Value
-			forUpdate = append(forUpdate, mapAddressBalances[nodeBalance.AddressID][nodeBalance.CoinID])
+		if dbBalance := mapAddressBalances[nodeBalance.AddressID][nodeBalance.CoinID]; dbBalance != nil {
+			if err = addChange(nodeBalance.AddressID, nodeBalance.CoinID, dbBalance.Value, nodeBalance.Value); err != nil {
+				return err
+			}
+			dbBalance.Value = nodeBalance.Value
+			forUpdate = append(forUpdate, dbBalance)
 			delete(mapAddressBalances[nodeBalance.AddressID], nodeBalance.CoinID)
 		} else if nodeBalance.CoinID > 0 {
+			if err = addChange(nodeBalance.AddressID, nodeBalance.CoinID, "0", nodeBalance.Value); err != nil {
+				return err
+			}
 			forCreate = append(forCreate, nodeBalance)
 			delete(mapAddressBalances[nodeBalance.AddressID], nodeBalance.CoinID)
 		}
 	}
+	for _, adr := range mapAddressBalances {
+		for _, blc := range adr {
+			if err = addChange(blc.AddressID, blc.CoinID, blc.Value, "0"); err != nil {
+				return err
+			}
+			forDelete = append(forDelete, blc)
+		}
+	}
+
+	if err = s.repository.SaveHistory(history); err != nil {
+		s.logger.Error(err)
+		return err
+	}
 
 	if len(forCreate) > 0 {
 		err = s.repository.SaveAll(forCreate)
@@ -187,11 +257,6 @@ func (s *Service) updateBalances(addresses []string, nodeBalances []*models.Bala
 		}
 	}
 
-	for _, adr := range mapAddressBalances {
-		for _, blc := range adr {
-			forDelete = append(forDelete, blc)
-		}
-	}
 	if len(forDelete) > 0 {
 		err = s.repository.DeleteAll(forDelete)
 		if err != nil {
@@ -1,12 +1,20 @@
 package block
 
 import (
+	"math"
+	"time"
+
 	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
 	"github.com/noah-blockchain/coinExplorer-tools/models"
+	"github.com/noah-blockchain/noah-extender/internal/bulk"
 )
 
+var blockValidatorColumns = []string{"block_id", "validator_id", "signed", "created_at"}
+
 type Repository struct {
-	db *pg.DB
+	db   orm.DB
+	copy bool
 }
 
 func NewRepository(db *pg.DB) *Repository {
@@ -15,6 +23,19 @@ func NewRepository(db *pg.DB) *Repository {
 	}
 }
 
+// Return copy of repository which executes all queries within DB transaction
+func (r *Repository) WithTx(tx *pg.Tx) *Repository {
+	return &Repository{
+		db:   tx,
+		copy: r.copy,
+	}
+}
+
+// Write validator links with COPY instead of multi-row INSERT
+func (r *Repository) UseCopy() {
+	r.copy = true
+}
+
 func (r *Repository) Save(block *models.Block) error {
 	_, err := r.db.Model(block).Insert()
 	if err != nil {
@@ -33,6 +54,17 @@ func (r *Repository) GetLastFromDB() (*models.Block, error) {
 }
 
 func (r *Repository) LinkWithValidators(links []*models.BlockValidator) error {
+	if r.copy {
+		rows := new(bulk.Rows)
+		for _, l := range links {
+			rows.Uint(l.BlockID)
+			rows.Uint(l.ValidatorID)
+			rows.Bool(l.Signed)
+			rows.Time(l.CreatedAt)
+			rows.End()
+		}
+		return bulk.Copy(r.db, "block_validator", blockValidatorColumns, rows)
+	}
 	var args []interface{}
 	for _, l := range links {
 		args = append(args, l)
@@ -41,21 +73,172 @@ func (r *Repository) LinkWithValidators(links []*models.BlockValidator) error {
 	return err
 }
 
+// Find stored block hash by height
+func (r *Repository) FindHashById(id uint64) (string, error) {
+	block := new(models.Block)
+	err := r.db.Model(block).Column("hash").Where("id = ?", id).Select()
+	if err != nil {
+		return "", err
+	}
+	return block.Hash, nil
+}
+
 func (r *Repository) DeleteLastBlockData() error {
-	tx, err := r.db.Begin()
+	block, err := r.GetLastFromDB()
+	if err == pg.ErrNoRows {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
-	// Rollback tx on error.
-	defer tx.Rollback()
-	_, err = tx.Query(nil, `delete from transaction_outputs where transaction_id IN (select distinct id from transactions where block_id = (select id from blocks order by id desc limit 1));`)
-	_, err = tx.Query(nil, `delete from transaction_validator where transaction_id IN (select distinct id from transactions where block_id = (select id from blocks order by id desc limit 1));`)
-	_, err = tx.Query(nil, `delete from index_transaction_by_address where transaction_id in (select distinct id from transactions where block_id = (select id from blocks order by id desc limit 1));`)
-	_, err = tx.Query(nil, `delete from invalid_transactions  where block_id = (select id from blocks order by id desc limit 1);`)
-	_, err = tx.Query(nil, `delete from transactions where block_id = (select id from blocks order by id desc limit 1);`)
-	_, err = tx.Query(nil, `delete from rewards where block_id = (select id from blocks order by id desc limit 1);`)
-	_, err = tx.Query(nil, `delete from slashes where block_id = (select id from blocks order by id desc limit 1);`)
-	_, err = tx.Query(nil, `delete from block_validator where block_id = (select id from blocks order by id desc limit 1);`)
-	_, err = tx.Query(nil, `delete from blocks where id = (select id from blocks order by id desc limit 1);`)
-	return tx.Commit()
+	return r.DeleteBlocksFrom(block.ID)
+}
+
+// Delete blocks with id >= height and all data linked to them, changes of balances recorded at these heights included
+func (r *Repository) DeleteBlocksFrom(height uint64) error {
+	return r.deleteRange(height, maxHeight, transactionsQueries, eventsQueries, aggregatedRewardsQueries,
+		blockValidatorsQueries, balanceHistoryQueries, blocksQueries)
+}
+
+// Delete transactions with all linked data in blocks with id >= height
+func (r *Repository) DeleteTransactionsFrom(height uint64) error {
+	return r.deleteRange(height, maxHeight, transactionsQueries)
+}
+
+// Delete rewards, aggregated rewards and slashes in blocks with id >= height
+func (r *Repository) DeleteEventsFrom(height uint64) error {
+	return r.deleteRange(height, maxHeight, eventsQueries, aggregatedRewardsQueries)
+}
+
+// Delete transactions with all linked data in blocks with id from..to
+func (r *Repository) DeleteTransactionsRange(from, to uint64) error {
+	return r.deleteRange(from, to, transactionsQueries)
+}
+
+// Delete rewards and slashes in blocks with id from..to, aggregated rewards are kept
+func (r *Repository) DeleteEventsRange(from, to uint64) error {
+	return r.deleteRange(from, to, eventsQueries)
+}
+
+// Delete block-validator links of blocks with id from..to
+func (r *Repository) DeleteValidatorLinksRange(from, to uint64) error {
+	return r.deleteRange(from, to, blockValidatorsQueries)
+}
+
+// Delete detailed data of blocks with id from..to which is not needed for aggregates and current state.
+// Blocks, block validators, rewards, slashes and transactions which have created coins are kept,
+// block validators are needed to compute the uptime of validators since their creation.
+func (r *Repository) PruneRange(from, to uint64) error {
+	return r.deleteRange(from, to, prunedQueries)
+}
+
+// Return the lowest height which has detailed data to prune, 0 if there is no such data
+func (r *Repository) GetFirstPrunableHeight() (uint64, error) {
+	var height uint64
+	_, err := r.db.QueryOne(pg.Scan(&height), `select coalesce(min(block_id), 0) from transactions
+		where id not in (select creation_transaction_id from coins where creation_transaction_id is not null);`)
+	return height, err
+}
+
+// Return the highest height of blocks created before t, 0 if there are no such blocks
+func (r *Repository) GetLastHeightBefore(t time.Time) (uint64, error) {
+	var height uint64
+	_, err := r.db.QueryOne(pg.Scan(&height), `select coalesce(max(id), 0) from blocks where created_at < ?;`, t)
+	return height, err
+}
+
+const maxHeight = math.MaxInt64
+
+// Partitions of tables partitioned by block_id and by transaction_id cover such ranges of keys
+const (
+	BlockPartitionSize       = 1000000
+	TransactionPartitionSize = 10000000
+)
+
+var blockPartitionedTables = []string{"transactions", "block_validator", "index_transaction_by_address"}
+
+// Create partitions up to the one after the partition of height and after the partition of the last transaction,
+// so new rows are not routed to default partitions
+func (r *Repository) CreatePartitions(height uint64) error {
+	for _, table := range blockPartitionedTables {
+		_, err := r.db.Exec(`select public.create_range_partitions(?, 'block_id', ?, ?);`,
+			table, BlockPartitionSize, height+BlockPartitionSize)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := r.db.Exec(`
+select public.create_range_partitions('transaction_outputs', 'transaction_id', ?0,
+                                      (select coalesce(max(id), 0) from transactions) + ?0);
+	`, TransactionPartitionSize)
+	return err
+}
+
+var transactionsQueries = []string{
+	// bounds of transaction_id let partitions of transaction_outputs be pruned
+	`delete from transaction_outputs where transaction_id in (select id from transactions where block_id between ?0 and ?1)
+		and transaction_id between (select coalesce(min(id), 0) from transactions where block_id between ?0 and ?1)
+		                       and (select coalesce(max(id), 0) from transactions where block_id between ?0 and ?1);`,
+	`delete from transaction_validator where transaction_id in (select id from transactions where block_id between ?0 and ?1);`,
+	`delete from index_transaction_by_address where block_id between ?0 and ?1;`,
+	`update coins set creation_transaction_id = null, creation_address_id = null where creation_transaction_id in (select id from transactions where block_id between ?0 and ?1);`,
+	`delete from invalid_transactions where block_id between ?0 and ?1;`,
+	`delete from transactions where block_id between ?0 and ?1;`,
+}
+
+var prunedQueries = []string{
+	`delete from transaction_outputs where transaction_id in (select id from transactions where block_id between ?0 and ?1)
+		and transaction_id between (select coalesce(min(id), 0) from transactions where block_id between ?0 and ?1)
+		                       and (select coalesce(max(id), 0) from transactions where block_id between ?0 and ?1)
+		and transaction_id not in (select creation_transaction_id from coins where creation_transaction_id is not null);`,
+	`delete from transaction_validator where transaction_id in (select id from transactions where block_id between ?0 and ?1)
+		and transaction_id not in (select creation_transaction_id from coins where creation_transaction_id is not null);`,
+	`delete from index_transaction_by_address where block_id between ?0 and ?1
+		and transaction_id not in (select creation_transaction_id from coins where creation_transaction_id is not null);`,
+	`delete from invalid_transactions where block_id between ?0 and ?1;`,
+	`delete from transactions where block_id between ?0 and ?1
+		and id not in (select creation_transaction_id from coins where creation_transaction_id is not null);`,
+}
+
+var eventsQueries = []string{
+	`delete from rewards where block_id between ?0 and ?1;`,
+	`delete from slashes where block_id between ?0 and ?1;`,
+}
+
+var aggregatedRewardsQueries = []string{
+	`delete from aggregated_rewards where to_block_id >= ?0;`,
+}
+
+var blockValidatorsQueries = []string{
+	`delete from block_validator where block_id between ?0 and ?1;`,
+}
+
+// changes recorded at rolled back heights would be kept on re-ingestion as they are saved with ON CONFLICT DO NOTHING
+var balanceHistoryQueries = []string{
+	`delete from balance_history where height between ?0 and ?1;`,
+}
+
+var blocksQueries = []string{
+	`delete from blocks where id between ?0 and ?1;`,
+}
+
+func (r *Repository) deleteRange(from, to uint64, queryGroups ...[]string) error {
+	db, ok := r.db.(*pg.DB)
+	if !ok {
+		return execQueries(r.db, from, to, queryGroups)
+	}
+	return db.RunInTransaction(func(tx *pg.Tx) error {
+		return execQueries(tx, from, to, queryGroups)
+	})
+}
+
+func execQueries(db orm.DB, from, to uint64, queryGroups [][]string) error {
+	for _, queries := range queryGroups {
+		for _, q := range queries {
+			if _, err := db.Exec(q, from, to); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
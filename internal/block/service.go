@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/go-pg/pg"
 	"github.com/noah-blockchain/coinExplorer-tools/helpers"
 	"github.com/noah-blockchain/coinExplorer-tools/models"
 	"github.com/noah-blockchain/noah-extender/internal/validator"
@@ -34,19 +35,49 @@ func (s *Service) GetBlockCache() (b *models.Block) {
 
 //Handle response and save block to DB
 func (s *Service) HandleBlockResponse(response *responses.BlockResponse) error {
+	block, err := s.makeBlock(response)
+	if err != nil {
+		return err
+	}
+	s.SetBlockCache(block)
+
+	return s.blockRepository.Save(block)
+}
+
+//Handle response and save block within DB transaction.
+//Block cache should be updated by caller after the transaction is committed
+func (s *Service) HandleBlockResponseTx(tx *pg.Tx, response *responses.BlockResponse) (*models.Block, error) {
+	block, err := s.makeBlock(response)
+	if err != nil {
+		return nil, err
+	}
+	return block, s.blockRepository.WithTx(tx).Save(block)
+}
+
+func (s *Service) makeBlock(response *responses.BlockResponse) (*models.Block, error) {
 	height, err := strconv.ParseUint(response.Result.Height, 10, 64)
-	helpers.HandleError(err)
+	if err != nil {
+		return nil, err
+	}
 	totalTx, err := strconv.ParseUint(response.Result.TotalTx, 10, 64)
-	helpers.HandleError(err)
+	if err != nil {
+		return nil, err
+	}
 	numTx, err := strconv.ParseUint(response.Result.TxCount, 10, 32)
-	helpers.HandleError(err)
+	if err != nil {
+		return nil, err
+	}
 	size, err := strconv.ParseUint(response.Result.Size, 10, 64)
-	helpers.HandleError(err)
+	if err != nil {
+		return nil, err
+	}
 
 	var proposerId uint64
 	if response.Result.Proposer != "" {
 		proposerId, err = s.validatorRepository.FindIdByPk(helpers.RemovePrefix(response.Result.Proposer))
-		helpers.HandleError(err)
+		if err != nil {
+			return nil, err
+		}
 	} else {
 		proposerId = 1
 	}
@@ -56,7 +87,7 @@ func (s *Service) HandleBlockResponse(response *responses.BlockResponse) error {
 		blockTime = math.MaxInt64 - 1
 	}
 
-	block := &models.Block{
+	return &models.Block{
 		ID:                  height,
 		TotalTxs:            totalTx,
 		NumTxs:              uint32(numTx),
@@ -66,10 +97,7 @@ func (s *Service) HandleBlockResponse(response *responses.BlockResponse) error {
 		BlockReward:         response.Result.BlockReward,
 		ProposerValidatorID: proposerId,
 		Hash:                response.Result.Hash,
-	}
-	s.SetBlockCache(block)
-
-	return s.blockRepository.Save(block)
+	}, nil
 }
 
 func (s *Service) getBlockTime(blockTime time.Time) uint64 {
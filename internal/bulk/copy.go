@@ -0,0 +1,165 @@
+package bulk
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/orm"
+	"github.com/noah-blockchain/noah-extender/internal/metrics"
+)
+
+// Ways to write batches of rows, see bulk_writer setting
+const (
+	// Multi-row INSERT built by go-pg
+	WriterInsert = "insert"
+	// COPY ... FROM STDIN in text format
+	WriterCopy = "copy"
+)
+
+const timestamptzFormat = "2006-01-02 15:04:05.999999999-07:00:00"
+
+// Rows encoded in the text format of COPY. Columns are appended in order of the COPY column list,
+// every row is finished by End.
+type Rows struct {
+	buf   []byte
+	count int
+	open  bool
+}
+
+// Count of finished rows
+func (r *Rows) Len() int {
+	return r.count
+}
+
+func (r *Rows) End() {
+	r.buf = append(r.buf, '\n')
+	r.open = false
+	r.count++
+}
+
+func (r *Rows) Null() {
+	r.column()
+	r.buf = append(r.buf, '\\', 'N')
+}
+
+func (r *Rows) Uint(v uint64) {
+	r.column()
+	r.buf = strconv.AppendUint(r.buf, v, 10)
+}
+
+func (r *Rows) Bool(v bool) {
+	r.column()
+	r.buf = strconv.AppendBool(r.buf, v)
+}
+
+func (r *Rows) String(v string) {
+	r.column()
+	r.appendEscaped(v)
+}
+
+// Empty string is written as NULL, like go-pg inserts zero values
+func (r *Rows) NullString(v string) {
+	if v == "" {
+		r.Null()
+		return
+	}
+	r.String(v)
+}
+
+// Text value such as JSON, nil is written as NULL
+func (r *Rows) Text(v []byte) {
+	if v == nil {
+		r.Null()
+		return
+	}
+	r.String(string(v))
+}
+
+// Bytea value, nil is written as NULL
+func (r *Rows) Bytes(v []byte) {
+	if v == nil {
+		r.Null()
+		return
+	}
+	r.column()
+	// backslash of the hex format is escaped
+	r.buf = append(r.buf, `\\x`...)
+	r.buf = appendHex(r.buf, v)
+}
+
+// Zero time is written as now, like created_at columns default to now()
+func (r *Rows) Time(v time.Time) {
+	r.column()
+	if v.IsZero() {
+		r.buf = append(r.buf, "now"...)
+		return
+	}
+	r.buf = v.UTC().AppendFormat(r.buf, timestamptzFormat)
+}
+
+func (r *Rows) column() {
+	if r.open {
+		r.buf = append(r.buf, '\t')
+	}
+	r.open = true
+}
+
+func (r *Rows) appendEscaped(v string) {
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; c {
+		case 0:
+			// NUL is not allowed in text values
+		case '\\':
+			r.buf = append(r.buf, '\\', '\\')
+		case '\n':
+			r.buf = append(r.buf, '\\', 'n')
+		case '\r':
+			r.buf = append(r.buf, '\\', 'r')
+		case '\t':
+			r.buf = append(r.buf, '\\', 't')
+		default:
+			r.buf = append(r.buf, c)
+		}
+	}
+}
+
+func appendHex(b []byte, v []byte) []byte {
+	const digits = "0123456789abcdef"
+	for _, c := range v {
+		b = append(b, digits[c>>4], digits[c&0x0f])
+	}
+	return b
+}
+
+// Copy rows into the columns of the table. COPY does not run query hooks, so insert duration is observed here.
+func Copy(db orm.DB, table string, columns []string, rows *Rows) error {
+	if rows.Len() == 0 {
+		return nil
+	}
+	start := time.Now()
+	query := fmt.Sprintf("COPY %s (%s) FROM STDIN", table, strings.Join(columns, ", "))
+	if _, err := db.CopyFrom(bytes.NewReader(rows.buf), query); err != nil {
+		return err
+	}
+	metrics.DbInsertDuration.WithLabelValues(table).Observe(time.Since(start).Seconds())
+	return nil
+}
+
+// Reserve count values of the sequence, they are used as ids of copied rows
+func NextIDs(db orm.DB, sequence string, count int) ([]uint64, error) {
+	var ids []uint64
+	if count == 0 {
+		return ids, nil
+	}
+	_, err := db.Query(&ids, `SELECT nextval(?) FROM generate_series(1, ?)`, sequence, count)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) != count {
+		return nil, fmt.Errorf("%d of %d values of %s reserved", len(ids), count, sequence)
+	}
+	return ids, nil
+}
@@ -0,0 +1,44 @@
+package bulk
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRows(t *testing.T) {
+	rows := new(Rows)
+	rows.Uint(42)
+	rows.String("a\tb\nc\\d\x00")
+	rows.NullString("")
+	rows.Text(nil)
+	rows.Text([]byte(`{"key":"value"}`))
+	rows.Bytes([]byte{0x01, 0xab})
+	rows.Bool(true)
+	rows.End()
+	rows.Time(time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("", 3600)))
+	rows.Time(time.Time{})
+	rows.End()
+
+	expected := "42\ta\\tb\\nc\\\\d\t\\N\t\\N\t{\"key\":\"value\"}\t\\\\x01ab\ttrue\n" +
+		"2020-01-02 02:04:05+00:00:00\tnow\n"
+	if string(rows.buf) != expected {
+		t.Errorf("Rows are encoded as %q, expected %q", rows.buf, expected)
+	}
+	if rows.Len() != 2 {
+		t.Errorf("Count of rows is %d, expected 2", rows.Len())
+	}
+}
+
+func BenchmarkRows(b *testing.B) {
+	payload := make([]byte, 64)
+	for i := 0; i < b.N; i++ {
+		rows := new(Rows)
+		for j := 0; j < 1000; j++ {
+			rows.Uint(uint64(j))
+			rows.String("1000000000000000000")
+			rows.Bytes(payload)
+			rows.Time(time.Time{})
+			rows.End()
+		}
+	}
+}
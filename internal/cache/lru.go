@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/noah-blockchain/noah-extender/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// LRU is a cache safe for concurrent use. Least recently used entries are evicted when the count of entries
+// exceeds the size, size <= 0 means the cache is not bounded.
+// Lookups, entries and evictions are exported as metrics labeled by name of the cache.
+type LRU struct {
+	size  int
+	mu    sync.Mutex
+	items map[interface{}]*list.Element
+	order *list.List
+
+	hits      prometheus.Counter
+	misses    prometheus.Counter
+	entries   prometheus.Gauge
+	evictions prometheus.Counter
+}
+
+type entry struct {
+	key   interface{}
+	value interface{}
+}
+
+func New(name string, size int) *LRU {
+	return &LRU{
+		size:      size,
+		items:     make(map[interface{}]*list.Element),
+		order:     list.New(),
+		hits:      metrics.CacheRequests.WithLabelValues(name, "hit"),
+		misses:    metrics.CacheRequests.WithLabelValues(name, "miss"),
+		entries:   metrics.CacheEntries.WithLabelValues(name),
+		evictions: metrics.CacheEvictions.WithLabelValues(name),
+	}
+}
+
+// Return value of the key and mark it as recently used, the lookup is counted in metrics
+func (c *LRU) Get(key interface{}) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.items[key]
+	if !ok {
+		c.misses.Inc()
+		return nil, false
+	}
+	c.hits.Inc()
+	c.order.MoveToFront(element)
+	return element.Value.(*entry).value, true
+}
+
+// Return value of the key without marking it as used and counting the lookup
+func (c *LRU) Peek(key interface{}) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	return element.Value.(*entry).value, true
+}
+
+// Add or replace value of the key, the least recently used entry is evicted if the cache is full
+func (c *LRU) Add(key, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.items[key]; ok {
+		element.Value.(*entry).value = value
+		c.order.MoveToFront(element)
+		return
+	}
+	c.items[key] = c.order.PushFront(&entry{key: key, value: value})
+	if c.size > 0 && c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+		c.evictions.Inc()
+	}
+	c.entries.Set(float64(c.order.Len()))
+}
+
+func (c *LRU) Remove(key interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.items[key]; ok {
+		c.removeElement(element)
+		c.entries.Set(float64(c.order.Len()))
+	}
+}
+
+// Remove all entries
+func (c *LRU) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = make(map[interface{}]*list.Element)
+	c.order.Init()
+	c.entries.Set(0)
+}
+
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRU) removeElement(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.items, element.Value.(*entry).key)
+}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/noah-blockchain/noah-extender/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestLRUEviction(t *testing.T) {
+	c := New("test_eviction", 2)
+	c.Add("a", uint64(1))
+	c.Add("b", uint64(2))
+	// a becomes recently used, so b is evicted
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a must be cached")
+	}
+	c.Add("c", uint64(3))
+
+	if _, ok := c.Peek("b"); ok {
+		t.Error("Least recently used entry must be evicted")
+	}
+	for key, expected := range map[string]uint64{"a": 1, "c": 3} {
+		if value, ok := c.Peek(key); !ok || value.(uint64) != expected {
+			t.Errorf("Value of %s is %v, expected %d", key, value, expected)
+		}
+	}
+	if c.Len() != 2 {
+		t.Errorf("Cache has %d entries, expected 2", c.Len())
+	}
+	if evictions := testutil.ToFloat64(metrics.CacheEvictions.WithLabelValues("test_eviction")); evictions != 1 {
+		t.Errorf("%v evictions counted, expected 1", evictions)
+	}
+}
+
+func TestLRURemove(t *testing.T) {
+	c := New("test_remove", 0)
+	for i := 0; i < 100; i++ {
+		c.Add(i, i)
+	}
+	if c.Len() != 100 {
+		t.Errorf("Unbounded cache has %d entries, expected 100", c.Len())
+	}
+	c.Remove(10)
+	if _, ok := c.Get(10); ok {
+		t.Error("Removed entry must not be found")
+	}
+	c.Purge()
+	if _, ok := c.Get(20); ok || c.Len() != 0 {
+		t.Error("Purged cache must be empty")
+	}
+
+	if misses := testutil.ToFloat64(metrics.CacheRequests.WithLabelValues("test_remove", "miss")); misses != 2 {
+		t.Errorf("%v misses counted, expected 2", misses)
+	}
+	if entries := testutil.ToFloat64(metrics.CacheEntries.WithLabelValues("test_remove")); entries != 0 {
+		t.Errorf("Entries gauge is %v, expected 0", entries)
+	}
+}
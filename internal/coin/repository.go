@@ -1,30 +1,40 @@
 package coin
 
 import (
-	"sync"
-
 	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
 	"github.com/noah-blockchain/coinExplorer-tools/models"
+	"github.com/noah-blockchain/noah-extender/internal/cache"
 )
 
 type Repository struct {
-	db       *pg.DB
-	cache    *sync.Map
-	invCache *sync.Map
+	db       orm.DB
+	cache    *cache.LRU
+	invCache *cache.LRU
 }
 
-func NewRepository(db *pg.DB) *Repository {
+// Each cache keeps up to cacheSize coins, 0 means caches are not bounded
+func NewRepository(db *pg.DB, cacheSize int) *Repository {
 	return &Repository{
 		db:       db,
-		cache:    new(sync.Map), //TODO: добавить реализацию очистки
-		invCache: new(sync.Map), //TODO: добавить реализацию очистки
+		cache:    cache.New("coin", cacheSize),
+		invCache: cache.New("coin_by_id", cacheSize),
+	}
+}
+
+// Return copy of repository which executes all queries within DB transaction, caches are shared
+func (r *Repository) WithTx(tx *pg.Tx) *Repository {
+	return &Repository{
+		db:       tx,
+		cache:    r.cache,
+		invCache: r.invCache,
 	}
 }
 
 // Find coin id by symbol
 func (r *Repository) FindIdBySymbol(symbol string) (uint64, error) {
 	//First look in the cache
-	id, ok := r.cache.Load(symbol)
+	id, ok := r.cache.Get(symbol)
 	if ok {
 		return id.(uint64), nil
 	}
@@ -37,7 +47,7 @@ func (r *Repository) FindIdBySymbol(symbol string) (uint64, error) {
 	if err != nil {
 		return 0, err
 	}
-	r.cache.Store(symbol, coin.ID)
+	r.cache.Add(symbol, coin.ID)
 	return coin.ID, nil
 }
 
@@ -57,7 +67,7 @@ func (r *Repository) FindCoinByID(id uint64) (*models.Coin, error) {
 
 func (r *Repository) FindSymbolById(id uint64) (string, error) {
 	//First look in the cache
-	symbol, ok := r.invCache.Load(id)
+	symbol, ok := r.invCache.Get(id)
 	if ok {
 		return symbol.(string), nil
 	}
@@ -70,11 +80,25 @@ func (r *Repository) FindSymbolById(id uint64) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	r.cache.Store(coin.Symbol, id)
-	r.invCache.Store(id, coin.Symbol)
+	r.cache.Add(coin.Symbol, id)
+	r.invCache.Add(id, coin.Symbol)
 	return coin.Symbol, nil
 }
 
+// Load ids and symbols of all coins into caches, returns count of loaded coins
+func (r *Repository) LoadAllToCache() (int, error) {
+	var coins []*models.Coin
+	err := r.db.Model(&coins).Column("id", "symbol").Select()
+	if err != nil {
+		return 0, err
+	}
+	for _, coin := range coins {
+		r.cache.Add(coin.Symbol, coin.ID)
+		r.invCache.Add(coin.ID, coin.Symbol)
+	}
+	return len(coins), nil
+}
+
 func (r *Repository) Save(c *models.Coin) error {
 	_, err := r.db.Model(c).
 		Where("symbol = ?symbol").
@@ -83,7 +107,7 @@ func (r *Repository) Save(c *models.Coin) error {
 	if err != nil {
 		return err
 	}
-	r.cache.Store(c.Symbol, c.ID)
+	r.cache.Add(c.Symbol, c.ID)
 	return nil
 }
 
@@ -93,12 +117,30 @@ func (r Repository) SaveAllIfNotExist(coins []*models.Coin) error {
 		return err
 	}
 	for _, coin := range coins {
-		r.cache.Store(coin.Symbol, coin.ID)
-		r.invCache.Store(coin.ID, coin.Symbol)
+		r.cache.Add(coin.Symbol, coin.ID)
+		r.invCache.Add(coin.ID, coin.Symbol)
 	}
 	return err
 }
 
+// Insert coins or update state of existing ones by symbol, creation and meta info of existing coins are kept
+func (r *Repository) SaveAllOrUpdate(coins []*models.Coin) error {
+	_, err := r.db.Model(&coins).
+		OnConflict("(symbol) DO UPDATE").
+		Set("name = EXCLUDED.name, crr = EXCLUDED.crr, volume = EXCLUDED.volume, reserve_balance = EXCLUDED.reserve_balance").
+		Set("price = EXCLUDED.price, capitalization = EXCLUDED.capitalization, updated_at = now(), deleted_at = null").
+		Returning("id").
+		Insert()
+	if err != nil {
+		return err
+	}
+	for _, coin := range coins {
+		r.cache.Add(coin.Symbol, coin.ID)
+		r.invCache.Add(coin.ID, coin.Symbol)
+	}
+	return nil
+}
+
 func (r *Repository) GetAllCoins() ([]*models.Coin, error) {
 	var coins []*models.Coin
 	err := r.db.Model(&coins).Order("symbol ASC").Select()
@@ -108,7 +150,20 @@ func (r *Repository) GetAllCoins() ([]*models.Coin, error) {
 func (r Repository) DeleteBySymbol(symbol string) error {
 	coin := &models.Coin{Symbol: symbol}
 	_, err := r.db.Model(coin).Where("symbol = ?symbol").Delete()
-	return err
+	if err != nil {
+		return err
+	}
+	r.Invalidate(symbol)
+	return nil
+}
+
+// Remove the coin from caches, it has to be called when the coin is deleted,
+// so a coin created again with the same symbol is not resolved to the old id
+func (r *Repository) Invalidate(symbol string) {
+	if id, ok := r.cache.Peek(symbol); ok {
+		r.invCache.Remove(id)
+	}
+	r.cache.Remove(symbol)
 }
 
 func (r *Repository) UpdateCoinDelegation(id uint64, delegated uint64) error {
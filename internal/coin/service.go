@@ -2,6 +2,7 @@ package coin
 
 import (
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/dgraph-io/badger"
@@ -13,7 +14,8 @@ import (
 	"github.com/noah-blockchain/coinExplorer-tools/models"
 	node_models "github.com/noah-blockchain/noah-explorer-tools/models"
 	"github.com/noah-blockchain/noah-extender/internal/address"
-	"github.com/noah-blockchain/noah-node-go-api"
+	"github.com/noah-blockchain/noah-extender/internal/failure"
+	"github.com/noah-blockchain/noah-extender/internal/node"
 	"github.com/noah-blockchain/noah-node-go-api/responses"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -21,32 +23,42 @@ import (
 
 type Service struct {
 	env                   *models.ExtenderEnvironment
-	nodeApi               *noah_node_go_api.NoahNodeApi
+	nodeApi               node.Client
 	repository            *Repository
 	addressRepository     *address.Repository
+	failureService        *failure.Service
 	logger                *logrus.Entry
 	jobUpdateCoins        chan []*models.Transaction
-	jobUpdateCoinsFromMap chan map[string]struct{}
+	jobUpdateCoinsFromMap chan BlockCoins
 	dbBadger              *badger.DB
 	ns                    stan.Conn
+	wgPublish             sync.WaitGroup
 }
 
-func NewService(env *models.ExtenderEnvironment, nodeApi *noah_node_go_api.NoahNodeApi, repository *Repository,
-	addressRepository *address.Repository, logger *logrus.Entry, dbBadger *badger.DB, ns stan.Conn) *Service {
+func NewService(env *models.ExtenderEnvironment, nodeApi node.Client, repository *Repository,
+	addressRepository *address.Repository, failureService *failure.Service, logger *logrus.Entry, dbBadger *badger.DB,
+	ns stan.Conn) *Service {
 
 	return &Service{
 		env:                   env,
 		nodeApi:               nodeApi,
 		repository:            repository,
 		addressRepository:     addressRepository,
+		failureService:        failureService,
 		logger:                logger,
 		jobUpdateCoins:        make(chan []*models.Transaction, 1),
-		jobUpdateCoinsFromMap: make(chan map[string]struct{}, 1),
+		jobUpdateCoinsFromMap: make(chan BlockCoins, 1),
 		dbBadger:              dbBadger,
 		ns:                    ns,
 	}
 }
 
+// Symbols of coins which have changed at the height, failures of their update are recorded at the height
+type BlockCoins struct {
+	Height uint64
+	Coins  map[string]struct{}
+}
+
 type CreateCoinData struct {
 	Name           string `json:"name"`
 	Symbol         string `json:"symbol"`
@@ -59,7 +71,7 @@ func (s *Service) GetUpdateCoinsFromTxsJobChannel() chan []*models.Transaction {
 	return s.jobUpdateCoins
 }
 
-func (s *Service) GetUpdateCoinsFromCoinsMapJobChannel() chan map[string]struct{} {
+func (s *Service) GetUpdateCoinsFromCoinsMapJobChannel() chan BlockCoins {
 	return s.jobUpdateCoinsFromMap
 }
 
@@ -72,7 +84,7 @@ func AppendIfMissing(slice []*models.Coin, c *models.Coin) []*models.Coin {
 	return append(slice, c)
 }
 
-func (s Service) ExtractCoinsFromTransactions(transactions []responses.Transaction) ([]*models.Coin, error) {
+func (s *Service) ExtractCoinsFromTransactions(transactions []responses.Transaction) ([]*models.Coin, error) {
 	var coins []*models.Coin
 	for _, tx := range transactions {
 		if tx.Type != models.TxTypeCreateCoin {
@@ -97,11 +109,11 @@ func (s Service) ExtractCoinsFromTransactions(transactions []responses.Transacti
 func (s *Service) ExtractFromTx(tx responses.Transaction) (*models.Coin, error) {
 	if tx.Data == nil {
 		s.logger.Warn("empty transaction data")
-		return nil, errors.New("no data for creating a coin")
+		return nil, failure.Permanent(errors.New("no data for creating a coin"))
 	}
 
 	if tx.Log != nil {
-		return nil, errors.New(*tx.Log)
+		return nil, failure.Permanent(errors.New(*tx.Log))
 	}
 
 	txData := tx.IData.(node_models.CreateCoinTxData)
@@ -134,6 +146,7 @@ func (s *Service) ExtractFromTx(tx responses.Transaction) (*models.Coin, error)
 			s.logger.Error(err)
 		}(coin.Symbol, helpers.RemovePrefix(tx.Hash))
 
+		s.wgPublish.Add(1)
 		go s.eventCoinMessage(&coin_extender.Coin{
 			Symbol:         coin.Symbol,
 			Price:          coin.Price,
@@ -157,6 +170,9 @@ func (s *Service) CreateNewCoins(coins []*models.Coin) error {
 
 func (s *Service) UpdateCoinsInfoFromTxsWorker(jobs <-chan []*models.Transaction) {
 	for transactions := range jobs {
+		if len(transactions) == 0 {
+			continue
+		}
 		coinsMap := make(map[string]struct{})
 		// Find coins in transaction for update
 		for _, tx := range transactions {
@@ -178,12 +194,13 @@ func (s *Service) UpdateCoinsInfoFromTxsWorker(jobs <-chan []*models.Transaction
 				coinsMap[tx.IData.(node_models.SellAllCoinTxData).CoinToSell] = struct{}{}
 			}
 		}
-		s.GetUpdateCoinsFromCoinsMapJobChannel() <- coinsMap
+		s.GetUpdateCoinsFromCoinsMapJobChannel() <- BlockCoins{Height: transactions[0].BlockID, Coins: coinsMap}
 	}
 }
 
-func (s Service) UpdateCoinsInfoFromCoinsMap(job <-chan map[string]struct{}) {
-	for coinsMap := range job {
+func (s *Service) UpdateCoinsInfoFromCoinsMap(job <-chan BlockCoins) {
+	for blockCoins := range job {
+		coinsMap := blockCoins.Coins
 		delete(coinsMap, s.env.BaseCoin)
 		if len(coinsMap) > 0 {
 			coinsForUpdate := make([]string, len(coinsMap))
@@ -192,16 +209,20 @@ func (s Service) UpdateCoinsInfoFromCoinsMap(job <-chan map[string]struct{}) {
 				coinsForUpdate[i] = symbol
 				i++
 			}
-			err := s.UpdateCoinsInfo(coinsForUpdate)
-			if err != nil {
-				s.logger.Error(err)
-			}
+			_ = s.failureService.DoPayload(failure.StageCoins, blockCoins.Height, coinsForUpdate, func() error {
+				return s.UpdateCoinsInfo(coinsForUpdate)
+			})
 		}
 	}
 }
 
+// Coins which have been received from the node are saved even if other coins have failed,
+// the last error of the node is returned then
 func (s *Service) UpdateCoinsInfo(symbols []string) error {
-	var coins []*models.Coin
+	var (
+		coins   []*models.Coin
+		nodeErr error
+	)
 	for _, symbol := range symbols {
 		if symbol == s.env.BaseCoin {
 			continue
@@ -209,14 +230,17 @@ func (s *Service) UpdateCoinsInfo(symbols []string) error {
 		coin, err := s.GetCoinFromNode(symbol)
 		if err != nil {
 			s.logger.Error(err)
+			nodeErr = err
 			continue
 		}
 		coins = append(coins, coin)
 	}
 	if len(coins) > 0 {
-		return s.repository.SaveAllIfNotExist(coins)
+		if err := s.repository.SaveAllIfNotExist(coins); err != nil {
+			return err
+		}
 	}
-	return nil
+	return nodeErr
 }
 
 func (s *Service) GetCoinFromNode(symbol string) (*models.Coin, error) {
@@ -251,6 +275,7 @@ func (s *Service) GetCoinFromNode(symbol string) (*models.Coin, error) {
 	coin.Capitalization = GetCapitalization(coin.Volume, coin.Price)
 
 	if coin.Symbol != s.env.BaseCoin {
+		s.wgPublish.Add(1)
 		go s.eventCoinMessage(&coin_extender.Coin{
 			Symbol:         coin.Symbol,
 			Price:          coin.Price,
@@ -279,6 +304,7 @@ func (s *Service) SelectCoinsWithBrokenMeta() (*[]models.Coin, error) {
 }
 
 func (s *Service) eventCoinMessage(coin *coin_extender.Coin) {
+	defer s.wgPublish.Done()
 	data, _ := proto.Marshal(coin)
 
 	err := s.ns.Publish(helpers.CoinCreatedSubject, data)
@@ -286,3 +312,8 @@ func (s *Service) eventCoinMessage(coin *coin_extender.Coin) {
 		s.logger.Error(errors.WithStack(err))
 	}
 }
+
+// Wait until all coin messages are published to NATS
+func (s *Service) WaitPublished() {
+	s.wgPublish.Wait()
+}
@@ -0,0 +1,76 @@
+package coin
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/noah-blockchain/coinExplorer-tools/models"
+	"github.com/noah-blockchain/noah-extender/internal/failure"
+	"github.com/noah-blockchain/noah-node-go-api/responses"
+	"github.com/sirupsen/logrus"
+)
+
+// Node which is not able to return coin info
+type failingNode struct {
+	coinCalls int
+}
+
+func (n *failingNode) GetStatus() (*responses.StatusResponse, error) {
+	return nil, errors.New("node is down")
+}
+
+func (n *failingNode) GetBlock(height uint64) (*responses.BlockResponse, error) {
+	return nil, errors.New("node is down")
+}
+
+func (n *failingNode) GetBlockEvents(height uint64) (*responses.EventsResponse, error) {
+	return nil, errors.New("node is down")
+}
+
+func (n *failingNode) GetCandidates(height uint64, stakes bool) (*responses.BlockCandidatesResponse, error) {
+	return nil, errors.New("node is down")
+}
+
+func (n *failingNode) GetCoinInfo(symbol string) (*responses.CoinInfoResponse, error) {
+	n.coinCalls++
+	return nil, errors.New("node is down")
+}
+
+func (n *failingNode) GetAddresses(addresses []string, height uint64) (*responses.BalancesResponse, error) {
+	return nil, errors.New("node is down")
+}
+
+type fakeDeadLetters struct {
+	heights []uint64
+}
+
+func (d *fakeDeadLetters) Put(stage failure.Stage, height uint64, payload interface{}, err error) error {
+	d.heights = append(d.heights, height)
+	return nil
+}
+
+func TestUpdateCoinsFailureIsRecordedAtHeight(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	entry := logrus.NewEntry(logger)
+	deadLetters := new(fakeDeadLetters)
+	backoffs := failure.Backoffs{failure.StageCoins: {Initial: time.Millisecond, Max: time.Millisecond, Attempts: 1}}
+	failureService := failure.NewService(failure.PolicyQuarantine, backoffs, nil, deadLetters, entry)
+	node := new(failingNode)
+	service := NewService(&models.ExtenderEnvironment{BaseCoin: "NOAH"}, node, nil, nil, failureService, entry, nil, nil)
+
+	jobs := make(chan BlockCoins, 2)
+	jobs <- BlockCoins{Height: 42, Coins: map[string]struct{}{"TESTCOIN": {}}}
+	jobs <- BlockCoins{Height: 43, Coins: map[string]struct{}{"TESTCOIN": {}}}
+	close(jobs)
+	service.UpdateCoinsInfoFromCoinsMap(jobs)
+
+	if len(deadLetters.heights) != 2 || deadLetters.heights[0] != 42 || deadLetters.heights[1] != 43 {
+		t.Error("Failed coin updates must be recorded at their heights, got ", deadLetters.heights)
+	}
+	if node.coinCalls != 2 {
+		t.Error("Quarantined height must not skip coin updates of later heights, node calls ", node.coinCalls)
+	}
+}
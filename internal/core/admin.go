@@ -0,0 +1,163 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/noah-blockchain/coinExplorer-tools/helpers"
+	"github.com/noah-blockchain/noah-extender/internal/api"
+	"github.com/sirupsen/logrus"
+)
+
+const AdminReindexQueueSize = 16
+
+var ErrReindexQueueFull = errors.New("reindex queue is full")
+
+// Range of heights to reindex requested by the admin API
+type reindexJob struct {
+	from     uint64
+	to       uint64
+	stages   []string
+	selected map[string]bool
+}
+
+// Gate of the ingestion loop, the loop waits while it is paused
+type pauseGate struct {
+	mu sync.Mutex
+	// closed on resume, nil while ingestion is running
+	resumed chan struct{}
+}
+
+func (g *pauseGate) pause() {
+	g.mu.Lock()
+	if g.resumed == nil {
+		g.resumed = make(chan struct{})
+	}
+	g.mu.Unlock()
+}
+
+func (g *pauseGate) resume() {
+	g.mu.Lock()
+	if g.resumed != nil {
+		close(g.resumed)
+		g.resumed = nil
+	}
+	g.mu.Unlock()
+}
+
+func (g *pauseGate) isPaused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.resumed != nil
+}
+
+// Block while the gate is paused, returns false if ctx is done before resume
+func (g *pauseGate) wait(ctx context.Context) bool {
+	g.mu.Lock()
+	resumed := g.resumed
+	g.mu.Unlock()
+	if resumed == nil {
+		return true
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-resumed:
+		return true
+	}
+}
+
+// Request the latest balances of the addresses from the node and save them
+func (ext *Extender) RefreshBalances(addresses []string) error {
+	if len(addresses) == 0 {
+		return errors.New("no addresses")
+	}
+	list := make([]string, len(addresses))
+	for i, address := range addresses {
+		list[i] = helpers.RemovePrefixFromAddress(address)
+	}
+	if err := ext.addressRepository.SaveAllIfNotExist(list); err != nil {
+		return err
+	}
+	return ext.balanceService.UpdateBalances(list)
+}
+
+// Request info of the coin from the node and save it
+func (ext *Extender) RefreshCoin(symbol string) error {
+	if symbol == "" || symbol == ext.env.BaseCoin {
+		return fmt.Errorf("coin %q has no info to refresh", symbol)
+	}
+	return ext.coinService.UpdateCoinsInfo([]string{symbol})
+}
+
+// Aggregate again rewards of blocks from..to
+func (ext *Extender) AggregateRewards(from, to uint64) error {
+	if from == 0 || to < from {
+		return fmt.Errorf("invalid height range %d..%d", from, to)
+	}
+	return ext.eventService.RebuildAggregatedRewards(ext.env.RewardAggregateTimeInterval, from, to)
+}
+
+// Stop the ingestion loop after the current height, pipeline and background workers keep running
+func (ext *Extender) PauseIngestion() {
+	ext.paused.pause()
+	ext.logger.Warn("ingestion has been paused")
+}
+
+func (ext *Extender) ResumeIngestion() {
+	ext.paused.resume()
+	ext.logger.Warn("ingestion has been resumed")
+}
+
+// Queue the range to be reindexed by the running extender, all reindex stages are selected if stages are empty
+func (ext *Extender) EnqueueReindex(from, to uint64, stages []string) error {
+	if len(stages) == 0 {
+		stages = ReindexStages
+	}
+	selected, err := ext.checkReindex(from, to, stages)
+	if _, ok := err.(invalidReindexError); ok {
+		return api.BadRequest(err)
+	}
+	if err != nil {
+		return err
+	}
+	select {
+	case ext.reindexJobs <- reindexJob{from: from, to: to, stages: stages, selected: selected}:
+		return nil
+	default:
+		return ErrReindexQueueFull
+	}
+}
+
+func (ext *Extender) reindexWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-ext.reindexJobs:
+			logger := ext.logger.WithFields(logrus.Fields{
+				"from":   job.from,
+				"to":     job.to,
+				"stages": job.stages,
+			})
+			if err := ext.reindexJob(ctx, job); err != nil {
+				logger.Error(err)
+				continue
+			}
+			logger.Warn("range has been reindexed")
+		}
+	}
+}
+
+// Reindex the range while the tip is not ingested, the range is checked again as blocks may have been rolled back
+func (ext *Extender) reindexJob(ctx context.Context, job reindexJob) error {
+	ext.ranges.Lock()
+	defer ext.ranges.Unlock()
+
+	if _, err := ext.checkReindex(job.from, job.to, job.stages); err != nil {
+		return err
+	}
+	return ext.reindexRange(ctx, job.from, job.to, job.selected)
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPauseGate(t *testing.T) {
+	gate := new(pauseGate)
+	if !gate.wait(context.Background()) {
+		t.Fatal("Running gate must not block")
+	}
+
+	gate.pause()
+	gate.pause()
+	resumed := make(chan bool)
+	go func() { resumed <- gate.wait(context.Background()) }()
+	select {
+	case <-resumed:
+		t.Fatal("Paused gate must block")
+	case <-time.After(10 * time.Millisecond):
+	}
+	gate.resume()
+	if !<-resumed || gate.isPaused() {
+		t.Error("Resumed gate must release waiters")
+	}
+
+	gate.pause()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if gate.wait(ctx) {
+		t.Error("Paused gate must return false when context is done")
+	}
+}
+
+func TestCheckReindexRejectsInvalidRequest(t *testing.T) {
+	ext := new(Extender)
+	for _, c := range []struct {
+		from, to uint64
+		stages   []string
+	}{
+		{1, 2, []string{"unknown"}},
+		{1, 2, nil},
+		{0, 2, ReindexStages},
+		{3, 2, ReindexStages},
+	} {
+		if _, err := ext.checkReindex(c.from, c.to, c.stages); err == nil {
+			t.Errorf("Request %+v must be rejected", c)
+		} else if _, ok := err.(invalidReindexError); !ok {
+			t.Errorf("Request %+v must be rejected as invalid, got %v", c, err)
+		}
+	}
+}
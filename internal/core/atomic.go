@@ -0,0 +1,96 @@
+package core
+
+import (
+	"github.com/go-pg/pg"
+	"github.com/noah-blockchain/coinExplorer-tools/models"
+	"github.com/noah-blockchain/noah-extender/internal/coin"
+	"github.com/noah-blockchain/noah-extender/internal/cursor"
+	"github.com/noah-blockchain/noah-extender/internal/failure"
+	"github.com/noah-blockchain/noah-node-go-api/responses"
+)
+
+// Save all data which belongs to the block height within a single DB transaction,
+// so the height is either fully present in DB or absent.
+// Addresses, validators and coins are shared between heights and saved before the transaction.
+// Failed jobs are handled by the failure policy, returned error is failure.ErrHalted only.
+func (ext *Extender) handleBlockAtomic(height uint64, blockResponse *responses.BlockResponse,
+	eventsResponse *responses.EventsResponse) error {
+
+	if err := ext.handleAddressesFromResponses(height, blockResponse, eventsResponse); err != nil {
+		return err
+	}
+	ext.cursorService.Seal(cursor.StageBalances, height)
+
+	var validators []*models.Validator
+	err := ext.failureService.Do(failure.StageValidators, height, func() (err error) {
+		validators, err = ext.validatorService.HandleBlockResponse(blockResponse)
+		return err
+	})
+	if err == failure.ErrHalted {
+		return err
+	}
+
+	if err = ext.handleCoinsFromTransactions(height, blockResponse.Result.Transactions); err != nil {
+		return err
+	}
+
+	var block *models.Block
+	// coins are updated after commit, updates sent within the transaction would see uncommitted data or rolled back one
+	var txs []*models.Transaction
+	var eventCoins map[string]struct{}
+	err = ext.failureService.Do(failure.StageBlock, height, func() error {
+		return ext.db.RunInTransaction(func(tx *pg.Tx) (err error) {
+			txs, eventCoins = nil, nil
+			block, err = ext.blockService.HandleBlockResponseTx(tx, blockResponse)
+			if err != nil {
+				return err
+			}
+
+			if height > 1 {
+				links, err := ext.getBlockValidatorLinks(*blockResponse)
+				if err != nil {
+					return err
+				}
+				if len(links) > 0 {
+					if err = ext.blockRepository.WithTx(tx).LinkWithValidators(links); err != nil {
+						return err
+					}
+				}
+			}
+
+			//first block don't have validators
+			if blockResponse.Result.TxCount != "0" && len(validators) > 0 {
+				txs, err = ext.transactionService.HandleTransactionsTx(tx, height, blockResponse.Result.Time, blockResponse.Result.Transactions)
+				if err != nil {
+					return err
+				}
+			}
+
+			if eventsResponse != nil && len(eventsResponse.Result.Events) > 0 {
+				eventCoins, err = ext.eventService.HandleEventResponseTx(tx, height, eventsResponse)
+			}
+			return err
+		})
+	})
+	if err == failure.ErrHalted {
+		return err
+	}
+
+	// stages of a failed height are not sealed, so the cursor does not pass the height which has not been written
+	if err == nil {
+		if block != nil {
+			ext.blockService.SetBlockCache(block)
+		}
+		if len(txs) > 0 {
+			ext.coinService.GetUpdateCoinsFromTxsJobChannel() <- txs
+		}
+		if len(eventCoins) > 0 {
+			ext.coinService.GetUpdateCoinsFromCoinsMapJobChannel() <- coin.BlockCoins{Height: height, Coins: eventCoins}
+		}
+		for _, stage := range []cursor.Stage{cursor.StageBlock, cursor.StageTxs, cursor.StageEvents} {
+			ext.cursorService.Seal(stage, height)
+		}
+	}
+	ext.updateValidators(height)
+	return nil
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Fetch heights concurrently from the node and handle them in height order
+// until ingestion is within ChasingModDiff of the node height.
+// Returns the next height to ingest by the tip-following loop.
+func (ext *Extender) backfill(ctx context.Context, height uint64) uint64 {
+	for {
+		nodeHeight, err := ext.getNodeLastBlockId()
+		if err != nil {
+			time.Sleep(2 * time.Second)
+			return height
+		}
+		ext.currentNodeHeight = nodeHeight
+		if nodeHeight < height+ChasingModDiff {
+			ext.chasingMode = false
+			return height
+		}
+
+		to := nodeHeight - ChasingModDiff
+		ext.logger.WithFields(logrus.Fields{
+			"from": height,
+			"to":   to,
+		}).Info("backfill")
+
+		fetchCtx, cancel := context.WithCancel(ctx)
+		for fetched := range fetchOrdered(fetchCtx, height, to, ext.env.BackfillWorkers, ext.fetchHeight) {
+			if fetched.err != nil {
+				ext.reportError(fetched.err)
+				cancel()
+				time.Sleep(2 * time.Second)
+				return height
+			}
+			if ext.paused.isPaused() {
+				cancel()
+				return height
+			}
+			// lock is held per height, so admin reindex is not blocked for the whole backfill
+			ext.ranges.Lock()
+			err = ext.handleHeight(height, fetched.blockResponse, fetched.eventsResponse)
+			ext.ranges.Unlock()
+			if err != nil {
+				cancel()
+				return height
+			}
+			height++
+		}
+		cancel()
+
+		if ctx.Err() != nil {
+			return height
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"context"
+	"errors"
+
+	"github.com/noah-blockchain/coinExplorer-tools/models"
+	"github.com/noah-blockchain/noah-extender/internal/dlq"
+	"github.com/noah-blockchain/noah-extender/internal/failure"
+	"github.com/sirupsen/logrus"
+)
+
+// Jobs of these stages have no payload and are replayed by reindex of their height
+var deadLetterReindexStages = map[failure.Stage]string{
+	failure.StageTxs:          ReindexTxs,
+	failure.StageTxOutputs:    ReindexTxs,
+	failure.StageTxValidators: ReindexTxs,
+	failure.StageEvents:       ReindexEvents,
+	failure.StageValidators:   ReindexValidators,
+}
+
+// Run again jobs from dead-letter store of the stage, all stages if it is empty.
+// Replayed jobs are deleted from the store, jobs which fail again are kept.
+// Returns count of replayed and kept jobs.
+func (ext *Extender) ReplayDeadLetters(ctx context.Context, stage failure.Stage) (replayed, kept int, err error) {
+	if ext.deadLetters == nil {
+		return 0, 0, errors.New("dead-letter store is not available")
+	}
+	entries, err := ext.deadLetters.List(stage)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	ext.runPipelineWorkers()
+	for _, entry := range entries {
+		if err = ctx.Err(); err != nil {
+			return replayed, kept, err
+		}
+
+		logger := ext.logger.WithFields(logrus.Fields{
+			"stage":  entry.Stage,
+			"height": entry.Height,
+		})
+		if err = ext.replayDeadLetter(ctx, entry); err != nil {
+			logger.Error(err)
+			kept++
+			continue
+		}
+		if err = ext.deadLetters.Delete(entry); err != nil {
+			return replayed, kept, err
+		}
+		logger.Info("replayed")
+		replayed++
+	}
+	return replayed, kept, nil
+}
+
+func (ext *Extender) replayDeadLetter(ctx context.Context, entry *dlq.Entry) error {
+	if reindexStage, ok := deadLetterReindexStages[entry.Stage]; ok {
+		return ext.reindexRange(ctx, entry.Height, entry.Height, map[string]bool{reindexStage: true})
+	}
+
+	switch entry.Stage {
+	case failure.StageBalances:
+		// balances of the height may be outdated already, the latest ones are requested
+		var job models.BlockAddresses
+		if err := entry.DecodePayload(&job); err != nil {
+			return err
+		}
+		return ext.balanceService.UpdateBalances(job.Addresses)
+	case failure.StageCoins:
+		var symbols []string
+		if err := entry.DecodePayload(&symbols); err != nil {
+			return err
+		}
+		return ext.coinService.UpdateCoinsInfo(symbols)
+	case failure.StageRewards:
+		var rewards []*models.Reward
+		if err := entry.DecodePayload(&rewards); err != nil {
+			return err
+		}
+		if err := ext.eventService.ReplayRewards(rewards); err != nil {
+			return err
+		}
+		return ext.eventService.RebuildAggregatedRewards(ext.env.RewardAggregateTimeInterval, entry.Height, entry.Height)
+	case failure.StageSlashes:
+		var slashes []*models.Slash
+		if err := entry.DecodePayload(&slashes); err != nil {
+			return err
+		}
+		return ext.eventService.ReplaySlashes(slashes)
+	case failure.StageAggregation:
+		from := uint64(1)
+		if interval := uint64(ext.env.RewardAggregateEveryBlocksCount); entry.Height > interval {
+			from = entry.Height - interval + 1
+		}
+		return ext.eventService.RebuildAggregatedRewards(ext.env.RewardAggregateTimeInterval, from, entry.Height)
+	}
+	return errors.New("job of the stage can not be replayed, reindex the height or purge the job")
+}
+
+// Avoid non-nil interface holding nil store
+func deadLetterStore(store *dlq.Store) failure.DeadLetterStore {
+	if store == nil {
+		return nil
+	}
+	return store
+}
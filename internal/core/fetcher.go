@@ -0,0 +1,69 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/noah-blockchain/noah-node-go-api/responses"
+)
+
+type fetchedHeight struct {
+	height         uint64
+	blockResponse  *responses.BlockResponse
+	eventsResponse *responses.EventsResponse
+	err            error
+}
+
+// Fetch block and events of the height from the node
+func (ext *Extender) fetchHeight(height uint64) *fetchedHeight {
+	result := &fetchedHeight{height: height}
+
+	result.blockResponse, result.err = ext.nodeApi.GetBlock(height)
+	if result.err != nil {
+		return result
+	}
+	if result.blockResponse.Error != nil {
+		result.err = fmt.Errorf("get block %d: %s", height, result.blockResponse.Error.Message)
+		return result
+	}
+
+	result.eventsResponse, result.err = ext.nodeApi.GetBlockEvents(height)
+	return result
+}
+
+// Fetch heights from..to with up to window concurrent requests.
+// Results are sent to the returned channel strictly in height order, the channel is closed
+// after the last height or when ctx is done.
+func fetchOrdered(ctx context.Context, from, to uint64, window int, fetch func(uint64) *fetchedHeight) <-chan *fetchedHeight {
+	if window < 1 {
+		window = 1
+	}
+	pending := make(chan chan *fetchedHeight, window)
+	go func() {
+		defer close(pending)
+		for height := from; height <= to; height++ {
+			result := make(chan *fetchedHeight, 1)
+			select {
+			case pending <- result:
+			case <-ctx.Done():
+				return
+			}
+			go func(height uint64) {
+				result <- fetch(height)
+			}(height)
+		}
+	}()
+
+	results := make(chan *fetchedHeight)
+	go func() {
+		defer close(results)
+		for result := range pending {
+			select {
+			case results <- <-result:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return results
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchOrderedKeepsHeightOrder(t *testing.T) {
+	var inFlight, maxInFlight int32
+	fetch := func(height uint64) *fetchedHeight {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		// later heights are fetched faster
+		time.Sleep(time.Duration(20-height) * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return &fetchedHeight{height: height}
+	}
+
+	next := uint64(1)
+	for fetched := range fetchOrdered(context.Background(), 1, 15, 4, fetch) {
+		if fetched.height != next {
+			t.Fatal("Height must be ", next, " but now ", fetched.height)
+		}
+		next++
+	}
+	if next != 16 {
+		t.Error("All heights must be fetched but last is ", next-1)
+	}
+	if maxInFlight < 2 {
+		t.Error("Heights must be fetched concurrently")
+	}
+	if maxInFlight > 6 {
+		t.Error("Too many concurrent fetches ", maxInFlight)
+	}
+}
+
+func TestFetchOrderedStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	fetch := func(height uint64) *fetchedHeight {
+		return &fetchedHeight{height: height}
+	}
+
+	results := fetchOrdered(ctx, 1, 1000000, 2, fetch)
+	<-results
+	cancel()
+
+	count := 0
+	for range results {
+		count++
+	}
+	if count > 10 {
+		t.Error("Fetching must stop after cancel but got ", count, " more heights")
+	}
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/go-pg/pg"
+	"github.com/noah-blockchain/coinExplorer-tools/models"
+	"github.com/noah-blockchain/noah-extender/internal/node"
+	"github.com/sirupsen/logrus"
+)
+
+const MaxRollbackDepth = 1000
+
+// Hashes of stored blocks, implemented by block.Repository
+type blockHashStore interface {
+	FindHashById(id uint64) (string, error)
+}
+
+// Compare the hash of the parent block stored in DB with the one returned by the node.
+// Returns the first height that has to be (re)ingested, it is equal to height if there is no divergence.
+func (ext *Extender) findForkHeight(height uint64) (uint64, error) {
+	return forkHeight(height, ext.blockRepository, ext.nodeApi, ext.logger)
+}
+
+func forkHeight(height uint64, store blockHashStore, nodeApi node.Client, logger *logrus.Entry) (uint64, error) {
+	for parent := height - 1; parent > 0; parent-- {
+		storedHash, err := store.FindHashById(parent)
+		if err == pg.ErrNoRows {
+			return parent + 1, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		blockResponse, err := nodeApi.GetBlock(parent)
+		if err != nil {
+			return 0, err
+		}
+		if blockResponse.Error != nil {
+			return 0, fmt.Errorf("get block %d: %s", parent, blockResponse.Error.Message)
+		}
+
+		if blockResponse.Result.Hash == storedHash {
+			return parent + 1, nil
+		}
+
+		logger.WithFields(logrus.Fields{
+			"height":      parent,
+			"stored_hash": storedHash,
+			"node_hash":   blockResponse.Result.Hash,
+		}).Warn("block hash mismatch")
+		// parent..height-1 have to be rolled back
+		if height-parent > MaxRollbackDepth {
+			return 0, fmt.Errorf("chain divergence is deeper than %d blocks from height %d", MaxRollbackDepth, height)
+		}
+	}
+	return 1, nil
+}
+
+// Roll back blocks from the fork if the stored parent of the height is not on the node chain.
+// Returns the height to be ingested next.
+func (ext *Extender) rollbackFork(height uint64) (uint64, error) {
+	ext.ranges.Lock()
+	defer ext.ranges.Unlock()
+
+	forkHeight, err := ext.findForkHeight(height)
+	if err != nil || forkHeight == height {
+		return height, err
+	}
+	if err = ext.rollback(forkHeight); err != nil {
+		return height, err
+	}
+	return forkHeight, nil
+}
+
+// Delete all blocks starting from height and refresh balances of affected addresses
+func (ext *Extender) rollback(height uint64) error {
+	addresses, err := ext.addressRepository.FindAllChangedFromBlock(height)
+	if err != nil {
+		return err
+	}
+
+	if err = ext.blockRepository.DeleteBlocksFrom(height); err != nil {
+		return err
+	}
+	if err = ext.cursorService.Rewind(height - 1); err != nil {
+		return err
+	}
+
+	lastExplorerBlock, err := ext.blockRepository.GetLastFromDB()
+	if err != nil && err != pg.ErrNoRows {
+		return err
+	}
+	ext.blockService.SetBlockCache(lastExplorerBlock)
+
+	ext.logger.WithFields(logrus.Fields{
+		"height":    height,
+		"addresses": len(addresses),
+	}).Warn("blocks have been rolled back")
+
+	if len(addresses) > 0 && height > 1 {
+		ext.balanceService.GetAddressesChannel() <- models.BlockAddresses{Height: height - 1, Addresses: addresses}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package core
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/go-pg/pg"
+	"github.com/noah-blockchain/noah-node-go-api/responses"
+	"github.com/sirupsen/logrus"
+)
+
+type fakeHashStore map[uint64]string
+
+func (s fakeHashStore) FindHashById(id uint64) (string, error) {
+	hash, ok := s[id]
+	if !ok {
+		return "", pg.ErrNoRows
+	}
+	return hash, nil
+}
+
+// Node which returns blocks of the hashes, other node methods are not used by fork detection
+type fakeForkNode struct {
+	hashes map[uint64]string
+}
+
+func (n *fakeForkNode) GetStatus() (*responses.StatusResponse, error) {
+	return new(responses.StatusResponse), nil
+}
+
+func (n *fakeForkNode) GetBlock(height uint64) (*responses.BlockResponse, error) {
+	hash, ok := n.hashes[height]
+	if !ok {
+		return nil, errors.New("block is not produced")
+	}
+	response := new(responses.BlockResponse)
+	response.Result.Hash = hash
+	return response, nil
+}
+
+func (n *fakeForkNode) GetBlockEvents(height uint64) (*responses.EventsResponse, error) {
+	return new(responses.EventsResponse), nil
+}
+
+func (n *fakeForkNode) GetCandidates(height uint64, stakes bool) (*responses.BlockCandidatesResponse, error) {
+	return new(responses.BlockCandidatesResponse), nil
+}
+
+func (n *fakeForkNode) GetCoinInfo(symbol string) (*responses.CoinInfoResponse, error) {
+	return new(responses.CoinInfoResponse), nil
+}
+
+func (n *fakeForkNode) GetAddresses(addresses []string, height uint64) (*responses.BalancesResponse, error) {
+	return new(responses.BalancesResponse), nil
+}
+
+// Chain of stored blocks 1..stored and node chain which differs from the stored one from height forkedFrom
+func forkChains(stored, forkedFrom uint64) (fakeHashStore, *fakeForkNode) {
+	store := make(fakeHashStore)
+	nodeApi := &fakeForkNode{hashes: make(map[uint64]string)}
+	for height := uint64(1); height <= stored; height++ {
+		store[height] = "stored"
+		nodeApi.hashes[height] = "stored"
+		if forkedFrom > 0 && height >= forkedFrom {
+			nodeApi.hashes[height] = "forked"
+		}
+	}
+	return store, nodeApi
+}
+
+func discardLogger() *logrus.Entry {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	return logrus.NewEntry(logger)
+}
+
+func TestForkHeight(t *testing.T) {
+	tests := []struct {
+		name       string
+		stored     uint64
+		forkedFrom uint64
+		expected   uint64
+		fails      bool
+	}{
+		{name: "matching parent", stored: 100, expected: 101},
+		{name: "fork of the last block", stored: 100, forkedFrom: 100, expected: 100},
+		{name: "fork 10 blocks deep", stored: 100, forkedFrom: 91, expected: 91},
+		{name: "fork of all blocks", stored: 100, forkedFrom: 1, expected: 1},
+		{name: "fork at max rollback depth", stored: MaxRollbackDepth + 10, forkedFrom: 11, expected: 11},
+		{name: "fork deeper than max rollback depth", stored: MaxRollbackDepth + 10, forkedFrom: 10, fails: true},
+		{name: "nothing stored", stored: 0, expected: 1},
+	}
+	logger := discardLogger()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store, nodeApi := forkChains(test.stored, test.forkedFrom)
+			height, err := forkHeight(test.stored+1, store, nodeApi, logger)
+			if test.fails {
+				if err == nil {
+					t.Errorf("Fork deeper than %d blocks must fail, got height %d", MaxRollbackDepth, height)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if height != test.expected {
+				t.Errorf("Fork height is %d, expected %d", height, test.expected)
+			}
+		})
+	}
+}
+
+func TestForkHeightNodeError(t *testing.T) {
+	store, nodeApi := forkChains(10, 0)
+	delete(nodeApi.hashes, 10)
+	if _, err := forkHeight(11, store, nodeApi, discardLogger()); err == nil {
+		t.Error("Node error must be returned")
+	}
+}
@@ -0,0 +1,308 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/noah-blockchain/coinExplorer-tools/helpers"
+	"github.com/noah-blockchain/coinExplorer-tools/models"
+	"github.com/noah-blockchain/noah-extender/internal/coin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	genesisChunkSize    = 1000
+	genesisFetchTimeout = time.Minute
+)
+
+// Read genesis from a genesis.json file or from a saved response of the node genesis method
+func ReadGenesis(r io.Reader) (*Genesis, error) {
+	var document struct {
+		Genesis
+		Result *struct {
+			Genesis Genesis `json:"genesis"`
+		} `json:"result"`
+	}
+	if err := json.NewDecoder(r).Decode(&document); err != nil {
+		return nil, err
+	}
+	if document.Result != nil {
+		return &document.Result.Genesis, nil
+	}
+	return &document.Genesis, nil
+}
+
+func LoadGenesisFile(path string) (*Genesis, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadGenesis(f)
+}
+
+// Request genesis from genesis method of the node API
+func FetchGenesis(nodeApi string) (*Genesis, error) {
+	client := &http.Client{Timeout: genesisFetchTimeout}
+	response, err := client.Get(strings.TrimRight(nodeApi, "/") + "/genesis")
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get genesis: %s", response.Status)
+	}
+	return ReadGenesis(response.Body)
+}
+
+// Seed addresses, coins, validators, stakes and balances from genesis app state
+// and set ingestion cursors to its start height, so Run continues from the next height.
+// Existing rows are updated, so the import can be run again after a failure.
+func (ext *Extender) ImportGenesis(genesis *Genesis) error {
+	state := genesis.AppState
+	var startHeight uint64
+	if state.StartHeight != "" {
+		var err error
+		if startHeight, err = strconv.ParseUint(state.StartHeight, 10, 64); err != nil {
+			return err
+		}
+	}
+
+	lastExplorerBlock, err := ext.blockRepository.GetLastFromDB()
+	if err != nil && err != pg.ErrNoRows {
+		return err
+	}
+	if lastExplorerBlock != nil && lastExplorerBlock.ID > startHeight {
+		return fmt.Errorf("blocks are already ingested up to height %d, genesis starts at %d", lastExplorerBlock.ID, startHeight)
+	}
+
+	logger := ext.logger.WithFields(logrus.Fields{
+		"chain_id":     genesis.ChainID,
+		"start_height": startHeight,
+	})
+
+	addressIds, err := ext.importGenesisAddresses(state)
+	if err != nil {
+		return err
+	}
+	logger.WithField("count", len(addressIds)).Info("genesis addresses imported")
+
+	if err = ext.importGenesisCoins(state.Coins); err != nil {
+		return err
+	}
+	logger.WithField("count", len(state.Coins)).Info("genesis coins imported")
+
+	if err = ext.importGenesisValidators(state, addressIds); err != nil {
+		return err
+	}
+	logger.WithField("count", len(state.Candidates)).Info("genesis candidates imported")
+
+	if err = ext.importGenesisBalances(state.Accounts, addressIds); err != nil {
+		return err
+	}
+	logger.WithField("count", len(state.Accounts)).Info("genesis balances imported")
+
+	return ext.cursorService.Init(startHeight)
+}
+
+// Save all addresses of the app state, returns their ids
+func (ext *Extender) importGenesisAddresses(state AppState) (map[string]uint64, error) {
+	addresses := genesisAddresses(state)
+	list := make([]string, 0, len(addresses))
+	for address := range addresses {
+		list = append(list, address)
+	}
+
+	ids := make(map[string]uint64, len(list))
+	for start := 0; start < len(list); start += genesisChunkSize {
+		end := start + genesisChunkSize
+		if end > len(list) {
+			end = len(list)
+		}
+		if err := ext.addressRepository.SaveAllIfNotExist(list[start:end]); err != nil {
+			return nil, err
+		}
+		saved, err := ext.addressRepository.FindAll(list[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for _, address := range saved {
+			ids[address.Address] = address.ID
+		}
+	}
+	return ids, nil
+}
+
+func (ext *Extender) importGenesisCoins(genesisCoins []Coin) error {
+	if len(genesisCoins) == 0 {
+		return nil
+	}
+	coins := make([]*models.Coin, len(genesisCoins))
+	for i, c := range genesisCoins {
+		var err error
+		if coins[i], err = genesisCoin(c); err != nil {
+			return err
+		}
+	}
+	return ext.coinRepository.SaveAllOrUpdate(coins)
+}
+
+func (ext *Extender) importGenesisValidators(state AppState, addressIds map[string]uint64) error {
+	if len(state.Candidates) == 0 {
+		return nil
+	}
+	// every validator is a candidate as well
+	validators := make([]*models.Validator, len(state.Candidates))
+	for i, candidate := range state.Candidates {
+		var err error
+		if validators[i], err = genesisValidator(candidate, addressIds); err != nil {
+			return err
+		}
+	}
+	if err := ext.validatorRepository.SaveAllIfNotExist(validators); err != nil {
+		return err
+	}
+
+	// validators which have already been saved get state of the genesis
+	var stakes []*models.Stake
+	for i, candidate := range state.Candidates {
+		id, err := ext.validatorRepository.FindIdByPk(validators[i].PublicKey)
+		if err != nil {
+			return err
+		}
+		validators[i].ID = id
+
+		for _, stake := range candidate.Stakes {
+			coinID, err := ext.coinRepository.FindIdBySymbol(stake.Coin)
+			if err != nil {
+				return fmt.Errorf("coin %s of stake: %s", stake.Coin, err)
+			}
+			stakes = append(stakes, &models.Stake{
+				ValidatorID:    id,
+				OwnerAddressID: addressIds[helpers.RemovePrefixFromAddress(stake.Owner)],
+				CoinID:         coinID,
+				Value:          stake.Value,
+				NoahValue:      stake.NoahValue,
+			})
+		}
+	}
+	if err := ext.validatorRepository.UpdateAll(validators); err != nil {
+		return err
+	}
+
+	for start := 0; start < len(stakes); start += ext.env.StakeChunkSize {
+		end := start + ext.env.StakeChunkSize
+		if end > len(stakes) {
+			end = len(stakes)
+		}
+		if err := ext.validatorRepository.SaveAllStakes(stakes[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ext *Extender) importGenesisBalances(accounts []Account, addressIds map[string]uint64) error {
+	var balances []*models.Balance
+	for _, account := range accounts {
+		addressID := addressIds[helpers.RemovePrefixFromAddress(account.Address)]
+		for _, b := range account.Balance {
+			coinID, err := ext.coinRepository.FindIdBySymbol(b.Coin)
+			if err != nil {
+				return fmt.Errorf("coin %s of balance: %s", b.Coin, err)
+			}
+			balances = append(balances, &models.Balance{
+				AddressID: addressID,
+				CoinID:    coinID,
+				Value:     b.Value,
+			})
+		}
+	}
+
+	for start := 0; start < len(balances); start += genesisChunkSize {
+		end := start + genesisChunkSize
+		if end > len(balances) {
+			end = len(balances)
+		}
+		if err := ext.balanceRepository.SaveAllOrUpdate(balances[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Addresses of accounts, candidates and stake owners without prefix
+func genesisAddresses(state AppState) map[string]struct{} {
+	addresses := make(map[string]struct{})
+	for _, account := range state.Accounts {
+		addresses[helpers.RemovePrefixFromAddress(account.Address)] = struct{}{}
+	}
+	for _, candidate := range state.Candidates {
+		addresses[helpers.RemovePrefixFromAddress(candidate.RewardAddress)] = struct{}{}
+		addresses[helpers.RemovePrefixFromAddress(candidate.OwnerAddress)] = struct{}{}
+		for _, stake := range candidate.Stakes {
+			addresses[helpers.RemovePrefixFromAddress(stake.Owner)] = struct{}{}
+		}
+	}
+	return addresses
+}
+
+func genesisCoin(c Coin) (*models.Coin, error) {
+	crr, err := strconv.ParseUint(c.Crr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("crr of coin %s: %s", c.Symbol, err)
+	}
+	price := coin.GetTokenPrice(c.Volume, c.ReserveBalance, crr)
+	return &models.Coin{
+		Name:                c.Name,
+		Symbol:              c.Symbol,
+		Crr:                 crr,
+		Volume:              c.Volume,
+		ReserveBalance:      c.ReserveBalance,
+		Price:               price,
+		Capitalization:      coin.GetCapitalization(c.Volume, price),
+		StartVolume:         c.Volume,
+		StartReserveBalance: c.ReserveBalance,
+		StartPrice:          price,
+		UpdatedAt:           time.Now(),
+	}, nil
+}
+
+func genesisValidator(candidate Candidate, addressIds map[string]uint64) (*models.Validator, error) {
+	commission, err := strconv.ParseUint(candidate.Commission, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("commission of candidate %s: %s", candidate.PubKey, err)
+	}
+	createdAtBlockID, err := strconv.ParseUint(candidate.CreatedAtBlock, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("created at block of candidate %s: %s", candidate.PubKey, err)
+	}
+	rewardAddressID, ok := addressIds[helpers.RemovePrefixFromAddress(candidate.RewardAddress)]
+	if !ok {
+		return nil, errors.New("reward address of candidate has not been saved")
+	}
+	ownerAddressID, ok := addressIds[helpers.RemovePrefixFromAddress(candidate.OwnerAddress)]
+	if !ok {
+		return nil, errors.New("owner address of candidate has not been saved")
+	}
+	status := uint8(candidate.Status)
+	updatedAt := time.Now()
+	return &models.Validator{
+		PublicKey:        helpers.RemovePrefix(candidate.PubKey),
+		Status:           &status,
+		Commission:       &commission,
+		TotalStake:       &candidate.TotalNoahStake,
+		CreatedAtBlockID: &createdAtBlockID,
+		RewardAddressID:  &rewardAddressID,
+		OwnerAddressID:   &ownerAddressID,
+		UpdatedAt:        &updatedAt,
+	}, nil
+}
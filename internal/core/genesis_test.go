@@ -0,0 +1,79 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+const testGenesis = `{
+  "genesis_time": "2019-10-01T00:00:00Z",
+  "chain_id": "noah-test",
+  "app_state": {
+    "start_height": "100",
+    "candidates": [{
+      "reward_address": "NOAHxaa",
+      "owner_address": "NOAHxbb",
+      "total_noah_stake": "1000",
+      "pub_key": "Np01",
+      "commission": "10",
+      "created_at_block": "1",
+      "status": 2,
+      "stakes": [{"owner": "NOAHxcc", "coin": "NOAH", "value": "1000", "noah_value": "1000"}]
+    }],
+    "accounts": [{"address": "NOAHxaa", "balance": [{"coin": "NOAH", "value": "5"}], "nonce": "0"}],
+    "coins": [{"name": "Test", "symbol": "TEST", "volume": "1000", "crr": "50", "reserve_balance": "100"}]
+  }
+}`
+
+func TestReadGenesis(t *testing.T) {
+	fromFile, err := ReadGenesis(strings.NewReader(testGenesis))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromNode, err := ReadGenesis(strings.NewReader(`{"jsonrpc": "2.0", "id": "", "result": {"genesis": ` + testGenesis + `}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, genesis := range []*Genesis{fromFile, fromNode} {
+		if genesis.ChainID != "noah-test" || genesis.AppState.StartHeight != "100" || len(genesis.AppState.Candidates) != 1 {
+			t.Errorf("Genesis is read wrong: %+v", genesis)
+		}
+	}
+}
+
+func TestGenesisModels(t *testing.T) {
+	genesis, err := ReadGenesis(strings.NewReader(testGenesis))
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := genesis.AppState
+
+	addresses := genesisAddresses(state)
+	for _, address := range []string{"aa", "bb", "cc"} {
+		if _, ok := addresses[address]; !ok {
+			t.Error("Address must be collected without prefix: ", address)
+		}
+	}
+	if len(addresses) != 3 {
+		t.Error("Addresses must be unique, got ", addresses)
+	}
+
+	c, err := genesisCoin(state.Coins[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Symbol != "TEST" || c.Crr != 50 || c.Price == "" || c.StartPrice != c.Price {
+		t.Errorf("Coin is converted wrong: %+v", c)
+	}
+
+	validator, err := genesisValidator(state.Candidates[0], map[string]uint64{"aa": 1, "bb": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if validator.PublicKey != "01" || *validator.Status != 2 || *validator.RewardAddressID != 1 || *validator.OwnerAddressID != 2 {
+		t.Errorf("Validator is converted wrong: %+v", validator)
+	}
+	if _, err = genesisValidator(state.Candidates[0], map[string]uint64{}); err == nil {
+		t.Error("Candidate with unknown addresses must be rejected")
+	}
+}
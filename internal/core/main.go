@@ -1,11 +1,13 @@
 package core
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/pkg/errors"
 	"math"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/dgraph-io/badger"
@@ -16,37 +18,63 @@ import (
 	"github.com/noah-blockchain/noah-extender/internal/address"
 	"github.com/noah-blockchain/noah-extender/internal/balance"
 	"github.com/noah-blockchain/noah-extender/internal/block"
+	"github.com/noah-blockchain/noah-extender/internal/bulk"
 	"github.com/noah-blockchain/noah-extender/internal/coin"
+	"github.com/noah-blockchain/noah-extender/internal/cursor"
+	"github.com/noah-blockchain/noah-extender/internal/dlq"
+	"github.com/noah-blockchain/noah-extender/internal/env"
 	"github.com/noah-blockchain/noah-extender/internal/events"
+	"github.com/noah-blockchain/noah-extender/internal/failure"
+	"github.com/noah-blockchain/noah-extender/internal/node"
 	"github.com/noah-blockchain/noah-extender/internal/transaction"
 	"github.com/noah-blockchain/noah-extender/internal/validator"
-	"github.com/noah-blockchain/noah-node-go-api"
+	"github.com/noah-blockchain/noah-extender/internal/verify"
 	"github.com/noah-blockchain/noah-node-go-api/responses"
 	"github.com/sirupsen/logrus"
 )
 
 const (
-	ChasingModDiff    = 2
-	CoinWorkerTimeout = time.Minute
+	ChasingModDiff      = 2
+	CoinWorkerTimeout   = time.Minute
+	CursorFlushInterval = time.Second
+	BlockWaitTimeout    = 10 * time.Second
+	SubscriptionDelay   = 5 * time.Second
+	VerifyRecheckDelay  = 30 * time.Second
 )
 
 type Extender struct {
-	env                 *models.ExtenderEnvironment
-	nodeApi             *noah_node_go_api.NoahNodeApi
+	env                 *env.Environment
+	nodeApi             node.Client
 	blockService        *block.Service
 	addressService      *address.Service
+	addressRepository   *address.Repository
 	blockRepository     *block.Repository
 	validatorService    *validator.Service
 	validatorRepository *validator.Repository
+	coinRepository      *coin.Repository
+	balanceRepository   *balance.Repository
 	transactionService  *transaction.Service
 	eventService        *events.Service
 	balanceService      *balance.Service
 	coinService         *coin.Service
+	verifyService       *verify.Service
+	cursorService       *cursor.Service
+	failureService      *failure.Service
+	deadLetters         *dlq.Store
 	chasingMode         bool
 	currentNodeHeight   uint64
 	logger              *logrus.Entry
 	dbBadger            *badger.DB
 	db                  *pg.DB
+	ns                  stan.Conn
+	prefetcher          *prefetcher
+	subscription        *node.Subscription
+	workers             workerGroups
+	stopBackground      context.CancelFunc
+	state               runState
+	paused              pauseGate
+	reindexJobs         chan reindexJob
+	ranges              sync.Mutex
 }
 
 type dbLogger struct {
@@ -59,7 +87,7 @@ func (d dbLogger) AfterQuery(q *pg.QueryEvent) {
 	d.logger.Info(q.FormattedQuery())
 }
 
-func NewExtender(env *models.ExtenderEnvironment, db *pg.DB, dbBadger *badger.DB, ns stan.Conn, nodeApi *noah_node_go_api.NoahNodeApi) *Extender {
+func NewExtender(env *env.Environment, db *pg.DB, dbBadger *badger.DB, ns stan.Conn, nodeApi node.Client) *Extender {
 	//Init Logger
 	logger := logrus.New()
 	logger.SetFormatter(&logrus.JSONFormatter{})
@@ -84,90 +112,164 @@ func NewExtender(env *models.ExtenderEnvironment, db *pg.DB, dbBadger *badger.DB
 	//if env.Debug {
 	//	db.AddQueryHook(dbLogger{logger: contextLogger})
 	//}
+	db.AddQueryHook(dbMetrics{})
 	//api
 
 	// Repositories
 	blockRepository := block.NewRepository(db)
-	validatorRepository := validator.NewRepository(db)
+	validatorRepository := validator.NewRepository(db, env.CacheValidatorSize)
 	transactionRepository := transaction.NewRepository(db)
-	addressRepository := address.NewRepository(db)
-	coinRepository := coin.NewRepository(db)
+	addressRepository := address.NewRepository(db, env.CacheAddressSize)
+	coinRepository := coin.NewRepository(db, env.CacheCoinSize)
 	eventsRepository := events.NewRepository(db)
 	balanceRepository := balance.NewRepository(db)
+	cursorRepository := cursor.NewRepository(db)
+	failureRepository := failure.NewRepository(db)
+	if env.BulkWriter == bulk.WriterCopy {
+		blockRepository.UseCopy()
+		transactionRepository.UseCopy()
+		eventsRepository.UseCopy()
+	}
 
 	// Services
-	balanceService := balance.NewService(env, balanceRepository, nodeApi, addressRepository, coinRepository, contextLogger)
-	coinService := coin.NewService(env, nodeApi, coinRepository, addressRepository, contextLogger, dbBadger, ns)
-	return &Extender{
+	cursorService := cursor.NewService(cursorRepository, contextLogger)
+	// dead-letter store is not available to commands which run without badger
+	var deadLetters *dlq.Store
+	if dbBadger != nil {
+		deadLetters = dlq.NewStore(dbBadger)
+	}
+	failureService := failure.NewService(env.FailurePolicy, env.RetryBackoffs, failureRepository, deadLetterStore(deadLetters), contextLogger)
+	balanceService := balance.NewService(env.ExtenderEnvironment, balanceRepository, nodeApi, addressRepository, coinRepository, cursorService, failureService, contextLogger)
+	coinService := coin.NewService(env.ExtenderEnvironment, nodeApi, coinRepository, addressRepository, failureService, contextLogger, dbBadger, ns)
+	validatorService := validator.NewService(env.ExtenderEnvironment, nodeApi, validatorRepository, addressRepository, coinRepository, contextLogger)
+	verifyService := verify.NewService(env.ExtenderEnvironment, nodeApi, addressRepository, balanceRepository, coinRepository,
+		validatorRepository, cursorService, balanceService, coinService, validatorService, contextLogger)
+	ext := &Extender{
 		env:                 env,
 		nodeApi:             nodeApi,
 		blockService:        block.NewBlockService(blockRepository, validatorRepository),
-		eventService:        events.NewService(env, eventsRepository, validatorRepository, addressRepository, coinRepository, coinService, balanceRepository, contextLogger),
+		eventService:        events.NewService(env.ExtenderEnvironment, eventsRepository, validatorRepository, addressRepository, coinRepository, coinService, balanceRepository, cursorService, failureService, contextLogger),
 		blockRepository:     blockRepository,
-		validatorService:    validator.NewService(env, nodeApi, validatorRepository, addressRepository, coinRepository, contextLogger),
-		transactionService:  transaction.NewService(env, transactionRepository, addressRepository, validatorRepository, coinRepository, coinService, contextLogger),
-		addressService:      address.NewService(env, addressRepository, balanceService.GetAddressesChannel(), contextLogger),
+		validatorService:    validatorService,
+		transactionService:  transaction.NewService(env.ExtenderEnvironment, transactionRepository, addressRepository, validatorRepository, coinRepository, coinService, cursorService, failureService, contextLogger),
+		addressService:      address.NewService(env.ExtenderEnvironment, addressRepository, balanceService.GetAddressesChannel(), cursorService, failureService, contextLogger),
+		addressRepository:   addressRepository,
 		validatorRepository: validatorRepository,
+		coinRepository:      coinRepository,
+		balanceRepository:   balanceRepository,
 		balanceService:      balanceService,
 		coinService:         coinService,
+		verifyService:       verifyService,
+		cursorService:       cursorService,
+		failureService:      failureService,
+		deadLetters:         deadLetters,
 		chasingMode:         true,
 		currentNodeHeight:   0,
 		logger:              contextLogger,
 		dbBadger:            dbBadger,
 		db:                  db,
+		ns:                  ns,
+		reindexJobs:         make(chan reindexJob, AdminReindexQueueSize),
+	}
+	ext.prefetcher = newPrefetcher(env.PrefetchDepth, ext.fetchHeight)
+	// recorded responses are replayed by height, new blocks are not waited for
+	if env.NodeWsLink != "" && env.NodeReplayDir == "" {
+		ext.subscription = node.NewSubscription(env.NodeWsLink, SubscriptionDelay, contextLogger)
 	}
+	return ext
 }
 
-func (ext *Extender) Run() {
+// Ingest blocks until ctx is done.
+// Returns failure.ErrHalted if ingestion has been stopped by the failure policy.
+func (ext *Extender) Run(ctx context.Context) error {
 	//check connections to node
 	_, err := ext.nodeApi.GetStatus()
-	if err == nil {
-		err = ext.blockRepository.DeleteLastBlockData()
-	} else {
-		ext.logger.Error(err)
+	if err != nil {
+		return err
 	}
-	helpers.HandleError(err)
 
-	var height uint64
+	// caches are only needed for ingestion, other commands start without warm up
+	if ext.env.CacheWarmUp {
+		ext.warmUpCaches(int64(ext.env.CacheWarmUpMemory) << 20)
+	}
 
 	// ----- Workers -----
-	ext.runWorkers()
+	ext.runWorkers(ctx)
 
-	lastExplorerBlock, _ := ext.blockRepository.GetLastFromDB()
+	height, err := ext.resumeStages()
+	if err != nil {
+		return err
+	}
 
+	lastExplorerBlock, _ := ext.blockRepository.GetLastFromDB()
 	if lastExplorerBlock != nil {
-		height = lastExplorerBlock.ID + 1
 		ext.blockService.SetBlockCache(lastExplorerBlock)
-	} else {
-		height = 1
 	}
 
+	// parent hash is always verified after restart and on every block in non-chasing mode
+	verifyParent := true
+
 	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ext.failureService.Halted():
+			return failure.ErrHalted
+		default:
+		}
+		if !ext.paused.wait(ctx) {
+			return nil
+		}
+
 		//start := time.Now()
-		ext.findOutChasingMode(height)
-		//Pulling block data
-		blockResponse, err := ext.nodeApi.GetBlock(height)
-		helpers.HandleError(err)
-		if blockResponse.Error != nil {
+		if err = ext.findOutChasingMode(height); err != nil {
+			ext.reportError(err)
 			time.Sleep(2 * time.Second)
 			continue
 		}
+		if ext.chasingMode && !verifyParent && ext.env.BackfillWorkers > 0 {
+			height = ext.backfill(ctx, height)
+			continue
+		}
 
-		//Pulling events
-		eventsResponse, err := ext.nodeApi.GetBlockEvents(height)
-		if err != nil {
-			ext.logger.Error(err)
+		//Pulling block and events data
+		fetched := ext.prefetcher.Get(height, ext.currentNodeHeight)
+		if fetched.blockResponse != nil && fetched.blockResponse.Error != nil {
+			ext.waitForBlock(ctx, height)
+			continue
+		}
+		if fetched.err == node.ErrBlockHashMismatch {
+			time.Sleep(2 * time.Second)
+			continue
+		}
+		if fetched.err != nil {
+			ext.reportError(fetched.err)
+			time.Sleep(2 * time.Second)
+			continue
 		}
-		helpers.HandleError(err)
+		blockResponse, eventsResponse := fetched.blockResponse, fetched.eventsResponse
 
-		ext.handleAddressesFromResponses(blockResponse, eventsResponse)
-		ext.handleBlockResponse(blockResponse)
-		ext.handleCoinsFromTransactions(blockResponse.Result.Transactions)
+		if verifyParent || !ext.chasingMode {
+			forkHeight, err := ext.rollbackFork(height)
+			if err != nil {
+				ext.reportError(err)
+				time.Sleep(2 * time.Second)
+				continue
+			}
+			if forkHeight < height {
+				ext.prefetcher.Reset()
+				height = forkHeight
+				continue
+			}
+			verifyParent = false
+		}
 
-		if height%uint64(ext.env.RewardAggregateEveryBlocksCount) == 0 {
-			go ext.eventService.AggregateRewards(ext.env.RewardAggregateTimeInterval, height)
+		ext.ranges.Lock()
+		err = ext.handleHeight(height, blockResponse, eventsResponse)
+		ext.ranges.Unlock()
+		if err != nil {
+			return err
 		}
-		go ext.handleEventResponse(height, eventsResponse)
 
 		height++
 
@@ -176,132 +278,211 @@ func (ext *Extender) Run() {
 	}
 }
 
-func (ext *Extender) runWorkers() {
+// Handle block and events of the height, all heights have to be handled in order.
+// Returns failure.ErrHalted if ingestion has to be stopped.
+func (ext *Extender) handleHeight(height uint64, blockResponse *responses.BlockResponse, eventsResponse *responses.EventsResponse) error {
+	ext.cursorService.Begin(height, cursor.StageBlock, cursor.StageTxs, cursor.StageEvents, cursor.StageBalances)
 
-	// Addresses
-	for w := 1; w <= ext.env.WrkSaveAddressesCount; w++ {
-		go ext.addressService.SaveAddressesWorker(ext.addressService.GetSaveAddressesJobChannel())
+	if ext.env.AtomicCommit {
+		if err := ext.handleBlockAtomic(height, blockResponse, eventsResponse); err != nil {
+			return err
+		}
+	} else {
+		if err := ext.handleAddressesFromResponses(height, blockResponse, eventsResponse); err != nil {
+			return err
+		}
+		ext.cursorService.Seal(cursor.StageBalances, height)
+		if err := ext.handleBlockResponse(height, blockResponse); err != nil {
+			return err
+		}
+		ext.cursorService.Seal(cursor.StageBlock, height)
+		ext.cursorService.Seal(cursor.StageTxs, height)
+		if err := ext.handleCoinsFromTransactions(height, blockResponse.Result.Transactions); err != nil {
+			return err
+		}
 	}
 
-	// Transactions
-	for w := 1; w <= ext.env.WrkSaveTxsCount; w++ {
-		go ext.transactionService.SaveTransactionsWorker(ext.transactionService.GetSaveTxJobChannel())
+	if height%uint64(ext.env.RewardAggregateEveryBlocksCount) == 0 {
+		ext.workers.handlers.Add(1)
+		go func() {
+			defer ext.workers.handlers.Done()
+			ext.eventService.AggregateRewards(ext.env.RewardAggregateTimeInterval, height)
+		}()
 	}
-	for w := 1; w <= ext.env.WrkSaveTxsOutputCount; w++ {
-		go ext.transactionService.SaveTransactionsOutputWorker(ext.transactionService.GetSaveTxsOutputJobChannel())
+	if !ext.env.AtomicCommit {
+		ext.workers.handlers.Add(1)
+		go func() {
+			defer ext.workers.handlers.Done()
+			_ = ext.handleEventResponse(height, eventsResponse)
+		}()
 	}
-	for w := 1; w <= ext.env.WrkSaveInvTxsCount; w++ {
-		go ext.transactionService.SaveInvalidTransactionsWorker(ext.transactionService.GetSaveInvalidTxsJobChannel())
-	}
-	go ext.transactionService.UpdateTxsIndexWorker()
+	ext.state.handled(height, ext.currentNodeHeight, ext.chasingMode)
+	observeHandled(height, ext.currentNodeHeight)
+	return nil
+}
+
+func (ext *Extender) runWorkers(ctx context.Context) {
+	ext.runPipelineWorkers()
+	ext.runBackgroundWorkers(ctx)
+}
+
+// Start workers which handle jobs produced by handlers of heights
+func (ext *Extender) runPipelineWorkers() {
+	w := &ext.workers
+
+	// Addresses
+	goWorkers(&w.addresses, ext.env.WrkSaveAddressesCount, func() {
+		ext.addressService.SaveAddressesWorker(ext.addressService.GetSaveAddressesJobChannel())
+	})
+
+	// Transactions
+	goWorkers(&w.txs, ext.env.WrkSaveTxsCount, func() {
+		ext.transactionService.SaveTransactionsWorker(ext.transactionService.GetSaveTxJobChannel())
+	})
+	goWorkers(&w.txs, ext.env.WrkSaveInvTxsCount, func() {
+		ext.transactionService.SaveInvalidTransactionsWorker(ext.transactionService.GetSaveInvalidTxsJobChannel())
+	})
+	goWorkers(&w.txChildren, ext.env.WrkSaveTxsOutputCount, func() {
+		ext.transactionService.SaveTransactionsOutputWorker(ext.transactionService.GetSaveTxsOutputJobChannel())
+	})
 
 	// Validators
-	for w := 1; w <= ext.env.WrkSaveValidatorTxsCount; w++ {
-		go ext.transactionService.SaveTxValidatorWorker(ext.transactionService.GetSaveTxValidatorJobChannel())
-	}
+	goWorkers(&w.txChildren, ext.env.WrkSaveValidatorTxsCount, func() {
+		ext.transactionService.SaveTxValidatorWorker(ext.transactionService.GetSaveTxValidatorJobChannel())
+	})
 	//обновляет награды валидаторов
-	go ext.validatorService.UpdateValidatorsWorker(ext.validatorService.GetUpdateValidatorsJobChannel())
+	goWorkers(&w.validators, 1, func() {
+		ext.validatorService.UpdateValidatorsWorker(ext.validatorService.GetUpdateValidatorsJobChannel())
+	})
 
 	//обновляет стейки валидаторов
-	go ext.validatorService.UpdateStakesWorker(ext.validatorService.GetUpdateStakesJobChannel())
+	goWorkers(&w.validators, 1, func() {
+		ext.validatorService.UpdateStakesWorker(ext.validatorService.GetUpdateStakesJobChannel())
+	})
 
 	// Events
-	for w := 1; w <= ext.env.WrkSaveRewardsCount; w++ {
-		go ext.eventService.SaveRewardsWorker(ext.eventService.GetSaveRewardsJobChannel())
-	}
-	for w := 1; w <= ext.env.WrkSaveSlashesCount; w++ {
-		go ext.eventService.SaveSlashesWorker(ext.eventService.GetSaveSlashesJobChannel())
-	}
+	goWorkers(&w.events, ext.env.WrkSaveRewardsCount, func() {
+		ext.eventService.SaveRewardsWorker(ext.eventService.GetSaveRewardsJobChannel())
+	})
+	goWorkers(&w.events, ext.env.WrkSaveSlashesCount, func() {
+		ext.eventService.SaveSlashesWorker(ext.eventService.GetSaveSlashesJobChannel())
+	})
 
 	// Balances
-	go ext.balanceService.Run()
-	for w := 1; w <= ext.env.WrkGetBalancesFromNodeCount; w++ {
-		go ext.balanceService.GetBalancesFromNodeWorker(ext.balanceService.GetBalancesFromNodeChannel(), ext.balanceService.GetUpdateBalancesJobChannel())
-	}
-	for w := 1; w <= ext.env.WrkUpdateBalanceCount; w++ {
-		go ext.balanceService.UpdateBalancesWorker(ext.balanceService.GetUpdateBalancesJobChannel())
-	}
+	goWorkers(&w.balances, 1, ext.balanceService.Run)
+	goWorkers(&w.balancesFromNode, ext.env.WrkGetBalancesFromNodeCount, func() {
+		ext.balanceService.GetBalancesFromNodeWorker(ext.balanceService.GetBalancesFromNodeChannel(), ext.balanceService.GetUpdateBalancesJobChannel())
+	})
+	goWorkers(&w.balancesUpdate, ext.env.WrkUpdateBalanceCount, func() {
+		ext.balanceService.UpdateBalancesWorker(ext.balanceService.GetUpdateBalancesJobChannel())
+	})
 
 	//Coins
-	go ext.coinService.UpdateCoinsInfoFromTxsWorker(ext.coinService.GetUpdateCoinsFromTxsJobChannel())
-	go ext.coinService.UpdateCoinsInfoFromCoinsMap(ext.coinService.GetUpdateCoinsFromCoinsMapJobChannel())
-	go ext.coinWorker()
-	go ext.validatorUptimeWorker()
+	goWorkers(&w.coinsFromTxs, 1, func() {
+		ext.coinService.UpdateCoinsInfoFromTxsWorker(ext.coinService.GetUpdateCoinsFromTxsJobChannel())
+	})
+	goWorkers(&w.coinsFromMap, 1, func() {
+		ext.coinService.UpdateCoinsInfoFromCoinsMap(ext.coinService.GetUpdateCoinsFromCoinsMapJobChannel())
+	})
 }
 
-func (ext *Extender) handleAddressesFromResponses(blockResponse *responses.BlockResponse, eventsResponse *responses.EventsResponse) {
-	err := ext.addressService.HandleResponses(blockResponse, eventsResponse)
-	if err != nil {
-		ext.logger.Error(err)
+// Start periodic workers of the tip-following instance
+func (ext *Extender) runBackgroundWorkers(ctx context.Context) {
+	w := &ext.workers
+	// background workers are stopped by Shutdown even if Run has returned on halt
+	ctx, ext.stopBackground = context.WithCancel(ctx)
+
+	goWorkers(&w.background, 1, func() { ext.cursorService.FlushWorker(ctx, CursorFlushInterval) })
+	goWorkers(&w.background, 1, func() { ext.transactionService.UpdateTxsIndexWorker(ctx) })
+	goWorkers(&w.background, 1, func() { ext.coinWorker(ctx) })
+	goWorkers(&w.background, 1, func() { ext.validatorUptimeWorker(ctx) })
+	goWorkers(&w.background, 1, func() { ext.queueDepthWorker(ctx, QueueDepthInterval) })
+	goWorkers(&w.background, 1, func() { ext.reindexWorker(ctx) })
+	goWorkers(&w.background, 1, func() { ext.partitionWorker(ctx, PartitionCheckInterval) })
+	if ext.env.PruneKeepBlocks > 0 || ext.env.PruneKeepDays > 0 {
+		goWorkers(&w.background, 1, func() { ext.pruneWorker(ctx, ext.env.PruneInterval) })
+	}
+	if ext.subscription != nil {
+		goWorkers(&w.background, 1, func() { ext.subscription.Run(ctx) })
+	}
+	if ext.env.VerifyInterval > 0 {
+		goWorkers(&w.background, 1, func() {
+			ext.verifyService.SampleWorker(ctx, ext.env.VerifyInterval, VerifyRecheckDelay, ext.env.VerifySampleSize, ext.env.VerifyRepair)
+		})
 	}
-	helpers.HandleError(err)
 }
 
-func (ext *Extender) handleBlockResponse(response *responses.BlockResponse) {
-	// Save validators if not exist
-	validators, err := ext.validatorService.HandleBlockResponse(response)
-	if err != nil {
-		ext.logger.Error(err)
+// Failed jobs are handled by the failure policy, returned error is failure.ErrHalted only
+func (ext *Extender) handleAddressesFromResponses(height uint64, blockResponse *responses.BlockResponse,
+	eventsResponse *responses.EventsResponse) error {
+
+	err := ext.failureService.Do(failure.StageAddresses, height, func() error {
+		return ext.addressService.HandleResponses(blockResponse, eventsResponse)
+	})
+	if err == failure.ErrHalted {
+		return err
 	}
-	helpers.HandleError(err)
+	return nil
+}
 
-	// Save block
-	err = ext.blockService.HandleBlockResponse(response)
-	if err != nil {
-		ext.logger.Error(err)
+func (ext *Extender) handleBlockResponse(height uint64, response *responses.BlockResponse) error {
+	var validators []*models.Validator
+	err := ext.failureService.Do(failure.StageBlock, height, func() (err error) {
+		// Save validators if not exist
+		if validators, err = ext.validatorService.HandleBlockResponse(response); err != nil {
+			return err
+		}
+		// Save block
+		return ext.blockService.HandleBlockResponse(response)
+	})
+	if err == failure.ErrHalted {
+		return err
 	}
-	helpers.HandleError(err)
 
-	ext.linkBlockValidator(*response)
+	if err = ext.linkBlockValidator(height, *response); err != nil {
+		return err
+	}
 
 	//first block don't have validators
 	if response.Result.TxCount != "0" && len(validators) > 0 {
-		ext.handleTransactions(response, validators)
+		if err = ext.handleTransactions(height, response); err != nil {
+			return err
+		}
 	}
 
-	height, err := strconv.ParseUint(response.Result.Height, 10, 64)
-	if err != nil {
-		ext.logger.Error(err)
-	}
-	helpers.HandleError(err)
+	ext.updateValidators(height)
+	return nil
+}
 
+func (ext *Extender) updateValidators(height uint64) {
 	// No need to update candidate and stakes at the same time
 	// Candidate will be updated in the next iteration
-	if height%12 == 0 {
+	if height%validator.UpdateStakesEveryBlocks == 0 {
 		ext.validatorService.GetUpdateStakesJobChannel() <- height
 	} else if height > 1 {
 		ext.validatorService.GetUpdateValidatorsJobChannel() <- height
 	}
 }
 
-func (ext *Extender) handleCoinsFromTransactions(transactions []responses.Transaction) {
+func (ext *Extender) handleCoinsFromTransactions(height uint64, transactions []responses.Transaction) error {
 	if len(transactions) == 0 {
-		return
-	}
-
-	coins, err := ext.coinService.ExtractCoinsFromTransactions(transactions)
-	if err != nil {
-		ext.logger.Error(err)
-		helpers.HandleError(err)
-	}
-
-	if len(coins) == 0 {
-		return
+		return nil
 	}
 
-	err = ext.coinService.CreateNewCoins(coins)
-	if err != nil {
-		ext.logger.Error(err)
-		helpers.HandleError(err)
+	err := ext.failureService.Do(failure.StageCoins, height, func() error {
+		coins, err := ext.coinService.ExtractCoinsFromTransactions(transactions)
+		if err != nil || len(coins) == 0 {
+			return err
+		}
+		return ext.coinService.CreateNewCoins(coins)
+	})
+	if err == failure.ErrHalted {
+		return err
 	}
+	return nil
 }
 
-func (ext *Extender) handleTransactions(response *responses.BlockResponse, validators []*models.Validator) {
-	height, err := strconv.ParseUint(response.Result.Height, 10, 64)
-	if err != nil {
-		ext.logger.Error(err)
-	}
-	helpers.HandleError(err)
+func (ext *Extender) handleTransactions(height uint64, response *responses.BlockResponse) error {
 	chunksCount := int(math.Ceil(float64(len(response.Result.Transactions)) / float64(ext.env.TxChunkSize)))
 	for i := 0; i < chunksCount; i++ {
 		start := ext.env.TxChunkSize * i
@@ -309,37 +490,59 @@ func (ext *Extender) handleTransactions(response *responses.BlockResponse, valid
 		if end > len(response.Result.Transactions) {
 			end = len(response.Result.Transactions)
 		}
-		ext.saveTransactions(height, response.Result.Time, response.Result.Transactions[start:end])
+		transactions := response.Result.Transactions[start:end]
+		err := ext.failureService.Do(failure.StageTxs, height, func() error {
+			return ext.transactionService.HandleTransactionsFromBlockResponse(height, response.Result.Time, transactions)
+		})
+		if err == failure.ErrHalted {
+			return err
+		}
 	}
+	return nil
 }
 
-func (ext *Extender) handleEventResponse(blockHeight uint64, response *responses.EventsResponse) {
+func (ext *Extender) handleEventResponse(blockHeight uint64, response *responses.EventsResponse) error {
 	if len(response.Result.Events) > 0 {
 		//Save events
-		err := ext.eventService.HandleEventResponse(blockHeight, response)
-		if err != nil {
-			ext.logger.Error(err)
+		err := ext.failureService.Do(failure.StageEvents, blockHeight, func() error {
+			return ext.eventService.HandleEventResponse(blockHeight, response)
+		})
+		if err == failure.ErrHalted {
+			return err
 		}
-		helpers.HandleError(err)
 	}
+	ext.cursorService.Seal(cursor.StageEvents, blockHeight)
+	return nil
 }
 
-func (ext *Extender) linkBlockValidator(response responses.BlockResponse) {
-	if response.Result.Height == "1" {
-		return
+func (ext *Extender) linkBlockValidator(height uint64, response responses.BlockResponse) error {
+	if height == 1 {
+		return nil
+	}
+	err := ext.failureService.Do(failure.StageValidators, height, func() error {
+		links, err := ext.getBlockValidatorLinks(response)
+		if err != nil {
+			return err
+		}
+		return ext.blockRepository.LinkWithValidators(links)
+	})
+	if err == failure.ErrHalted {
+		return err
 	}
+	return nil
+}
+
+func (ext *Extender) getBlockValidatorLinks(response responses.BlockResponse) ([]*models.BlockValidator, error) {
 	var links []*models.BlockValidator
 	height, err := strconv.ParseUint(response.Result.Height, 10, 64)
 	if err != nil {
-		ext.logger.Error(err)
+		return nil, err
 	}
-	helpers.HandleError(err)
 	for _, v := range response.Result.Validators {
 		vId, err := ext.validatorRepository.FindIdByPk(helpers.RemovePrefix(v.PubKey))
 		if err != nil {
-			ext.logger.Error(err)
+			return nil, err
 		}
-		helpers.HandleError(err)
 		link := models.BlockValidator{
 			ValidatorID: vId,
 			BlockID:     height,
@@ -347,20 +550,33 @@ func (ext *Extender) linkBlockValidator(response responses.BlockResponse) {
 		}
 		links = append(links, &link)
 	}
-	err = ext.blockRepository.LinkWithValidators(links)
-	if err != nil {
-		ext.logger.Error(err)
-	}
-	helpers.HandleError(err)
+	return links, nil
 }
 
-func (ext *Extender) saveTransactions(blockHeight uint64, blockCreatedAt time.Time, transactions []responses.Transaction) {
-	// Save transactions
-	err := ext.transactionService.HandleTransactionsFromBlockResponse(blockHeight, blockCreatedAt, transactions)
-	if err != nil {
-		ext.logger.Error(err)
+// Wait until the node may have produced the height.
+// New blocks are awaited from the subscription while it is connected and polled otherwise.
+func (ext *Extender) waitForBlock(ctx context.Context, height uint64) {
+	if ext.subscription == nil || !ext.subscription.IsConnected() {
+		select {
+		case <-ctx.Done():
+		case <-time.After(2 * time.Second):
+		}
+		return
+	}
+
+	timeout := time.After(BlockWaitTimeout)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timeout:
+			return
+		case newHeight := <-ext.subscription.Heights():
+			if newHeight >= height {
+				return
+			}
+		}
 	}
-	helpers.HandleError(err)
 }
 
 func (ext *Extender) getNodeLastBlockId() (uint64, error) {
@@ -372,27 +588,26 @@ func (ext *Extender) getNodeLastBlockId() (uint64, error) {
 	return strconv.ParseUint(statusResponse.Result.LatestBlockHeight, 10, 64)
 }
 
-func (ext *Extender) findOutChasingMode(height uint64) {
+func (ext *Extender) findOutChasingMode(height uint64) error {
 	var err error
 	if ext.currentNodeHeight == 0 {
 		ext.currentNodeHeight, err = ext.getNodeLastBlockId()
 		if err != nil {
-			ext.logger.Error(err)
+			return err
 		}
-		helpers.HandleError(err)
 	}
 	isChasingMode := ext.currentNodeHeight-height > ChasingModDiff
 	if ext.chasingMode && !isChasingMode {
 		ext.currentNodeHeight, err = ext.getNodeLastBlockId()
 		if err != nil {
-			ext.logger.Error(err)
+			return err
 		}
-		helpers.HandleError(err)
 		ext.chasingMode = ext.currentNodeHeight-height > ChasingModDiff
 	}
+	return nil
 }
 
-func (ext *Extender) coinWorker() {
+func (ext *Extender) coinWorker(ctx context.Context) {
 	for {
 		//err := ext.dbBadger.Update(func(txn *badger.Txn) error {
 		//	opts := badger.DefaultIteratorOptions
@@ -429,7 +644,11 @@ func (ext *Extender) coinWorker() {
 		//}
 		ext.FixBrokenCoinMetaInfo()
 		ext.logger.Println("Coin Worker. New attempt")
-		time.Sleep(CoinWorkerTimeout)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(CoinWorkerTimeout):
+		}
 	}
 }
 
@@ -484,10 +703,14 @@ func (ext *Extender) FixBrokenCoinMetaInfo() {
 	}
 }
 
-func (ext *Extender) validatorUptimeWorker() {
+func (ext *Extender) validatorUptimeWorker(ctx context.Context) {
 	for {
 		ext.updateValidatorsUptime()
-		time.Sleep(5 * time.Minute)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Minute):
+		}
 	}
 }
 
@@ -528,6 +751,8 @@ func (ext *Extender) updateValidatorsUptime() {
 }
 
 func (ext *Extender) Close() {
-	ext.dbBadger.Close()
+	if ext.dbBadger != nil {
+		ext.dbBadger.Close()
+	}
 	ext.db.Close()
 }
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/noah-blockchain/noah-extender/internal/metrics"
+)
+
+const QueueDepthInterval = 5 * time.Second
+
+var insertTablePattern = regexp.MustCompile(`^INSERT INTO "?([a-z_]+)"?`)
+
+type queryStartKey struct{}
+
+// Measure duration of insert queries by table
+type dbMetrics struct{}
+
+func (d dbMetrics) BeforeQuery(q *pg.QueryEvent) {
+	q.Data[queryStartKey{}] = time.Now()
+}
+
+func (d dbMetrics) AfterQuery(q *pg.QueryEvent) {
+	start, ok := q.Data[queryStartKey{}].(time.Time)
+	if !ok {
+		return
+	}
+	query, err := q.UnformattedQuery()
+	if err != nil {
+		return
+	}
+	if table := insertTable(query); table != "" {
+		metrics.DbInsertDuration.WithLabelValues(table).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Return table of insert query or empty string for other queries
+func insertTable(query string) string {
+	match := insertTablePattern.FindStringSubmatch(query)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+func observeHandled(height, nodeHeight uint64) {
+	metrics.BlocksProcessed.Inc()
+	metrics.IngestionHeight.Set(float64(height))
+	var lag uint64
+	if nodeHeight > height {
+		lag = nodeHeight - height
+	}
+	metrics.IngestionLag.Set(float64(lag))
+}
+
+// Count of jobs waiting in every job channel of services
+func (ext *Extender) queueDepths() map[string]int {
+	return map[string]int{
+		"addresses":          len(ext.addressService.GetSaveAddressesJobChannel()),
+		"balances_from_node": len(ext.balanceService.GetBalancesFromNodeChannel()),
+		"balances_update":    len(ext.balanceService.GetUpdateBalancesJobChannel()),
+		"txs":                len(ext.transactionService.GetSaveTxJobChannel()),
+		"tx_outputs":         len(ext.transactionService.GetSaveTxsOutputJobChannel()),
+		"invalid_txs":        len(ext.transactionService.GetSaveInvalidTxsJobChannel()),
+		"tx_validators":      len(ext.transactionService.GetSaveTxValidatorJobChannel()),
+		"rewards":            len(ext.eventService.GetSaveRewardsJobChannel()),
+		"slashes":            len(ext.eventService.GetSaveSlashesJobChannel()),
+		"validators":         len(ext.validatorService.GetUpdateValidatorsJobChannel()),
+		"stakes":             len(ext.validatorService.GetUpdateStakesJobChannel()),
+		"coins_from_txs":     len(ext.coinService.GetUpdateCoinsFromTxsJobChannel()),
+		"coins_from_map":     len(ext.coinService.GetUpdateCoinsFromCoinsMapJobChannel()),
+	}
+}
+
+func (ext *Extender) queueDepthWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for queue, depth := range ext.queueDepths() {
+			metrics.QueueDepth.WithLabelValues(queue).Set(float64(depth))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package core
+
+import "testing"
+
+func TestInsertTable(t *testing.T) {
+	queries := map[string]string{
+		`INSERT INTO "balances" ("address_id", "coin_id", "value") VALUES (1, 1, '10')`: "balances",
+		`INSERT INTO transaction_outputs (transaction_id) VALUES (1)`:                   "transaction_outputs",
+		`SELECT "address"."id" FROM "addresses" AS "address"`:                           "",
+		`UPDATE "coins" SET "volume" = '1'`:                                             "",
+	}
+	for query, table := range queries {
+		if got := insertTable(query); got != table {
+			t.Errorf("Table of %q is %q, expected %q", query, got, table)
+		}
+	}
+}
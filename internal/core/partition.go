@@ -0,0 +1,30 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+const PartitionCheckInterval = 10 * time.Minute
+
+// Create partitions for upcoming heights every interval until ctx is done
+func (ext *Extender) partitionWorker(ctx context.Context, interval time.Duration) {
+	for {
+		height, err := ext.getNodeLastBlockId()
+		if err != nil {
+			height, err = ext.lastIngestedHeight()
+		}
+		if err == nil {
+			err = ext.blockRepository.CreatePartitions(height)
+		}
+		if err != nil {
+			ext.logger.WithField("height", height).Error(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"github.com/noah-blockchain/noah-extender/internal/metrics"
+)
+
+// Fetches the next heights while the current one is handled.
+// Must be used from a single goroutine.
+type prefetcher struct {
+	depth   int
+	fetch   func(uint64) *fetchedHeight
+	pending map[uint64]chan *fetchedHeight
+}
+
+func newPrefetcher(depth int, fetch func(uint64) *fetchedHeight) *prefetcher {
+	return &prefetcher{
+		depth:   depth,
+		fetch:   fetch,
+		pending: make(map[uint64]chan *fetchedHeight),
+	}
+}
+
+// Return block and events of the height and start fetching the following heights up to last
+func (p *prefetcher) Get(height, last uint64) *fetchedHeight {
+	var fetched *fetchedHeight
+	if result, ok := p.pending[height]; ok {
+		delete(p.pending, height)
+		select {
+		case fetched = <-result:
+			metrics.PrefetchRequests.WithLabelValues("hit").Inc()
+		default:
+			fetched = <-result
+			metrics.PrefetchRequests.WithLabelValues("wait").Inc()
+		}
+		// errors are not cached, the height could have been requested before it was produced
+		if fetched.err != nil {
+			fetched = p.fetch(height)
+		}
+	} else {
+		metrics.PrefetchRequests.WithLabelValues("miss").Inc()
+		fetched = p.fetch(height)
+	}
+
+	last = minHeight(last, height+uint64(p.depth))
+	for h := range p.pending {
+		if h < height || h > last {
+			delete(p.pending, h)
+		}
+	}
+	if fetched.err == nil {
+		for h := height + 1; h <= last; h++ {
+			if _, ok := p.pending[h]; !ok {
+				p.pending[h] = p.start(h)
+			}
+		}
+	}
+	metrics.PrefetchPending.Set(float64(len(p.pending)))
+
+	return fetched
+}
+
+// Forget all prefetched heights, e.g. after rollback
+func (p *prefetcher) Reset() {
+	p.pending = make(map[uint64]chan *fetchedHeight)
+	metrics.PrefetchPending.Set(0)
+}
+
+func (p *prefetcher) start(height uint64) chan *fetchedHeight {
+	result := make(chan *fetchedHeight, 1)
+	go func() {
+		result <- p.fetch(height)
+	}()
+	return result
+}
+
+func minHeight(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
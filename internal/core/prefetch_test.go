@@ -0,0 +1,47 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestPrefetcherReturnsRequestedHeights(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[uint64]int)
+	fetch := func(height uint64) *fetchedHeight {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[height]++
+		if height == 3 && calls[height] == 1 {
+			return &fetchedHeight{height: height, err: errors.New("not produced yet")}
+		}
+		return &fetchedHeight{height: height}
+	}
+
+	p := newPrefetcher(2, fetch)
+	for height := uint64(1); height <= 5; height++ {
+		fetched := p.Get(height, 5)
+		if fetched.height != height {
+			t.Fatal("Height must be ", height, " but now ", fetched.height)
+		}
+		if fetched.err != nil {
+			t.Error("Prefetched error must be fetched again for height ", height)
+		}
+	}
+	if len(p.pending) != 0 {
+		t.Error("Heights above last must not be prefetched")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for height := uint64(1); height <= 5; height++ {
+		expected := 1
+		if height == 3 {
+			expected = 2
+		}
+		if calls[height] != expected {
+			t.Error("Height ", height, " must be fetched ", expected, " times but now ", calls[height])
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/noah-blockchain/noah-extender/internal/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// Prune detailed data below the window of the last blocks every interval until ctx is done.
+// Every batch is pruned within its own DB transaction, so ingestion is not blocked for long.
+func (ext *Extender) pruneWorker(ctx context.Context, interval time.Duration) {
+	var pruned uint64
+	for {
+		height, err := ext.prune(ctx, pruned)
+		if err != nil {
+			ext.logger.WithField("height", height).Error(err)
+		}
+		pruned = height
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Prune heights above pruned up to the horizon of the window, returns the last pruned height
+func (ext *Extender) prune(ctx context.Context, pruned uint64) (uint64, error) {
+	last, err := ext.lastIngestedHeight()
+	if err != nil {
+		return pruned, err
+	}
+	var dayHeight uint64
+	if ext.env.PruneKeepDays > 0 {
+		since := time.Now().AddDate(0, 0, -ext.env.PruneKeepDays)
+		if dayHeight, err = ext.blockRepository.GetLastHeightBefore(since); err != nil {
+			return pruned, err
+		}
+	}
+	horizon := pruneHorizon(last, ext.env.PruneKeepBlocks, ext.env.PruneKeepDays > 0, dayHeight)
+
+	if pruned == 0 {
+		first, err := ext.blockRepository.GetFirstPrunableHeight()
+		if err != nil || first == 0 {
+			return pruned, err
+		}
+		pruned = first - 1
+	}
+
+	for pruned < horizon {
+		select {
+		case <-ctx.Done():
+			return pruned, nil
+		default:
+		}
+		from, to := pruned+1, pruned+ext.env.PruneBatchSize
+		if to > horizon {
+			to = horizon
+		}
+		if err = ext.blockRepository.PruneRange(from, to); err != nil {
+			return pruned, err
+		}
+		pruned = to
+		metrics.PrunedHeight.Set(float64(pruned))
+		ext.logger.WithFields(logrus.Fields{
+			"from": from,
+			"to":   to,
+		}).Debug("pruned")
+	}
+	return pruned, nil
+}
+
+// Return the highest height to prune, the larger window is kept if both limits are set
+func pruneHorizon(last, keepBlocks uint64, byDays bool, dayHeight uint64) uint64 {
+	var horizon uint64
+	limited := false
+	if keepBlocks > 0 {
+		if last > keepBlocks {
+			horizon = last - keepBlocks
+		}
+		limited = true
+	}
+	if byDays && (!limited || dayHeight < horizon) {
+		horizon = dayHeight
+	}
+	return horizon
+}
@@ -0,0 +1,18 @@
+package core
+
+import "testing"
+
+func TestPruneHorizon(t *testing.T) {
+	if pruneHorizon(1000, 100, false, 0) != 900 {
+		t.Error("Last blocks must be kept")
+	}
+	if pruneHorizon(50, 100, false, 0) != 0 {
+		t.Error("Nothing must be pruned while there are fewer blocks than the window")
+	}
+	if pruneHorizon(1000, 0, true, 700) != 700 {
+		t.Error("Blocks of the last days must be kept")
+	}
+	if pruneHorizon(1000, 100, true, 700) != 700 || pruneHorizon(1000, 500, true, 700) != 500 {
+		t.Error("The larger window must be kept")
+	}
+}
@@ -0,0 +1,199 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-pg/pg"
+	"github.com/noah-blockchain/noah-extender/internal/cursor"
+	"github.com/noah-blockchain/noah-extender/internal/failure"
+	"github.com/noah-blockchain/noah-node-go-api/responses"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ReindexTxs        = "txs"
+	ReindexEvents     = "events"
+	ReindexValidators = "validators"
+)
+
+var ReindexStages = []string{ReindexTxs, ReindexEvents, ReindexValidators}
+
+// Reindex request which can not be run, e.g. unknown stage or range above the ingested heights
+type invalidReindexError struct {
+	reason string
+}
+
+func (e invalidReindexError) Error() string {
+	return e.reason
+}
+
+// Delete data of the stages in heights from..to and ingest it again from the node.
+// Blocks, balances and ingestion cursors are kept, so a tip-following instance can run at the same time
+// as long as the range is below its cursors.
+func (ext *Extender) Reindex(ctx context.Context, from, to uint64, stages []string) error {
+	selected, err := ext.checkReindex(from, to, stages)
+	if err != nil {
+		return err
+	}
+
+	ext.runPipelineWorkers()
+	if err = ext.reindexRange(ctx, from, to, selected); err != nil {
+		return err
+	}
+
+	ext.logger.WithFields(logrus.Fields{
+		"from":   from,
+		"to":     to,
+		"stages": stages,
+	}).Warn("range has been reindexed")
+	return nil
+}
+
+// Return selected stages if the range can be reindexed
+func (ext *Extender) checkReindex(from, to uint64, stages []string) (map[string]bool, error) {
+	selected := make(map[string]bool)
+	for _, stage := range stages {
+		if !isReindexStage(stage) {
+			return nil, invalidReindexError{fmt.Sprintf("unknown reindex stage %q", stage)}
+		}
+		selected[stage] = true
+	}
+	if len(selected) == 0 {
+		return nil, invalidReindexError{"no reindex stages selected"}
+	}
+	if from == 0 || to < from {
+		return nil, invalidReindexError{fmt.Sprintf("invalid height range %d..%d", from, to)}
+	}
+
+	last, err := ext.lastIngestedHeight()
+	if err != nil {
+		return nil, err
+	}
+	if to > last {
+		return nil, invalidReindexError{fmt.Sprintf("height %d has not been ingested yet, last ingested height is %d", to, last)}
+	}
+	return selected, nil
+}
+
+// Delete and ingest again data of the selected stages, pipeline workers have to be running
+func (ext *Extender) reindexRange(ctx context.Context, from, to uint64, selected map[string]bool) error {
+	var err error
+	if selected[ReindexTxs] {
+		if err = ext.blockRepository.DeleteTransactionsRange(from, to); err != nil {
+			return err
+		}
+	}
+	if selected[ReindexEvents] {
+		if err = ext.blockRepository.DeleteEventsRange(from, to); err != nil {
+			return err
+		}
+	}
+	if selected[ReindexValidators] {
+		if err = ext.blockRepository.DeleteValidatorLinksRange(from, to); err != nil {
+			return err
+		}
+	}
+
+	window := ext.env.BackfillWorkers
+	if ext.env.PrefetchDepth > window {
+		window = ext.env.PrefetchDepth
+	}
+
+	height := from
+	for fetched := range fetchOrdered(ctx, from, to, window, ext.fetchHeight) {
+		if fetched.err != nil {
+			return fetched.err
+		}
+		blockResponse, eventsResponse := fetched.blockResponse, fetched.eventsResponse
+
+		if selected[ReindexValidators] {
+			if _, err = ext.validatorService.HandleBlockResponse(blockResponse); err != nil {
+				return err
+			}
+			if err = ext.linkBlockValidator(height, *blockResponse); err != nil {
+				return err
+			}
+		}
+		if selected[ReindexTxs] {
+			if err = ext.addressService.HandleResponses(blockResponse, eventsResponse); err != nil {
+				return err
+			}
+			//first block don't have validators
+			if blockResponse.Result.TxCount != "0" && len(blockResponse.Result.Validators) > 0 {
+				if err = ext.handleTransactions(height, blockResponse); err != nil {
+					return err
+				}
+			}
+		}
+		if selected[ReindexEvents] {
+			if err = ext.reindexEventResponse(height, eventsResponse); err != nil {
+				return err
+			}
+		}
+
+		ext.logger.WithField("height", height).Info("reindexed")
+		height++
+	}
+	if height <= to {
+		return ctx.Err()
+	}
+
+	if selected[ReindexEvents] {
+		err = ext.eventService.RebuildAggregatedRewards(ext.env.RewardAggregateTimeInterval, from, to)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Save again rewards and slashes of the height, liquidation events are not applied again
+// as coins and balances are kept by reindex
+func (ext *Extender) reindexEventResponse(height uint64, response *responses.EventsResponse) error {
+	if len(response.Result.Events) == 0 {
+		return nil
+	}
+	err := ext.failureService.Do(failure.StageEvents, height, func() error {
+		return ext.eventService.ReindexEventResponse(height, response)
+	})
+	if err == failure.ErrHalted {
+		return err
+	}
+	return nil
+}
+
+// Return the highest height which has been fully ingested by the tip-following instance
+func (ext *Extender) lastIngestedHeight() (uint64, error) {
+	cursors, err := ext.cursorService.GetSavedCursors()
+	if err != nil {
+		return 0, err
+	}
+	if len(cursors) > 0 {
+		var last uint64
+		for i, stage := range cursor.Stages {
+			if i == 0 || cursors[stage] < last {
+				last = cursors[stage]
+			}
+		}
+		return last, nil
+	}
+
+	lastExplorerBlock, err := ext.blockRepository.GetLastFromDB()
+	if err == pg.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return lastExplorerBlock.ID, nil
+}
+
+func isReindexStage(stage string) bool {
+	for _, s := range ReindexStages {
+		if s == stage {
+			return true
+		}
+	}
+	return false
+}
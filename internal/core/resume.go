@@ -0,0 +1,149 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/go-pg/pg"
+	"github.com/noah-blockchain/noah-extender/internal/cursor"
+	"github.com/sirupsen/logrus"
+)
+
+var txStages = []cursor.Stage{
+	cursor.StageTxs,
+	cursor.StageTxOutputs,
+	cursor.StageTxValidators,
+	cursor.StageAddressIndex,
+}
+
+// Ingest again the stages which have not been completed up to the block stage cursor before the last shutdown.
+// Returns the height to continue ingestion from.
+func (ext *Extender) resumeStages() (uint64, error) {
+	cursors, err := ext.cursorService.Load()
+	if err != nil {
+		return 0, err
+	}
+
+	// Cursors have never been saved, only the last block can be incomplete
+	if len(cursors) == 0 {
+		if err = ext.blockRepository.DeleteLastBlockData(); err != nil {
+			return 0, err
+		}
+		var height uint64
+		lastExplorerBlock, err := ext.blockRepository.GetLastFromDB()
+		if err != nil && err != pg.ErrNoRows {
+			return 0, err
+		}
+		if lastExplorerBlock != nil {
+			height = lastExplorerBlock.ID
+		}
+		if err = ext.cursorService.Init(height); err != nil {
+			return 0, err
+		}
+		return height + 1, nil
+	}
+
+	blockHeight := cursors[cursor.StageBlock]
+	if err = ext.blockRepository.DeleteBlocksFrom(blockHeight + 1); err != nil {
+		return 0, err
+	}
+	if err = ext.cursorService.Rewind(blockHeight); err != nil {
+		return 0, err
+	}
+
+	txHeight := ext.lowestCursor(txStages...)
+	if txHeight < blockHeight {
+		if err = ext.cursorService.Rewind(txHeight, txStages...); err != nil {
+			return 0, err
+		}
+		if err = ext.blockRepository.DeleteTransactionsFrom(txHeight + 1); err != nil {
+			return 0, err
+		}
+	}
+
+	eventsHeight := ext.cursorService.GetCursor(cursor.StageEvents)
+	if eventsHeight < blockHeight {
+		if err = ext.cursorService.Rewind(eventsHeight, cursor.StageEvents); err != nil {
+			return 0, err
+		}
+		if err = ext.blockRepository.DeleteEventsFrom(eventsHeight + 1); err != nil {
+			return 0, err
+		}
+	}
+
+	balancesHeight := ext.cursorService.GetCursor(cursor.StageBalances)
+
+	from := txHeight
+	if eventsHeight < from {
+		from = eventsHeight
+	}
+	if balancesHeight < from {
+		from = balancesHeight
+	}
+	from++
+	if from <= blockHeight {
+		ext.logger.WithFields(logrus.Fields{
+			"from": from,
+			"to":   blockHeight,
+		}).Warn("resume unfinished stages")
+	}
+
+	for height := from; height <= blockHeight; height++ {
+		blockResponse, err := ext.nodeApi.GetBlock(height)
+		if err != nil {
+			return 0, err
+		}
+		if blockResponse.Error != nil {
+			return 0, fmt.Errorf("get block %d: %s", height, blockResponse.Error.Message)
+		}
+		eventsResponse, err := ext.nodeApi.GetBlockEvents(height)
+		if err != nil {
+			return 0, err
+		}
+
+		var stages []cursor.Stage
+		if height > txHeight {
+			stages = append(stages, cursor.StageTxs)
+		}
+		if height > eventsHeight {
+			stages = append(stages, cursor.StageEvents)
+		}
+		if height > balancesHeight {
+			stages = append(stages, cursor.StageBalances)
+		}
+		ext.cursorService.Begin(height, stages...)
+
+		if height > balancesHeight {
+			if err = ext.addressService.HandleResponses(blockResponse, eventsResponse); err != nil {
+				return 0, err
+			}
+			ext.cursorService.Seal(cursor.StageBalances, height)
+		}
+		if height > txHeight {
+			//first block don't have validators
+			if blockResponse.Result.TxCount != "0" && len(blockResponse.Result.Validators) > 0 {
+				if err = ext.handleTransactions(height, blockResponse); err != nil {
+					return 0, err
+				}
+			}
+			ext.cursorService.Seal(cursor.StageTxs, height)
+		}
+		if height > eventsHeight {
+			if err = ext.handleEventResponse(height, eventsResponse); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return blockHeight + 1, nil
+}
+
+func (ext *Extender) lowestCursor(stages ...cursor.Stage) uint64 {
+	var lowest uint64
+	for i, stage := range stages {
+		height := ext.cursorService.GetCursor(stage)
+		if i == 0 || height < lowest {
+			lowest = height
+		}
+	}
+	return lowest
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Worker groups are drained in the order of declaration,
+// workers of a group send jobs only to the groups declared after it.
+type workerGroups struct {
+	handlers         sync.WaitGroup
+	addresses        sync.WaitGroup
+	txs              sync.WaitGroup
+	txChildren       sync.WaitGroup
+	events           sync.WaitGroup
+	validators       sync.WaitGroup
+	balances         sync.WaitGroup
+	balancesFromNode sync.WaitGroup
+	balancesUpdate   sync.WaitGroup
+	coinsFromTxs     sync.WaitGroup
+	coinsFromMap     sync.WaitGroup
+	background       sync.WaitGroup
+}
+
+func goWorkers(wg *sync.WaitGroup, count int, worker func()) {
+	for w := 1; w <= count; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			worker()
+		}()
+	}
+}
+
+// Stop background workers and drain worker pipelines after Run has returned, publish pending NATS messages and close the stores.
+// Stores are closed even if workers have not been drained within timeout.
+func (ext *Extender) Shutdown(timeout time.Duration) error {
+	drained := make(chan struct{})
+	go func() {
+		ext.drainWorkers()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+	case <-time.After(timeout):
+		err = fmt.Errorf("workers have not been drained within %s", timeout)
+	}
+
+	if flushErr := ext.cursorService.Flush(); flushErr != nil {
+		ext.logger.Error(flushErr)
+	}
+	if closeErr := ext.ns.Close(); closeErr != nil {
+		ext.logger.Error(closeErr)
+	}
+	ext.Close()
+	return err
+}
+
+func (ext *Extender) drainWorkers() {
+	w := &ext.workers
+
+	// reindex, verify repair and other background workers send jobs to the pipelines
+	if ext.stopBackground != nil {
+		ext.stopBackground()
+	}
+	w.background.Wait()
+	w.handlers.Wait()
+
+	close(ext.addressService.GetSaveAddressesJobChannel())
+	w.addresses.Wait()
+
+	close(ext.transactionService.GetSaveTxJobChannel())
+	close(ext.transactionService.GetSaveInvalidTxsJobChannel())
+	w.txs.Wait()
+	close(ext.transactionService.GetSaveTxsOutputJobChannel())
+	close(ext.transactionService.GetSaveTxValidatorJobChannel())
+	w.txChildren.Wait()
+
+	close(ext.eventService.GetSaveRewardsJobChannel())
+	close(ext.eventService.GetSaveSlashesJobChannel())
+	w.events.Wait()
+
+	close(ext.validatorService.GetUpdateValidatorsJobChannel())
+	close(ext.validatorService.GetUpdateStakesJobChannel())
+	w.validators.Wait()
+
+	close(ext.balanceService.GetAddressesChannel())
+	w.balances.Wait()
+	close(ext.balanceService.GetBalancesFromNodeChannel())
+	w.balancesFromNode.Wait()
+	close(ext.balanceService.GetUpdateBalancesJobChannel())
+	w.balancesUpdate.Wait()
+
+	close(ext.coinService.GetUpdateCoinsFromTxsJobChannel())
+	w.coinsFromTxs.Wait()
+	close(ext.coinService.GetUpdateCoinsFromCoinsMapJobChannel())
+	w.coinsFromMap.Wait()
+	ext.coinService.WaitPublished()
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/noah-blockchain/noah-extender/internal/api"
+	"github.com/noah-blockchain/noah-extender/internal/cursor"
+)
+
+const HealthCheckTimeout = 5 * time.Second
+
+// State of the ingestion loop which is read by the API
+type runState struct {
+	mu          sync.RWMutex
+	height      uint64
+	nodeHeight  uint64
+	chasingMode bool
+	lastError   error
+	lastErrorAt time.Time
+}
+
+func (s *runState) handled(height, nodeHeight uint64, chasingMode bool) {
+	s.mu.Lock()
+	s.height = height
+	s.nodeHeight = nodeHeight
+	s.chasingMode = chasingMode
+	s.mu.Unlock()
+}
+
+func (s *runState) failed(err error) {
+	s.mu.Lock()
+	s.lastError = err
+	s.lastErrorAt = time.Now()
+	s.mu.Unlock()
+}
+
+// Log error of the ingestion loop and keep it for the status
+func (ext *Extender) reportError(err error) {
+	ext.logger.Error(err)
+	ext.state.failed(err)
+}
+
+func (ext *Extender) Status() api.Status {
+	ext.state.mu.RLock()
+	status := api.Status{
+		Height:      ext.state.height,
+		NodeHeight:  ext.state.nodeHeight,
+		ChasingMode: ext.state.chasingMode,
+		Paused:      ext.paused.isPaused(),
+		Cursors:     make(map[string]uint64),
+	}
+	if ext.state.lastError != nil {
+		lastErrorAt := ext.state.lastErrorAt
+		status.LastError = ext.state.lastError.Error()
+		status.LastErrorAt = &lastErrorAt
+	}
+	ext.state.mu.RUnlock()
+
+	// node height of the loop is refreshed in chasing mode only
+	var nodeHeight uint64
+	err := withTimeout(HealthCheckTimeout, func() (err error) {
+		nodeHeight, err = ext.getNodeLastBlockId()
+		return err
+	})
+	if err == nil && nodeHeight > status.NodeHeight {
+		status.NodeHeight = nodeHeight
+	}
+
+	select {
+	case <-ext.failureService.Halted():
+		status.Halted = true
+	default:
+	}
+
+	for _, stage := range cursor.Stages {
+		status.Cursors[string(stage)] = ext.cursorService.GetCursor(stage)
+	}
+	status.Queues = ext.queueDepths()
+	return status
+}
+
+// Check that DB, NATS and the node are reachable
+func (ext *Extender) CheckHealth() map[string]error {
+	return map[string]error{
+		"db": withTimeout(HealthCheckTimeout, func() error {
+			_, err := ext.db.Exec("select 1")
+			return err
+		}),
+		"nats": ext.checkNats(),
+		"node": withTimeout(HealthCheckTimeout, func() error {
+			_, err := ext.getNodeLastBlockId()
+			return err
+		}),
+	}
+}
+
+func (ext *Extender) checkNats() error {
+	if ext.ns == nil || ext.ns.NatsConn() == nil || !ext.ns.NatsConn().IsConnected() {
+		return errors.New("not connected")
+	}
+	return nil
+}
+
+// Node client retries failed requests, so checks must not wait for it
+func withTimeout(timeout time.Duration, check func() error) error {
+	result := make(chan error, 1)
+	go func() {
+		result <- check()
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(timeout):
+		return errors.New("timed out")
+	}
+}
@@ -0,0 +1,29 @@
+package core
+
+import (
+	"github.com/noah-blockchain/noah-extender/internal/verify"
+)
+
+// Compare random sample of the state with the node at the height, the height of DB state if it is 0.
+// Mismatches are repaired with the latest state of the node if it is requested.
+func (ext *Extender) Verify(height uint64, sampleSize int, repair bool) (*verify.Report, error) {
+	if height == 0 {
+		var err error
+		if height, err = ext.verifyService.StateHeight(); err != nil {
+			return nil, err
+		}
+	}
+	sample, err := ext.verifyService.RandomSample(sampleSize)
+	if err != nil {
+		return nil, err
+	}
+	report, err := ext.verifyService.Verify(height, sample)
+	if err != nil {
+		return nil, err
+	}
+	ext.verifyService.Observe(report)
+	if repair && len(report.Mismatches) > 0 {
+		err = ext.verifyService.Repair(report)
+	}
+	return report, err
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/sirupsen/logrus"
+)
+
+// Count of addresses loaded into cache by a single query
+const WarmUpBatchSize = 10000
+
+// Approximate memory of cached entries: strings, list elements and map buckets of both caches of a repository
+const (
+	warmUpAddressBytes   = 320
+	warmUpCoinBytes      = 256
+	warmUpValidatorBytes = 256
+)
+
+// Load all coins, all validators and the most active addresses of the last blocks into repository caches,
+// so ids of the first heights are not read from DB one by one. Caches take up to budget bytes approximately,
+// all coins and validators are loaded even if they exceed it.
+func (ext *Extender) warmUpCaches(budget int64) {
+	start := time.Now()
+	coins, err := ext.coinRepository.LoadAllToCache()
+	if err != nil {
+		ext.logger.Error(err)
+		return
+	}
+	validators, err := ext.validatorRepository.LoadAllToCache()
+	if err != nil {
+		ext.logger.Error(err)
+		return
+	}
+	ext.logger.WithFields(logrus.Fields{
+		"coins":      coins,
+		"validators": validators,
+	}).Info("coins and validators are loaded into caches")
+
+	limit := warmUpAddressLimit(budget, coins, validators, ext.env.CacheAddressSize)
+	var addresses int
+	if limit > 0 {
+		addresses, err = ext.warmUpAddresses(limit)
+		if err != nil {
+			ext.logger.Error(err)
+		}
+	}
+	ext.logger.WithFields(logrus.Fields{
+		"coins":      coins,
+		"validators": validators,
+		"addresses":  addresses,
+		"duration":   time.Since(start).String(),
+	}).Info("caches have been warmed up")
+}
+
+// Load up to limit addresses with the most transactions in the last blocks, returns count of loaded addresses
+func (ext *Extender) warmUpAddresses(limit int) (int, error) {
+	last, err := ext.blockRepository.GetLastFromDB()
+	if err == pg.ErrNoRows {
+		// nothing has been ingested yet
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var from uint64
+	if last.ID > ext.env.CacheWarmUpBlocks {
+		from = last.ID - ext.env.CacheWarmUpBlocks
+	}
+	ids, err := ext.addressRepository.FindMostActiveIds(from, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	loaded := 0
+	for start := 0; start < len(ids); start += WarmUpBatchSize {
+		end := start + WarmUpBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		count, err := ext.addressRepository.LoadToCache(ids[start:end])
+		if err != nil {
+			return loaded, err
+		}
+		loaded += count
+		ext.logger.WithFields(logrus.Fields{
+			"loaded": loaded,
+			"total":  len(ids),
+		}).Info("addresses are loaded into caches")
+	}
+	return loaded, nil
+}
+
+// Count of addresses which fit into the budget left by coins and validators, limited by the address cache size
+func warmUpAddressLimit(budget int64, coins, validators, cacheSize int) int {
+	budget -= int64(coins)*warmUpCoinBytes + int64(validators)*warmUpValidatorBytes
+	if budget <= 0 {
+		return 0
+	}
+	limit := budget / warmUpAddressBytes
+	if cacheSize > 0 && limit > int64(cacheSize) {
+		return cacheSize
+	}
+	return int(limit)
+}
@@ -0,0 +1,19 @@
+package core
+
+import "testing"
+
+func TestWarmUpAddressLimit(t *testing.T) {
+	const mb = 1 << 20
+	if limit := warmUpAddressLimit(mb, 0, 0, 0); limit != mb/warmUpAddressBytes {
+		t.Errorf("Limit is %d, expected all budget for addresses", limit)
+	}
+	if limit := warmUpAddressLimit(mb, 1000, 100, 0); limit != (mb-1000*warmUpCoinBytes-100*warmUpValidatorBytes)/warmUpAddressBytes {
+		t.Errorf("Limit is %d, expected budget left by coins and validators", limit)
+	}
+	if limit := warmUpAddressLimit(mb, 0, 0, 100); limit != 100 {
+		t.Errorf("Limit is %d, expected size of the address cache", limit)
+	}
+	if limit := warmUpAddressLimit(mb, mb, 0, 0); limit != 0 {
+		t.Errorf("Limit is %d, expected no addresses when coins exceed the budget", limit)
+	}
+}
@@ -0,0 +1,86 @@
+package cursor
+
+import (
+	"time"
+
+	"github.com/go-pg/pg"
+)
+
+type Cursor struct {
+	tableName struct{}  `sql:"ingestion_cursors"`
+	Stage     Stage     `sql:",pk"`
+	Height    uint64    `sql:",notnull"`
+	UpdatedAt time.Time `sql:"default:now()"`
+}
+
+type Progress struct {
+	tableName   struct{}  `sql:"ingestion_progress"`
+	BlockID     uint64    `sql:",pk"`
+	Stage       Stage     `sql:",pk"`
+	CompletedAt time.Time `sql:"default:now()"`
+}
+
+type Repository struct {
+	db *pg.DB
+}
+
+func NewRepository(db *pg.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+func (r *Repository) GetCursors() ([]*Cursor, error) {
+	var cursors []*Cursor
+	err := r.db.Model(&cursors).Select()
+	return cursors, err
+}
+
+// Return progress rows above stage cursors ordered by height
+func (r *Repository) GetProgress() ([]*Progress, error) {
+	var progress []*Progress
+	err := r.db.Model(&progress).Order("block_id ASC").Select()
+	return progress, err
+}
+
+func (r *Repository) SaveCursors(cursors []*Cursor) error {
+	if len(cursors) == 0 {
+		return nil
+	}
+	_, err := r.db.Model(&cursors).
+		OnConflict("(stage) DO UPDATE").
+		Set("height = EXCLUDED.height, updated_at = now()").
+		Insert()
+	return err
+}
+
+func (r *Repository) SaveProgress(progress []*Progress) error {
+	if len(progress) == 0 {
+		return nil
+	}
+	_, err := r.db.Model(&progress).OnConflict("DO NOTHING").Insert()
+	return err
+}
+
+// Delete progress rows which are already covered by stage cursors
+func (r *Repository) DeleteCompactedProgress() error {
+	_, err := r.db.Exec(`
+delete from ingestion_progress p
+using ingestion_cursors c
+where p.stage = c.stage and p.block_id <= c.height;
+	`)
+	return err
+}
+
+// Move cursors of the stages back to height if they are ahead and forget their progress above it
+func (r *Repository) Rewind(height uint64, stages []Stage) error {
+	return r.db.RunInTransaction(func(tx *pg.Tx) error {
+		_, err := tx.Exec(`update ingestion_cursors set height = ?, updated_at = now() where height > ? and stage in (?);`,
+			height, height, pg.In(stages))
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`delete from ingestion_progress where block_id > ? and stage in (?);`, height, pg.In(stages))
+		return err
+	})
+}
@@ -0,0 +1,335 @@
+package cursor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type Stage string
+
+const (
+	StageBlock        Stage = "block"
+	StageTxs          Stage = "txs"
+	StageTxOutputs    Stage = "tx_outputs"
+	StageTxValidators Stage = "tx_validators"
+	StageAddressIndex Stage = "address_index"
+	StageEvents       Stage = "events"
+	StageBalances     Stage = "balances"
+)
+
+var Stages = []Stage{
+	StageBlock,
+	StageTxs,
+	StageTxOutputs,
+	StageTxValidators,
+	StageAddressIndex,
+	StageEvents,
+	StageBalances,
+}
+
+// Stage can be completed only after its parent stage for the same height.
+// Jobs of child stages are produced by the parent stage workers.
+var parents = map[Stage]Stage{
+	StageTxOutputs:    StageTxs,
+	StageTxValidators: StageTxs,
+	StageAddressIndex: StageTxOutputs,
+}
+
+type heightState struct {
+	jobs   map[Stage]int
+	sealed map[Stage]bool
+	done   map[Stage]bool
+}
+
+// Service tracks completion of pipeline stages per height
+// and persists the last contiguously completed height of every stage.
+type Service struct {
+	repository *Repository
+	logger     *logrus.Entry
+	mu         sync.Mutex
+	heights    map[uint64]*heightState
+	cursors    map[Stage]uint64
+	completed  map[Stage]map[uint64]struct{}
+	progress   []*Progress
+	dirty      map[Stage]bool
+}
+
+func NewService(repository *Repository, logger *logrus.Entry) *Service {
+	s := &Service{
+		repository: repository,
+		logger:     logger,
+		heights:    make(map[uint64]*heightState),
+		cursors:    make(map[Stage]uint64),
+		completed:  make(map[Stage]map[uint64]struct{}),
+		dirty:      make(map[Stage]bool),
+	}
+	for _, stage := range Stages {
+		s.completed[stage] = make(map[uint64]struct{})
+	}
+	return s
+}
+
+// Load cursors from DB. Progress stored above a cursor moves it forward while heights are contiguous.
+// Returns empty map if cursors have never been saved.
+func (s *Service) Load() (map[Stage]uint64, error) {
+	cursors, err := s.repository.GetCursors()
+	if err != nil {
+		return nil, err
+	}
+	progress, err := s.repository.GetProgress()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make(map[Stage]uint64)
+	for _, c := range cursors {
+		s.cursors[c.Stage] = c.Height
+	}
+	for _, p := range progress {
+		if _, ok := s.completed[p.Stage]; ok {
+			s.markCompleted(p.Stage, p.BlockID)
+		}
+	}
+	if len(cursors) == 0 {
+		return result, nil
+	}
+	for _, stage := range Stages {
+		result[stage] = s.cursors[stage]
+	}
+	return result, nil
+}
+
+// Return cursors saved in DB without changing tracked state, e.g. for another running instance
+func (s *Service) GetSavedCursors() (map[Stage]uint64, error) {
+	cursors, err := s.repository.GetCursors()
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[Stage]uint64)
+	for _, c := range cursors {
+		result[c.Stage] = c.Height
+	}
+	return result, nil
+}
+
+// Set cursors of all stages to height and save them
+func (s *Service) Init(height uint64) error {
+	s.mu.Lock()
+	cursors := make([]*Cursor, len(Stages))
+	for i, stage := range Stages {
+		s.cursors[stage] = height
+		s.completed[stage] = make(map[uint64]struct{})
+		cursors[i] = &Cursor{Stage: stage, Height: height}
+	}
+	s.mu.Unlock()
+
+	return s.repository.SaveCursors(cursors)
+}
+
+// Move cursors of the stages (all stages if none given) back to height and forget everything tracked above it
+func (s *Service) Rewind(height uint64, stages ...Stage) error {
+	if len(stages) == 0 {
+		stages = Stages
+	}
+
+	s.mu.Lock()
+	for _, stage := range stages {
+		if s.cursors[stage] > height {
+			s.cursors[stage] = height
+		}
+		for h := range s.completed[stage] {
+			if h > height {
+				delete(s.completed[stage], h)
+			}
+		}
+		for h, state := range s.heights {
+			if h > height {
+				delete(state.jobs, stage)
+				delete(state.done, stage)
+			}
+		}
+	}
+	rewound := make(map[Stage]bool)
+	for _, stage := range stages {
+		rewound[stage] = true
+	}
+	progress := s.progress[:0]
+	for _, p := range s.progress {
+		if p.BlockID <= height || !rewound[p.Stage] {
+			progress = append(progress, p)
+		}
+	}
+	s.progress = progress
+	s.mu.Unlock()
+
+	return s.repository.Rewind(height, stages)
+}
+
+func (s *Service) GetCursor(stage Stage) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cursors[stage]
+}
+
+// Start tracking of the given root stages and their children for height
+func (s *Service) Begin(height uint64, stages ...Stage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := &heightState{
+		jobs:   make(map[Stage]int),
+		sealed: make(map[Stage]bool),
+		done:   make(map[Stage]bool),
+	}
+	tracked := make(map[Stage]bool)
+	for _, stage := range stages {
+		tracked[stage] = true
+	}
+	for _, stage := range Stages {
+		if tracked[rootOf(stage)] && height > s.cursors[stage] {
+			state.jobs[stage] = 0
+		}
+	}
+	if len(state.jobs) > 0 {
+		s.heights[height] = state
+	}
+}
+
+// Register a job of the stage for height
+func (s *Service) Add(stage Stage, height uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if state, ok := s.heights[height]; ok {
+		if _, tracked := state.jobs[stage]; tracked {
+			state.jobs[stage]++
+		}
+	}
+}
+
+// Mark a job of the stage for height as finished
+func (s *Service) Done(stage Stage, height uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if state, ok := s.heights[height]; ok {
+		if _, tracked := state.jobs[stage]; tracked && state.jobs[stage] > 0 {
+			state.jobs[stage]--
+			s.tryComplete(height, stage)
+		}
+	}
+}
+
+// Mark that all jobs of the root stage for height have been registered
+func (s *Service) Seal(stage Stage, height uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if state, ok := s.heights[height]; ok {
+		state.sealed[stage] = true
+		s.tryComplete(height, stage)
+	}
+}
+
+// Save completed progress and cursors every interval until ctx is done
+func (s *Service) FlushWorker(ctx context.Context, interval time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		if err := s.Flush(); err != nil {
+			s.logger.Error(err)
+		}
+	}
+}
+
+func (s *Service) Flush() error {
+	s.mu.Lock()
+	var progress []*Progress
+	for _, p := range s.progress {
+		if p.BlockID > s.cursors[p.Stage] {
+			progress = append(progress, p)
+		}
+	}
+	s.progress = nil
+	var cursors []*Cursor
+	for stage := range s.dirty {
+		cursors = append(cursors, &Cursor{Stage: stage, Height: s.cursors[stage]})
+	}
+	s.dirty = make(map[Stage]bool)
+	s.mu.Unlock()
+
+	if err := s.repository.SaveProgress(progress); err != nil {
+		return err
+	}
+	if err := s.repository.SaveCursors(cursors); err != nil {
+		return err
+	}
+	return s.repository.DeleteCompactedProgress()
+}
+
+func (s *Service) tryComplete(height uint64, stage Stage) {
+	state, ok := s.heights[height]
+	if !ok {
+		return
+	}
+	jobs, tracked := state.jobs[stage]
+	if !tracked || state.done[stage] || jobs > 0 {
+		return
+	}
+	if parent, ok := parents[stage]; ok {
+		if _, parentTracked := state.jobs[parent]; parentTracked && !state.done[parent] {
+			return
+		}
+	} else if !state.sealed[stage] {
+		return
+	}
+
+	state.done[stage] = true
+	s.markCompleted(stage, height)
+	s.progress = append(s.progress, &Progress{BlockID: height, Stage: stage})
+
+	for child, parent := range parents {
+		if parent == stage {
+			s.tryComplete(height, child)
+		}
+	}
+
+	if len(state.done) == len(state.jobs) {
+		delete(s.heights, height)
+	}
+}
+
+func (s *Service) markCompleted(stage Stage, height uint64) {
+	if height <= s.cursors[stage] {
+		return
+	}
+	s.completed[stage][height] = struct{}{}
+	for {
+		next := s.cursors[stage] + 1
+		if _, ok := s.completed[stage][next]; !ok {
+			break
+		}
+		delete(s.completed[stage], next)
+		s.cursors[stage] = next
+		s.dirty[stage] = true
+	}
+}
+
+func rootOf(stage Stage) Stage {
+	for {
+		parent, ok := parents[stage]
+		if !ok {
+			return stage
+		}
+		stage = parent
+	}
+}
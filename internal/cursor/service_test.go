@@ -0,0 +1,53 @@
+package cursor
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestCursorAdvancesContiguously(t *testing.T) {
+	s := NewService(nil, logrus.NewEntry(logrus.New()))
+
+	s.Begin(1, StageTxs)
+	s.Begin(2, StageTxs)
+
+	s.Add(StageTxs, 2)
+	s.Seal(StageTxs, 2)
+	s.Done(StageTxs, 2)
+	if s.GetCursor(StageTxs) != 0 {
+		t.Error("Cursor must stay at 0 until height 1 is completed but now ", s.GetCursor(StageTxs))
+	}
+
+	s.Add(StageTxs, 1)
+	s.Seal(StageTxs, 1)
+	s.Done(StageTxs, 1)
+	if s.GetCursor(StageTxs) != 2 {
+		t.Error("Cursor must be 2 but now ", s.GetCursor(StageTxs))
+	}
+}
+
+func TestChildStageWaitsForParent(t *testing.T) {
+	s := NewService(nil, logrus.NewEntry(logrus.New()))
+
+	s.Begin(1, StageTxs)
+	s.Add(StageTxs, 1)
+	s.Add(StageTxOutputs, 1)
+	s.Add(StageAddressIndex, 1)
+	s.Done(StageTxOutputs, 1)
+	s.Done(StageAddressIndex, 1)
+	if s.GetCursor(StageTxOutputs) != 0 || s.GetCursor(StageAddressIndex) != 0 {
+		t.Error("Child stages must not be completed before txs stage")
+	}
+
+	s.Seal(StageTxs, 1)
+	s.Done(StageTxs, 1)
+	for _, stage := range []Stage{StageTxs, StageTxOutputs, StageTxValidators, StageAddressIndex} {
+		if s.GetCursor(stage) != 1 {
+			t.Error("Cursor of ", stage, " must be 1 but now ", s.GetCursor(stage))
+		}
+	}
+	if s.GetCursor(StageEvents) != 0 {
+		t.Error("Events stage has not been tracked but cursor moved to ", s.GetCursor(StageEvents))
+	}
+}
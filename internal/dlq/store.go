@@ -0,0 +1,119 @@
+package dlq
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger"
+	"github.com/noah-blockchain/noah-extender/internal/failure"
+)
+
+var keyPrefix = []byte("dlq/")
+
+// Failed job kept in dead-letter store
+type Entry struct {
+	Key       []byte          `json:"-"`
+	Stage     failure.Stage   `json:"stage"`
+	Height    uint64          `json:"height"`
+	Error     string          `json:"error"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Unmarshal payload of the job into v
+func (e *Entry) DecodePayload(v interface{}) error {
+	if len(e.Payload) == 0 {
+		return fmt.Errorf("job of stage %s at height %d has no payload", e.Stage, e.Height)
+	}
+	return json.Unmarshal(e.Payload, v)
+}
+
+// Store keeps failed jobs in badger, entries are ordered by stage and height
+type Store struct {
+	db *badger.DB
+}
+
+func NewStore(db *badger.DB) *Store {
+	return &Store{
+		db: db,
+	}
+}
+
+// Save failed job, payload is serialized to JSON and may be nil
+func (s *Store) Put(stage failure.Stage, height uint64, payload interface{}, jobErr error) error {
+	entry := &Entry{
+		Stage:     stage,
+		Height:    height,
+		Error:     jobErr.Error(),
+		CreatedAt: time.Now(),
+	}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		entry.Payload = data
+	}
+
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	key := []byte(fmt.Sprintf("%s%s/%020d/%d", keyPrefix, stage, height, entry.CreatedAt.UnixNano()))
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, value)
+	})
+}
+
+// Return entries of the stage, all entries if stage is empty
+func (s *Store) List(stage failure.Stage) ([]*Entry, error) {
+	prefix := stagePrefix(stage)
+	var entries []*Entry
+	err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			entry := new(Entry)
+			if err = json.Unmarshal(value, entry); err != nil {
+				return err
+			}
+			entry.Key = item.KeyCopy(nil)
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+func (s *Store) Delete(entry *Entry) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(entry.Key)
+	})
+}
+
+// Delete entries of the stage, all entries if stage is empty. Returns count of deleted entries.
+func (s *Store) Purge(stage failure.Stage) (int, error) {
+	entries, err := s.List(stage)
+	if err != nil {
+		return 0, err
+	}
+	for i, entry := range entries {
+		if err = s.Delete(entry); err != nil {
+			return i, err
+		}
+	}
+	return len(entries), nil
+}
+
+func stagePrefix(stage failure.Stage) []byte {
+	if stage == "" {
+		return keyPrefix
+	}
+	return []byte(fmt.Sprintf("%s%s/", keyPrefix, stage))
+}
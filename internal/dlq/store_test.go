@@ -0,0 +1,75 @@
+package dlq
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+	"github.com/noah-blockchain/noah-extender/internal/failure"
+)
+
+func newTestStore(t *testing.T) (*Store, func()) {
+	dir, err := ioutil.TempDir("", "dlq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := badger.Open(badger.DefaultOptions(dir).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewStore(db), func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestStore(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	if err := s.Put(failure.StageCoins, 0, []string{"ABC"}, errors.New("node is down")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(failure.StageTxs, 20, nil, errors.New("malformed tx")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(failure.StageTxs, 10, nil, errors.New("malformed tx")); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := s.List(failure.StageTxs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Height != 10 || entries[1].Height != 20 {
+		t.Fatal("Entries of the stage must be ordered by height ", entries)
+	}
+	if entries[0].Error != "malformed tx" {
+		t.Error("Error of the job must be kept")
+	}
+
+	entries, err = s.List(failure.StageCoins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var symbols []string
+	if len(entries) != 1 || entries[0].DecodePayload(&symbols) != nil || len(symbols) != 1 || symbols[0] != "ABC" {
+		t.Error("Payload of the job must be kept")
+	}
+
+	if err = s.Delete(entries[0]); err != nil {
+		t.Fatal(err)
+	}
+	count, err := s.Purge("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Error("All remaining entries must be purged, purged ", count)
+	}
+	if entries, _ = s.List(""); len(entries) != 0 {
+		t.Error("Store must be empty after purge")
+	}
+}
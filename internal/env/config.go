@@ -1,32 +1,242 @@
 package env
 
 import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// Source of the effective value of a setting
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
 )
 
-func getEnv(key string, defaultVal string) string {
-	if value, exists := os.LookupEnv(key); exists {
+const redacted = "******"
+
+// Settings which are redacted when they are printed
+var secretSettings = map[string]bool{
+	"db_password": true,
+	"ws_api_key":  true,
+	"admin_token": true,
+}
+
+// Effective value of a setting
+type Setting struct {
+	Name   string
+	Value  string
+	Source string
+}
+
+// Value with secrets and passwords of URLs replaced
+func (s Setting) Redacted() string {
+	if s.Value == "" {
+		return ""
+	}
+	if secretSettings[s.Name] {
+		return redacted
+	}
+	items := strings.Split(s.Value, ",")
+	for i, item := range items {
+		items[i] = redactURL(item)
+	}
+	return strings.Join(items, ",")
+}
+
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
 		return value
 	}
+	if password, ok := u.User.Password(); ok && password != "" {
+		return strings.Replace(value, ":"+password+"@", ":"+redacted+"@", 1)
+	}
+	return value
+}
 
-	return defaultVal
+// Settings layered as flags set on command line, env vars, config file and defaults.
+// Invalid values are collected, so all of them are reported at once.
+type settings struct {
+	file      map[string]string
+	used      map[string]bool
+	effective []Setting
+	errs      []string
 }
 
-func getEnvAsInt(name string, defaultVal int) int {
-	valueStr := getEnv(name, "")
-	if value, err := strconv.Atoi(valueStr); err == nil {
-		return value
+func newSettings(configFile string) (*settings, error) {
+	s := &settings{
+		file: make(map[string]string),
+		used: make(map[string]bool),
+	}
+	if configFile == "" {
+		return s, nil
+	}
+	values, err := readConfigFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+	s.file = values
+	return s, nil
+}
+
+// Set flags which are not set on command line from the config file
+func (s *settings) applyFlags(names []string) {
+	onCommandLine := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		onCommandLine[f.Name] = true
+	})
+	for _, name := range names {
+		source := SourceDefault
+		value, inFile := s.fromFile(name)
+		if onCommandLine[name] {
+			source = SourceFlag
+		} else if inFile {
+			source = SourceFile
+			if err := flag.Set(name, value); err != nil {
+				s.errs = append(s.errs, fmt.Sprintf("%s: %s", name, err))
+			}
+		}
+		s.add(name, flag.Lookup(name).Value.String(), source)
 	}
+}
 
+func (s *settings) getEnv(name, key string, defaultVal string) string {
+	if value := os.Getenv(key); value != "" {
+		s.add(name, value, SourceEnv)
+		return value
+	}
+	if value, ok := s.fromFile(name); ok {
+		s.add(name, value, SourceFile)
+		return value
+	}
+	s.add(name, defaultVal, SourceDefault)
 	return defaultVal
 }
 
-func getEnvAsBool(name string, defaultVal bool) bool {
-	valStr := getEnv(name, "")
-	if val, err := strconv.ParseBool(valStr); err == nil {
-		return val
+func (s *settings) getEnvAsInt(name, key string, defaultVal int) int {
+	valueStr := s.getEnv(name, key, strconv.Itoa(defaultVal))
+	value, err := strconv.Atoi(valueStr)
+	if err != nil {
+		s.errs = append(s.errs, fmt.Sprintf("%s: invalid integer %q", name, valueStr))
+		return defaultVal
 	}
+	return value
+}
 
-	return defaultVal
+func (s *settings) getEnvAsBool(name, key string, defaultVal bool) bool {
+	valueStr := s.getEnv(name, key, strconv.FormatBool(defaultVal))
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		s.errs = append(s.errs, fmt.Sprintf("%s: invalid boolean %q", name, valueStr))
+		return defaultVal
+	}
+	return value
+}
+
+func (s *settings) fromFile(name string) (string, bool) {
+	value, ok := s.file[name]
+	if ok {
+		s.used[name] = true
+	}
+	return value, ok
+}
+
+func (s *settings) add(name, value, source string) {
+	s.effective = append(s.effective, Setting{Name: name, Value: value, Source: source})
+}
+
+// Effective settings sorted by name
+func (s *settings) list() []Setting {
+	list := make([]Setting, len(s.effective))
+	copy(list, s.effective)
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// Return invalid values and unknown settings of the config file
+func (s *settings) err() error {
+	errs := s.errs
+	for name := range s.file {
+		if !s.used[name] {
+			errs = append(errs, fmt.Sprintf("unknown setting %q in config file", name))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.Strings(errs)
+	return fmt.Errorf("invalid configuration: %s", strings.Join(errs, "; "))
+}
+
+// Read flat YAML or TOML config file, lists are joined by comma
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make(map[string]interface{})
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		_, err = toml.Decode(string(data), &raw)
+	default:
+		return nil, fmt.Errorf("unknown config file format %q, expected .yaml, .yml or .toml", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	values := make(map[string]string, len(raw))
+	for name, value := range raw {
+		if values[name], err = configValue(value); err != nil {
+			return nil, fmt.Errorf("%s: %s: %s", path, name, err)
+		}
+	}
+	return values, nil
+}
+
+func configValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			switch item.(type) {
+			case []interface{}, map[interface{}]interface{}, map[string]interface{}:
+				return "", fmt.Errorf("list items must be scalar")
+			}
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ","), nil
+	case map[interface{}]interface{}, map[string]interface{}:
+		return "", fmt.Errorf("nested settings are not supported")
+	}
+	return fmt.Sprint(value), nil
+}
+
+// Split comma separated value, empty items are skipped
+func splitList(value string) []string {
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
 }
@@ -3,11 +3,73 @@ package env
 import (
 	"flag"
 	"os"
+	"time"
 
 	"github.com/noah-blockchain/coinExplorer-tools/models"
+	"github.com/noah-blockchain/noah-extender/internal/bulk"
+	"github.com/noah-blockchain/noah-extender/internal/failure"
 )
 
-func New() *models.ExtenderEnvironment {
+// Environment extends shared extender environment with settings of this service
+type Environment struct {
+	*models.ExtenderEnvironment
+	AtomicCommit    bool
+	ShutdownTimeout time.Duration
+	BackfillWorkers int
+	PrefetchDepth   int
+	// NOAH_API_NODE may contain several comma separated node links
+	NodeApiLinks        []string
+	NodeCrossCheck      bool
+	NodeHealthCheckTime time.Duration
+	// Tendermint websocket of the node, new blocks are polled if it is empty
+	NodeWsLink    string
+	FailurePolicy failure.Policy
+	RetryBackoffs failure.Backoffs
+	// Directory where node responses are recorded, or replayed from instead of the node
+	NodeRecordDir string
+	NodeReplayDir string
+	// Random sample of the state is compared with the node every VerifyInterval, 0 disables it
+	VerifyInterval   time.Duration
+	VerifySampleSize int
+	VerifyRepair     bool
+	// Extender is not ready while it is behind the node by more blocks
+	ReadyMaxLag uint64
+	// Admin endpoints are enabled by the token or client CA, API uses TLS when certificate and key are set
+	AdminToken    string
+	ApiTLSCert    string
+	ApiTLSKey     string
+	ApiClientCA   string
+	AdminAuditLog string
+	AdminInsecure bool
+	// Detailed data older than the last PruneKeepBlocks blocks or PruneKeepDays days is pruned, 0 disables the limit
+	PruneKeepBlocks uint64
+	PruneKeepDays   int
+	PruneBatchSize  uint64
+	PruneInterval   time.Duration
+	// Way transactions, outputs, validator links and rewards are written: insert or copy
+	BulkWriter string
+	// Count of entries kept in every repository cache, 0 means caches are not bounded
+	CacheAddressSize   int
+	CacheCoinSize      int
+	CacheValidatorSize int
+	// Caches are filled on start with up to CacheWarmUpMemory megabytes of coins, validators
+	// and addresses most active within the last CacheWarmUpBlocks blocks
+	CacheWarmUp       bool
+	CacheWarmUpMemory int
+	CacheWarmUpBlocks uint64
+	// Effective settings with their sources, see config print command
+	Settings []Setting
+}
+
+// Read settings from flags, env vars and the optional config file (-config or COIN_EXTENDER_CONFIG) in this order
+// of precedence. Returns error on invalid values together with the environment, so its settings can still be printed.
+// Environment has to be validated by Validate then.
+func New() (*Environment, error) {
+	existing := make(map[string]bool)
+	flag.VisitAll(func(f *flag.Flag) {
+		existing[f.Name] = true
+	})
+
 	appName := flag.String("app_name", "Coin Extender", "App name")
 	baseCoin := flag.String("base_coin", "NOAH", "Base coin symbol")
 	coinsUpdateTime := flag.Int("coins_upd_time", 3600, "Coins update time in minutes")
@@ -28,28 +90,73 @@ func New() *models.ExtenderEnvironment {
 	wrkUpdateTxsIndexTime := flag.Int("wrk_update_txs_index_time", 60, "Time in seconds which worker sleep before the next iteration")
 	rewardAggregateEveryBlocksCount := flag.Int("reward_aggregate_every_blocks_count", 60, "Every X block will be launched reward aggregation")
 	rewardAggregateTimeInterval := flag.String("reward_aggregate_time_interval", "hour", "Rewards aggregation time interval('hour' or 'day')")
+	atomicCommit := flag.Bool("atomic_commit", false, "Save all data of a block within a single DB transaction")
+	shutdownTimeout := flag.Int("shutdown_timeout", 30, "Time in seconds to drain workers on shutdown")
+	backfillWorkers := flag.Int("backfill_workers", 0, "Count of heights fetched concurrently while far behind the node, 0 disables backfill")
+	prefetchDepth := flag.Int("prefetch_depth", 2, "Count of next heights fetched from the node while the current one is handled")
+	nodeCrossCheck := flag.Bool("node_cross_check", false, "Confirm block hash by a second node before the block is saved")
+	nodeHealthCheckTime := flag.Int("node_health_check_time", 10, "Time in seconds between health checks of nodes")
+	failurePolicy := failure.PolicyHalt
+	flag.Var(&failurePolicy, "failure_policy", "Policy of failed jobs: halt, skip or quarantine")
+	retryBackoffs := failure.Backoffs{}
+	flag.Var(retryBackoffs, "retry_backoff", "Backoff of retries by stage as stage=initial:max:attempts, comma separated, stage 'default' applies to others")
+	nodeRecordDir := flag.String("node_record", "", "Directory to record node responses into")
+	nodeReplayDir := flag.String("node_replay", "", "Directory to replay recorded node responses from instead of the node")
+	verifyInterval := flag.Int("verify_interval", 0, "Time in seconds between verifications of a random sample of the state with the node, 0 disables it")
+	verifySampleSize := flag.Int("verify_sample_size", 100, "Count of addresses and coins in a sample of the state to verify")
+	verifyRepair := flag.Bool("verify_repair", false, "Repair values which differ from the node")
+	readyMaxLag := flag.Uint64("ready_max_lag", 10, "Count of blocks the extender may be behind the node and still be ready")
+	adminAuditLog := flag.String("admin_audit_log", "", "File to append audit log of admin actions to, stdout if it is empty")
+	adminInsecure := flag.Bool("admin_insecure", false, "Accept the admin token over plain HTTP when the API has no TLS certificate")
+	pruneKeepBlocks := flag.Uint64("prune_keep_blocks", 0, "Count of the last blocks to keep detailed data of, 0 disables pruning by blocks")
+	pruneKeepDays := flag.Int("prune_keep_days", 0, "Count of the last days to keep detailed data of, 0 disables pruning by days")
+	pruneBatchSize := flag.Uint64("prune_batch_size", 1000, "Count of blocks pruned within a single DB transaction")
+	pruneInterval := flag.Int("prune_interval", 60, "Time in seconds between pruning runs")
+	bulkWriter := flag.String("bulk_writer", bulk.WriterInsert, "Way to write batches of transactions, outputs, validator links and rewards: insert or copy")
+	cacheAddressSize := flag.Int("cache_address_size", 500000, "Count of addresses kept in each address cache, 0 means the cache is not bounded")
+	cacheCoinSize := flag.Int("cache_coin_size", 10000, "Count of coins kept in each coin cache, 0 means the cache is not bounded")
+	cacheValidatorSize := flag.Int("cache_validator_size", 10000, "Count of validators kept in the validator cache, 0 means the cache is not bounded")
+	cacheWarmUp := flag.Bool("cache_warmup", false, "Load coins, validators and the most active addresses into caches on start")
+	cacheWarmUpMemory := flag.Int("cache_warmup_memory", 256, "Approximate memory in megabytes taken by caches loaded on start")
+	cacheWarmUpBlocks := flag.Uint64("cache_warmup_blocks", 100000, "Count of the last blocks in which the most active addresses are counted")
+	dbMinIdleConns := flag.Int("db_min_idle_conns", 10, "Minimum count of idle DB connections")
+	dbPoolSize := flag.Int("db_pool_size", 20, "Maximum count of DB connections")
+
+	var names []string
+	flag.VisitAll(func(f *flag.Flag) {
+		if !existing[f.Name] {
+			names = append(names, f.Name)
+		}
+	})
+	configFile := flag.String("config", os.Getenv("COIN_EXTENDER_CONFIG"), "YAML or TOML config file, env vars and flags override its settings")
 	flag.Parse()
 
+	s, err := newSettings(*configFile)
+	if err != nil {
+		return nil, err
+	}
+	s.applyFlags(names)
+
 	envData := new(models.ExtenderEnvironment)
 
-	envData.DbUser = os.Getenv("DB_USER")
-	envData.DbName = os.Getenv("DB_NAME")
-	envData.DbPassword = os.Getenv("DB_PASSWORD")
-	envData.DbHost = os.Getenv("DB_HOST")
-	envData.DbPort = getEnvAsInt("DB_PORT", 5432)
-	envData.NodeApi = os.Getenv("NOAH_API_NODE")
-	envData.ApiHost = os.Getenv("COIN_EXTENDER_API_HOST")
-	envData.ApiPort = getEnvAsInt("COIN_EXTENDER_API_PORT", 10000)
-	envData.Debug = getEnvAsBool("DEBUG", true)
-	envData.WsHost = os.Getenv("WS_HOST")
-	envData.WsPort = getEnvAsInt("WS_PORT", 8000)
-	envData.WsKey = os.Getenv("WS_API_KEY")
-	envData.NatsAddr = os.Getenv("NATS_ADDR")
-	envData.NatsClusterID = os.Getenv("NATS_CLUSTER_ID")
+	envData.DbUser = s.getEnv("db_user", "DB_USER", "")
+	envData.DbName = s.getEnv("db_name", "DB_NAME", "")
+	envData.DbPassword = s.getEnv("db_password", "DB_PASSWORD", "")
+	envData.DbHost = s.getEnv("db_host", "DB_HOST", "")
+	envData.DbPort = s.getEnvAsInt("db_port", "DB_PORT", 5432)
+	envData.NodeApi = s.getEnv("node_api", "NOAH_API_NODE", "")
+	envData.ApiHost = s.getEnv("api_host", "COIN_EXTENDER_API_HOST", "")
+	envData.ApiPort = s.getEnvAsInt("api_port", "COIN_EXTENDER_API_PORT", 10000)
+	envData.Debug = s.getEnvAsBool("debug", "DEBUG", true)
+	envData.WsHost = s.getEnv("ws_host", "WS_HOST", "")
+	envData.WsPort = s.getEnvAsInt("ws_port", "WS_PORT", 8000)
+	envData.WsKey = s.getEnv("ws_api_key", "WS_API_KEY", "")
+	envData.NatsAddr = s.getEnv("nats_addr", "NATS_ADDR", "")
+	envData.NatsClusterID = s.getEnv("nats_cluster_id", "NATS_CLUSTER_ID", "")
 
 	envData.AppName = *appName
-	envData.DbMinIdleConns = 10
-	envData.DbPoolSize = 20
+	envData.DbMinIdleConns = *dbMinIdleConns
+	envData.DbPoolSize = *dbPoolSize
 	envData.TxChunkSize = *txChunkSize
 	envData.EventsChunkSize = *eventsChunkSize
 	envData.StakeChunkSize = *stakeChunkSize
@@ -70,5 +177,42 @@ func New() *models.ExtenderEnvironment {
 	envData.RewardAggregateEveryBlocksCount = *rewardAggregateEveryBlocksCount
 	envData.RewardAggregateTimeInterval = *rewardAggregateTimeInterval
 
-	return envData
+	result := &Environment{
+		ExtenderEnvironment: envData,
+		AtomicCommit:        *atomicCommit,
+		ShutdownTimeout:     time.Duration(*shutdownTimeout) * time.Second,
+		BackfillWorkers:     *backfillWorkers,
+		PrefetchDepth:       *prefetchDepth,
+		NodeApiLinks:        splitList(envData.NodeApi),
+		NodeCrossCheck:      *nodeCrossCheck,
+		NodeHealthCheckTime: time.Duration(*nodeHealthCheckTime) * time.Second,
+		NodeWsLink:          s.getEnv("node_ws", "NOAH_NODE_WS", ""),
+		FailurePolicy:       failurePolicy,
+		RetryBackoffs:       retryBackoffs,
+		NodeRecordDir:       *nodeRecordDir,
+		NodeReplayDir:       *nodeReplayDir,
+		VerifyInterval:      time.Duration(*verifyInterval) * time.Second,
+		VerifySampleSize:    *verifySampleSize,
+		VerifyRepair:        *verifyRepair,
+		ReadyMaxLag:         *readyMaxLag,
+		AdminToken:          s.getEnv("admin_token", "COIN_EXTENDER_ADMIN_TOKEN", ""),
+		ApiTLSCert:          s.getEnv("api_tls_cert", "COIN_EXTENDER_API_TLS_CERT", ""),
+		ApiTLSKey:           s.getEnv("api_tls_key", "COIN_EXTENDER_API_TLS_KEY", ""),
+		ApiClientCA:         s.getEnv("api_client_ca", "COIN_EXTENDER_API_CLIENT_CA", ""),
+		AdminAuditLog:       *adminAuditLog,
+		AdminInsecure:       *adminInsecure,
+		PruneKeepBlocks:     *pruneKeepBlocks,
+		PruneKeepDays:       *pruneKeepDays,
+		PruneBatchSize:      *pruneBatchSize,
+		PruneInterval:       time.Duration(*pruneInterval) * time.Second,
+		BulkWriter:          *bulkWriter,
+		CacheAddressSize:    *cacheAddressSize,
+		CacheCoinSize:       *cacheCoinSize,
+		CacheValidatorSize:  *cacheValidatorSize,
+		CacheWarmUp:         *cacheWarmUp,
+		CacheWarmUpMemory:   *cacheWarmUpMemory,
+		CacheWarmUpBlocks:   *cacheWarmUpBlocks,
+	}
+	result.Settings = s.list()
+	return result, s.err()
 }
@@ -0,0 +1,103 @@
+package env
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/noah-blockchain/noah-extender/internal/bulk"
+)
+
+// Check that settings are usable, all invalid settings are reported at once
+func (e *Environment) Validate() error {
+	var errs []string
+	required := func(name, value string) {
+		if value == "" {
+			errs = append(errs, name+" is required")
+		}
+	}
+	positive := func(name string, value int) {
+		if value <= 0 {
+			errs = append(errs, fmt.Sprintf("%s must be positive, got %d", name, value))
+		}
+	}
+	notNegative := func(name string, value int) {
+		if value < 0 {
+			errs = append(errs, fmt.Sprintf("%s must not be negative, got %d", name, value))
+		}
+	}
+	port := func(name string, value int) {
+		if value <= 0 || value > 65535 {
+			errs = append(errs, fmt.Sprintf("%s must be a port number, got %d", name, value))
+		}
+	}
+
+	required("db_host", e.DbHost)
+	required("db_name", e.DbName)
+	required("db_user", e.DbUser)
+	port("db_port", e.DbPort)
+	positive("db_pool_size", e.DbPoolSize)
+	notNegative("db_min_idle_conns", e.DbMinIdleConns)
+	if e.DbMinIdleConns > e.DbPoolSize {
+		errs = append(errs, "db_min_idle_conns must not exceed db_pool_size")
+	}
+	// recorded responses are replayed instead of the node
+	if e.NodeReplayDir == "" {
+		required("node_api", e.NodeApi)
+	}
+	port("api_port", e.ApiPort)
+	if (e.ApiTLSCert == "") != (e.ApiTLSKey == "") {
+		errs = append(errs, "api_tls_cert and api_tls_key must be set together")
+	}
+	if e.AdminToken != "" && e.ApiTLSCert == "" && !e.AdminInsecure {
+		errs = append(errs, "admin_token requires api_tls_cert and api_tls_key unless admin_insecure is set")
+	}
+
+	positive("tx_chunk_size", e.TxChunkSize)
+	positive("event_chunk_size", e.EventsChunkSize)
+	positive("stake_chunk_size", e.StakeChunkSize)
+	positive("addr_chunk_size", e.AddrChunkSize)
+	positive("wrk_save_txs_count", e.WrkSaveTxsCount)
+	positive("wrk_save_txs_output_count", e.WrkSaveTxsOutputCount)
+	positive("wrk_save_invtxs_count", e.WrkSaveInvTxsCount)
+	positive("wrk_save_rewards_count", e.WrkSaveRewardsCount)
+	positive("wrk_save_slashes_count", e.WrkSaveSlashesCount)
+	positive("wrk_save_addresses_count", e.WrkSaveAddressesCount)
+	positive("wrk_save_val_tx_count", e.WrkSaveValidatorTxsCount)
+	positive("wrk_upd_balances_count", e.WrkUpdateBalanceCount)
+	positive("wrk_node_balance_count", e.WrkGetBalancesFromNodeCount)
+	positive("wrk_update_txs_index_num_blocks", e.WrkUpdateTxsIndexNumBlocks)
+	positive("wrk_update_txs_index_time", e.WrkUpdateTxsIndexTime)
+	positive("coins_upd_time", e.CoinsUpdateTime)
+	positive("reward_aggregate_every_blocks_count", e.RewardAggregateEveryBlocksCount)
+	if e.RewardAggregateTimeInterval != "hour" && e.RewardAggregateTimeInterval != "day" {
+		errs = append(errs, fmt.Sprintf("reward_aggregate_time_interval must be hour or day, got %q", e.RewardAggregateTimeInterval))
+	}
+
+	notNegative("backfill_workers", e.BackfillWorkers)
+	notNegative("prefetch_depth", e.PrefetchDepth)
+	notNegative("shutdown_timeout", int(e.ShutdownTimeout/time.Second))
+	positive("node_health_check_time", int(e.NodeHealthCheckTime/time.Second))
+	notNegative("verify_interval", int(e.VerifyInterval/time.Second))
+	positive("verify_sample_size", e.VerifySampleSize)
+	notNegative("prune_keep_days", e.PruneKeepDays)
+	positive("prune_batch_size", int(e.PruneBatchSize))
+	positive("prune_interval", int(e.PruneInterval/time.Second))
+	notNegative("cache_address_size", e.CacheAddressSize)
+	notNegative("cache_coin_size", e.CacheCoinSize)
+	notNegative("cache_validator_size", e.CacheValidatorSize)
+	if e.CacheWarmUp {
+		positive("cache_warmup_memory", e.CacheWarmUpMemory)
+		positive("cache_warmup_blocks", int(e.CacheWarmUpBlocks))
+	}
+	if e.BulkWriter != bulk.WriterInsert && e.BulkWriter != bulk.WriterCopy {
+		errs = append(errs, fmt.Sprintf("bulk_writer must be insert or copy, got %q", e.BulkWriter))
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.Strings(errs)
+	return fmt.Errorf("invalid configuration: %s", strings.Join(errs, "; "))
+}
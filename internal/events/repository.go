@@ -5,11 +5,16 @@ import (
 	"strings"
 
 	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
 	"github.com/noah-blockchain/coinExplorer-tools/models"
+	"github.com/noah-blockchain/noah-extender/internal/bulk"
 )
 
+var rewardColumns = []string{"block_id", "address_id", "validator_id", "role", "amount", "created_at"}
+
 type Repository struct {
-	db *pg.DB
+	db   orm.DB
+	copy bool
 }
 
 func NewRepository(db *pg.DB) *Repository {
@@ -18,7 +23,33 @@ func NewRepository(db *pg.DB) *Repository {
 	}
 }
 
+// Return copy of repository which executes all queries within DB transaction
+func (r *Repository) WithTx(tx *pg.Tx) *Repository {
+	return &Repository{
+		db:   tx,
+		copy: r.copy,
+	}
+}
+
+// Write rewards with COPY instead of multi-row INSERT
+func (r *Repository) UseCopy() {
+	r.copy = true
+}
+
 func (r *Repository) SaveRewards(rewards []*models.Reward) error {
+	if r.copy {
+		rows := new(bulk.Rows)
+		for _, reward := range rewards {
+			rows.Uint(reward.BlockID)
+			rows.Uint(reward.AddressID)
+			rows.Uint(reward.ValidatorID)
+			rows.String(reward.Role)
+			rows.String(reward.Amount)
+			rows.Time(reward.CreatedAt)
+			rows.End()
+		}
+		return bulk.Copy(r.db, "rewards", rewardColumns, rows)
+	}
 	var args []interface{}
 	for _, reward := range rewards {
 		args = append(args, reward)
@@ -67,3 +98,39 @@ ON CONFLICT (time_id,address_id,validator_id,role)
 	`, aggregateInterval, beforeBlockId, aggregateInterval)
 	return err
 }
+
+// Aggregate again all intervals which contain blocks from..to
+func (r *Repository) RebuildAggregatedRewards(aggregateInterval string, fromBlockId, toBlockId uint64) error {
+
+	if strings.Compare(aggregateInterval, "hour") != 0 && strings.Compare(aggregateInterval, "day") != 0 {
+		return errors.New("not acceptable aggregate interval")
+	}
+
+	_, err := r.db.Exec(`
+delete from aggregated_rewards
+where time_id between (select date_trunc(?0, created_at) from blocks where id = ?1)
+                  and (select date_trunc(?0, created_at) from blocks where id = ?2);
+insert into aggregated_rewards (time_id,
+                                from_block_id,
+                                to_block_id,
+                                address_id,
+                                validator_id,
+                                role,
+                                amount) (select date_trunc(?0, b.created_at) as time_id,
+                                                min(r.block_id)                   as from_block_id,
+                                                max(r.block_id)                   as to_block_id,
+                                                r.address_id,
+                                                r.validator_id,
+                                                r.role,
+                                                sum(r.amount)                     as amount
+                                         from rewards r
+                                                inner join blocks b on r.block_id = b.id
+                                         where date_trunc(?0, b.created_at) between
+                                                   (select date_trunc(?0, created_at) from blocks where id = ?1)
+                                                   and (select date_trunc(?0, created_at) from blocks where id = ?2)
+                                         group by r.address_id, r.validator_id, r.role, date_trunc(?0, b.created_at))
+ON CONFLICT (time_id,address_id,validator_id,role)
+            DO UPDATE set amount = EXCLUDED.amount, to_block_id = EXCLUDED.to_block_id, from_block_id = EXCLUDED.from_block_id;
+	`, aggregateInterval, fromBlockId, toBlockId)
+	return err
+}
@@ -3,11 +3,14 @@ package events
 import (
 	"math"
 
+	"github.com/go-pg/pg"
 	"github.com/noah-blockchain/coinExplorer-tools/helpers"
 	"github.com/noah-blockchain/coinExplorer-tools/models"
 	"github.com/noah-blockchain/noah-extender/internal/address"
 	"github.com/noah-blockchain/noah-extender/internal/balance"
 	"github.com/noah-blockchain/noah-extender/internal/coin"
+	"github.com/noah-blockchain/noah-extender/internal/cursor"
+	"github.com/noah-blockchain/noah-extender/internal/failure"
 	"github.com/noah-blockchain/noah-extender/internal/validator"
 	"github.com/noah-blockchain/noah-node-go-api/responses"
 	"github.com/sirupsen/logrus"
@@ -21,6 +24,8 @@ type Service struct {
 	coinRepository      *coin.Repository
 	coinService         *coin.Service
 	balanceRepository   *balance.Repository
+	cursorService       *cursor.Service
+	failureService      *failure.Service
 	jobSaveRewards      chan []*models.Reward
 	jobSaveSlashes      chan []*models.Slash
 	logger              *logrus.Entry
@@ -28,7 +33,8 @@ type Service struct {
 
 func NewService(env *models.ExtenderEnvironment, repository *Repository, validatorRepository *validator.Repository,
 	addressRepository *address.Repository, coinRepository *coin.Repository, coinService *coin.Service,
-	balanceRepository *balance.Repository, logger *logrus.Entry) *Service {
+	balanceRepository *balance.Repository, cursorService *cursor.Service, failureService *failure.Service,
+	logger *logrus.Entry) *Service {
 	return &Service{
 		env:                 env,
 		repository:          repository,
@@ -37,41 +43,97 @@ func NewService(env *models.ExtenderEnvironment, repository *Repository, validat
 		coinRepository:      coinRepository,
 		coinService:         coinService,
 		balanceRepository:   balanceRepository,
+		cursorService:       cursorService,
+		failureService:      failureService,
 		jobSaveRewards:      make(chan []*models.Reward, env.WrkSaveRewardsCount),
 		jobSaveSlashes:      make(chan []*models.Slash, env.WrkSaveSlashesCount),
 		logger:              logger,
 	}
 }
 
+type blockEvents struct {
+	rewards           []*models.Reward
+	slashes           []*models.Slash
+	liquidatedCoins   []string
+	coinsForUpdateMap map[string]struct{}
+}
+
 //Handle response and save block to DB
 func (s *Service) HandleEventResponse(blockHeight uint64, response *responses.EventsResponse) error {
-	var (
-		rewards           []*models.Reward
-		slashes           []*models.Slash
-		coinsForUpdateMap = make(map[string]struct{})
-	)
+	events, err := s.extractEvents(blockHeight, response)
+	if err != nil {
+		return err
+	}
 
-	for _, event := range response.Result.Events {
-		if event.Type == "noah/CoinLiquidationEvent" {
+	if err = s.liquidateCoins(blockHeight, events.liquidatedCoins, s.balanceRepository, s.coinRepository); err != nil {
+		return err
+	}
 
-			coinId, err := s.coinRepository.FindIdBySymbol(event.Value.Coin)
+	if len(events.coinsForUpdateMap) > 0 {
+		s.coinService.GetUpdateCoinsFromCoinsMapJobChannel() <- coin.BlockCoins{Height: blockHeight, Coins: events.coinsForUpdateMap}
+	}
 
-			err = s.balanceRepository.DeleteByCoinId(coinId)
+	if len(events.rewards) > 0 {
+		s.saveRewards(events.rewards)
+	}
 
-			if err != nil {
-				s.logger.WithFields(logrus.Fields{
-					"coin": event.Value.Coin,
-				}).Error(err)
-				return err
-			}
+	if len(events.slashes) > 0 {
+		s.saveSlashes(events.slashes)
+	}
 
-			err = s.coinRepository.DeleteBySymbol(event.Value.Coin)
-			if err != nil {
-				s.logger.WithFields(logrus.Fields{
-					"coin": event.Value.Coin,
-				}).Error(err)
-				return err
-			}
+	return nil
+}
+
+//Handle response, liquidate coins and save rewards and slashes within DB transaction.
+//Returns coins which have to be updated, they are sent to coin service after commit.
+func (s *Service) HandleEventResponseTx(tx *pg.Tx, blockHeight uint64, response *responses.EventsResponse) (map[string]struct{}, error) {
+	events, err := s.extractEvents(blockHeight, response)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.liquidateCoins(blockHeight, events.liquidatedCoins, s.balanceRepository.WithTx(tx), s.coinRepository.WithTx(tx)); err != nil {
+		return nil, err
+	}
+
+	repository := s.repository.WithTx(tx)
+	if len(events.rewards) > 0 {
+		if err = repository.SaveRewards(events.rewards); err != nil {
+			return nil, err
+		}
+	}
+	if len(events.slashes) > 0 {
+		if err = repository.SaveSlashes(events.slashes); err != nil {
+			return nil, err
+		}
+	}
+	return events.coinsForUpdateMap, nil
+}
+
+// Save again rewards and slashes of the height reindexed by the range reindex.
+// Coins are not liquidated and not updated, so coins and balances created after the height are kept.
+func (s *Service) ReindexEventResponse(blockHeight uint64, response *responses.EventsResponse) error {
+	events, err := s.extractEvents(blockHeight, response)
+	if err != nil {
+		return err
+	}
+	if len(events.rewards) > 0 {
+		if err = s.repository.SaveRewards(events.rewards); err != nil {
+			return err
+		}
+	}
+	if len(events.slashes) > 0 {
+		return s.repository.SaveSlashes(events.slashes)
+	}
+	return nil
+}
+
+func (s *Service) extractEvents(blockHeight uint64, response *responses.EventsResponse) (*blockEvents, error) {
+	events := &blockEvents{coinsForUpdateMap: make(map[string]struct{})}
+
+	for _, event := range response.Result.Events {
+		if event.Type == "noah/CoinLiquidationEvent" {
+			events.liquidatedCoins = append(events.liquidatedCoins, event.Value.Coin)
 			continue
 		}
 		if event.Type == "noah/UnbondEvent" {
@@ -83,7 +145,7 @@ func (s *Service) HandleEventResponse(blockHeight uint64, response *responses.Ev
 			s.logger.WithFields(logrus.Fields{
 				"address": event.Value.Address,
 			}).Error(err)
-			return err
+			return nil, err
 		}
 
 		validatorId, err := s.validatorRepository.FindIdByPk(helpers.RemovePrefix(event.Value.ValidatorPubKey))
@@ -91,12 +153,12 @@ func (s *Service) HandleEventResponse(blockHeight uint64, response *responses.Ev
 			s.logger.WithFields(logrus.Fields{
 				"public_key": event.Value.ValidatorPubKey,
 			}).Error(err)
-			return err
+			return nil, err
 		}
 
 		switch event.Type {
 		case models.RewardEvent:
-			rewards = append(rewards, &models.Reward{
+			events.rewards = append(events.rewards, &models.Reward{
 				BlockID:     blockHeight,
 				Role:        event.Value.Role,
 				Amount:      event.Value.Amount,
@@ -105,14 +167,14 @@ func (s *Service) HandleEventResponse(blockHeight uint64, response *responses.Ev
 			})
 
 		case models.SlashEvent:
-			coinsForUpdateMap[event.Value.Coin] = struct{}{}
+			events.coinsForUpdateMap[event.Value.Coin] = struct{}{}
 			coinId, err := s.coinRepository.FindIdBySymbol(event.Value.Coin)
 			if err != nil {
 				s.logger.Error(err)
-				return err
+				return nil, err
 			}
 
-			slashes = append(slashes, &models.Slash{
+			events.slashes = append(events.slashes, &models.Slash{
 				BlockID:     blockHeight,
 				CoinID:      coinId,
 				Amount:      event.Value.Amount,
@@ -122,18 +184,32 @@ func (s *Service) HandleEventResponse(blockHeight uint64, response *responses.Ev
 		}
 	}
 
-	if len(coinsForUpdateMap) > 0 {
-		s.coinService.GetUpdateCoinsFromCoinsMapJobChannel() <- coinsForUpdateMap
-	}
+	return events, nil
+}
 
-	if len(rewards) > 0 {
-		s.saveRewards(rewards)
-	}
+func (s *Service) liquidateCoins(height uint64, symbols []string, balanceRepository *balance.Repository,
+	coinRepository *coin.Repository) error {
 
-	if len(slashes) > 0 {
-		s.saveSlashes(slashes)
-	}
+	for _, symbol := range symbols {
+		coinId, err := coinRepository.FindIdBySymbol(symbol)
+
+		err = balanceRepository.DeleteByCoinId(coinId, height)
+
+		if err != nil {
+			s.logger.WithFields(logrus.Fields{
+				"coin": symbol,
+			}).Error(err)
+			return err
+		}
 
+		err = coinRepository.DeleteBySymbol(symbol)
+		if err != nil {
+			s.logger.WithFields(logrus.Fields{
+				"coin": symbol,
+			}).Error(err)
+			return err
+		}
+	}
 	return nil
 }
 
@@ -147,21 +223,46 @@ func (s *Service) GetSaveSlashesJobChannel() chan []*models.Slash {
 
 func (s *Service) SaveRewardsWorker(jobs <-chan []*models.Reward) {
 	for rewards := range jobs {
-		err := s.repository.SaveRewards(rewards)
-		helpers.HandleError(err)
+		err := s.failureService.DoPayload(failure.StageRewards, rewards[0].BlockID, rewards, func() error {
+			return s.repository.SaveRewards(rewards)
+		})
+		if err == failure.ErrHalted {
+			continue
+		}
+		s.cursorService.Done(cursor.StageEvents, rewards[0].BlockID)
 	}
 }
 
 func (s *Service) SaveSlashesWorker(jobs <-chan []*models.Slash) {
 	for slashes := range jobs {
-		err := s.repository.SaveSlashes(slashes)
-		helpers.HandleError(err)
+		err := s.failureService.DoPayload(failure.StageSlashes, slashes[0].BlockID, slashes, func() error {
+			return s.repository.SaveSlashes(slashes)
+		})
+		if err == failure.ErrHalted {
+			continue
+		}
+		s.cursorService.Done(cursor.StageEvents, slashes[0].BlockID)
 	}
 }
 
+// Save rewards of a job from dead-letter store
+func (s *Service) ReplayRewards(rewards []*models.Reward) error {
+	return s.repository.SaveRewards(rewards)
+}
+
+// Save slashes of a job from dead-letter store
+func (s *Service) ReplaySlashes(slashes []*models.Slash) error {
+	return s.repository.SaveSlashes(slashes)
+}
+
 func (s *Service) AggregateRewards(aggregateInterval string, beforeBlockId uint64) {
-	err := s.repository.AggregateRewards(aggregateInterval, beforeBlockId)
-	helpers.HandleError(err)
+	_ = s.failureService.Do(failure.StageAggregation, beforeBlockId, func() error {
+		return s.repository.AggregateRewards(aggregateInterval, beforeBlockId)
+	})
+}
+
+func (s *Service) RebuildAggregatedRewards(aggregateInterval string, fromBlockId, toBlockId uint64) error {
+	return s.repository.RebuildAggregatedRewards(aggregateInterval, fromBlockId, toBlockId)
 }
 
 func (s *Service) saveRewards(rewards []*models.Reward) {
@@ -172,6 +273,7 @@ func (s *Service) saveRewards(rewards []*models.Reward) {
 		if end > len(rewards) {
 			end = len(rewards)
 		}
+		s.cursorService.Add(cursor.StageEvents, rewards[start].BlockID)
 		s.GetSaveRewardsJobChannel() <- rewards[start:end]
 	}
 }
@@ -184,6 +286,7 @@ func (s *Service) saveSlashes(slashes []*models.Slash) {
 		if end > len(slashes) {
 			end = len(slashes)
 		}
+		s.cursorService.Add(cursor.StageEvents, slashes[start].BlockID)
 		s.GetSaveSlashesJobChannel() <- slashes[start:end]
 	}
 }
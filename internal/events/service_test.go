@@ -0,0 +1,26 @@
+package events
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/noah-blockchain/noah-node-go-api/responses"
+	"github.com/sirupsen/logrus"
+)
+
+func TestReindexEventResponseKeepsLiquidatedCoins(t *testing.T) {
+	response := new(responses.EventsResponse)
+	err := json.Unmarshal([]byte(`{"result": {"events": [{"type": "noah/CoinLiquidationEvent", "value": {"coin": "TESTCOIN"}}]}}`), response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	// coin and balance repositories are nil, liquidation of the coin would panic on them
+	service := &Service{logger: logrus.NewEntry(logger)}
+
+	if err = service.ReindexEventResponse(10, response); err != nil {
+		t.Error("Liquidation event must be skipped by reindex, got ", err)
+	}
+}
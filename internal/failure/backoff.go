@@ -0,0 +1,99 @@
+package failure
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultStage = "default"
+
+var DefaultBackoff = Backoff{Initial: 500 * time.Millisecond, Max: 30 * time.Second, Attempts: 5}
+
+// Exponential backoff of retries of transient errors
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+	// Count of attempts including the first one, 0 retries transient errors until they succeed
+	Attempts int
+}
+
+// Delay before the retry which follows the attempt, attempts are counted from 0
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := b.Initial
+	for i := 0; i < attempt && delay < b.Max; i++ {
+		delay *= 2
+	}
+	if delay > b.Max {
+		return b.Max
+	}
+	return delay
+}
+
+// Backoffs by stage, implements flag.Value with "stage=initial:max:attempts,..." format.
+// Stage "default" is used for stages which are not listed.
+type Backoffs map[Stage]Backoff
+
+func (b Backoffs) Get(stage Stage) Backoff {
+	if backoff, ok := b[stage]; ok {
+		return backoff
+	}
+	if backoff, ok := b[defaultStage]; ok {
+		return backoff
+	}
+	return DefaultBackoff
+}
+
+func (b Backoffs) String() string {
+	items := make([]string, 0, len(b))
+	for stage, backoff := range b {
+		items = append(items, fmt.Sprintf("%s=%s:%s:%d", stage, backoff.Initial, backoff.Max, backoff.Attempts))
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+func (b Backoffs) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		stage, backoff, err := parseBackoff(item)
+		if err != nil {
+			return err
+		}
+		b[stage] = backoff
+	}
+	return nil
+}
+
+func parseBackoff(item string) (Stage, Backoff, error) {
+	parts := strings.SplitN(item, "=", 2)
+	if len(parts) != 2 {
+		return "", Backoff{}, fmt.Errorf("invalid backoff %q, expected stage=initial:max:attempts", item)
+	}
+	values := strings.Split(parts[1], ":")
+	if len(values) != 3 {
+		return "", Backoff{}, fmt.Errorf("invalid backoff %q, expected stage=initial:max:attempts", item)
+	}
+
+	var (
+		backoff Backoff
+		err     error
+	)
+	if backoff.Initial, err = time.ParseDuration(values[0]); err != nil {
+		return "", Backoff{}, err
+	}
+	if backoff.Max, err = time.ParseDuration(values[1]); err != nil {
+		return "", Backoff{}, err
+	}
+	if backoff.Attempts, err = strconv.Atoi(values[2]); err != nil {
+		return "", Backoff{}, err
+	}
+	if backoff.Initial <= 0 || backoff.Max < backoff.Initial || backoff.Attempts < 0 {
+		return "", Backoff{}, fmt.Errorf("invalid backoff %q", item)
+	}
+	return Stage(strings.TrimSpace(parts[0])), backoff, nil
+}
@@ -0,0 +1,109 @@
+package failure
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/go-pg/pg"
+)
+
+type Kind int
+
+const (
+	// Transient errors may disappear on retry, e.g. lost connection or a row which is saved by another worker
+	KindTransient Kind = iota
+	// Permanent errors fail the same way on every retry, e.g. malformed data
+	KindPermanent
+)
+
+var (
+	// Returned when a failed job has been dropped according to the failure policy
+	ErrSkipped = errors.New("job has been skipped after failure")
+	// Returned when the extender has to stop ingestion according to the failure policy
+	ErrHalted = errors.New("ingestion has been halted after failure")
+)
+
+// SQLSTATE classes of errors which may succeed on retry
+var transientPgClasses = []string{
+	"08", // connection exception
+	"40", // transaction rollback, e.g. deadlock
+	"53", // insufficient resources
+	"57", // operator intervention, e.g. server shutdown
+}
+
+const (
+	pgForeignKeyViolation = "23503"
+	pgLockNotAvailable    = "55P03"
+)
+
+type classifiedError struct {
+	kind Kind
+	err  error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Cause() error {
+	return e.err
+}
+
+// Mark error as transient regardless of its type
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{kind: KindTransient, err: err}
+}
+
+// Mark error as permanent regardless of its type
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{kind: KindPermanent, err: err}
+}
+
+// Classify error by its type, unknown errors are considered transient
+func Classify(err error) Kind {
+	for err != nil {
+		switch e := err.(type) {
+		case *classifiedError:
+			return e.kind
+		case pg.Error:
+			return classifyPg(e.Field('C'))
+		case net.Error:
+			return KindTransient
+		case *strconv.NumError, *json.SyntaxError, *json.UnmarshalTypeError:
+			return KindPermanent
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return KindTransient
+		}
+
+		causer, ok := err.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		err = causer.Cause()
+	}
+	return KindTransient
+}
+
+func classifyPg(code string) Kind {
+	// referenced row may be saved by another worker later
+	if code == pgForeignKeyViolation || code == pgLockNotAvailable {
+		return KindTransient
+	}
+	for _, class := range transientPgClasses {
+		if strings.HasPrefix(code, class) {
+			return KindTransient
+		}
+	}
+	return KindPermanent
+}
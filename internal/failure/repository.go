@@ -0,0 +1,32 @@
+package failure
+
+import (
+	"time"
+
+	"github.com/go-pg/pg"
+)
+
+type Failure struct {
+	tableName struct{}  `sql:"ingestion_failures"`
+	ID        uint64    `sql:"id,pk"`
+	BlockID   uint64    `sql:",notnull"`
+	Stage     Stage     `sql:",notnull"`
+	Policy    Policy    `sql:",notnull"`
+	Error     string    `sql:",notnull"`
+	CreatedAt time.Time `sql:"default:now()"`
+}
+
+type Repository struct {
+	db *pg.DB
+}
+
+func NewRepository(db *pg.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+func (r *Repository) Save(failure *Failure) error {
+	_, err := r.db.Model(failure).Insert()
+	return err
+}
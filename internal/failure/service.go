@@ -0,0 +1,191 @@
+package failure
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/noah-blockchain/noah-extender/internal/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// Stage of block handling, used to pick backoff and to record failures
+type Stage string
+
+const (
+	StageAddresses    Stage = "addresses"
+	StageBlock        Stage = "block"
+	StageValidators   Stage = "validators"
+	StageTxs          Stage = "txs"
+	StageTxOutputs    Stage = "tx_outputs"
+	StageTxValidators Stage = "tx_validators"
+	StageCoins        Stage = "coins"
+	StageEvents       Stage = "events"
+	StageRewards      Stage = "rewards"
+	StageSlashes      Stage = "slashes"
+	StageBalances     Stage = "balances"
+	StageAggregation  Stage = "aggregation"
+)
+
+// Policy of permanent failures and of transient failures which have run out of attempts.
+// Implements flag.Value.
+type Policy string
+
+const (
+	// Stop ingestion, the failed height is ingested again after restart
+	PolicyHalt Policy = "halt"
+	// Drop the failed job and record it
+	PolicySkip Policy = "skip"
+	// Drop the failed job and all further jobs of its stage at its height and record the height,
+	// so the stage of the height can be reindexed as a whole. Jobs of other stages at the height still run.
+	PolicyQuarantine Policy = "quarantine"
+)
+
+func (p *Policy) String() string {
+	return string(*p)
+}
+
+func (p *Policy) Set(value string) error {
+	switch Policy(value) {
+	case PolicyHalt, PolicySkip, PolicyQuarantine:
+		*p = Policy(value)
+		return nil
+	}
+	return fmt.Errorf("unknown failure policy %q, expected halt, skip or quarantine", value)
+}
+
+// Stage of the height whose jobs are dropped by quarantine policy
+type quarantineKey struct {
+	stage  Stage
+	height uint64
+}
+
+// Store of dropped jobs, so they can be replayed later
+type DeadLetterStore interface {
+	Put(stage Stage, height uint64, payload interface{}, err error) error
+}
+
+// Repository and dead-letter store may be nil, failures are only logged then
+type Service struct {
+	policy      Policy
+	backoffs    Backoffs
+	repository  *Repository
+	deadLetters DeadLetterStore
+	quarantined map[quarantineKey]struct{}
+	mu          sync.RWMutex
+	halted      chan struct{}
+	haltOnce    sync.Once
+	logger      *logrus.Entry
+}
+
+func NewService(policy Policy, backoffs Backoffs, repository *Repository, deadLetters DeadLetterStore,
+	logger *logrus.Entry) *Service {
+	return &Service{
+		policy:      policy,
+		backoffs:    backoffs,
+		repository:  repository,
+		deadLetters: deadLetters,
+		quarantined: make(map[quarantineKey]struct{}),
+		halted:      make(chan struct{}),
+		logger:      logger,
+	}
+}
+
+// Closed when ingestion has to be stopped
+func (s *Service) Halted() <-chan struct{} {
+	return s.halted
+}
+
+// Run job of the stage, transient errors are retried with backoff of the stage.
+// Returns nil on success, ErrSkipped when the job has been dropped and ErrHalted when ingestion has to be stopped.
+// Job must be safe to run again after it has failed.
+func (s *Service) Do(stage Stage, height uint64, job func() error) error {
+	return s.DoPayload(stage, height, nil, job)
+}
+
+// Same as Do, payload describes the job and is kept in dead-letter store if the job is dropped
+func (s *Service) DoPayload(stage Stage, height uint64, payload interface{}, job func() error) error {
+	if s.isQuarantined(stage, height) {
+		return ErrSkipped
+	}
+
+	start := time.Now()
+	defer func() {
+		metrics.StageDuration.WithLabelValues(string(stage)).Observe(time.Since(start).Seconds())
+	}()
+
+	backoff := s.backoffs.Get(stage)
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = job(); err == nil {
+			return nil
+		}
+		if Classify(err) == KindPermanent || (backoff.Attempts > 0 && attempt+1 >= backoff.Attempts) {
+			break
+		}
+
+		delay := backoff.Delay(attempt)
+		metrics.JobRetries.WithLabelValues(string(stage)).Inc()
+		s.logger.WithFields(logrus.Fields{
+			"stage":   stage,
+			"height":  height,
+			"attempt": attempt + 1,
+			"delay":   delay,
+		}).Warn(err)
+
+		select {
+		case <-s.halted:
+			return ErrHalted
+		case <-time.After(delay):
+		}
+	}
+	return s.fail(stage, height, payload, err)
+}
+
+func (s *Service) fail(stage Stage, height uint64, payload interface{}, err error) error {
+	metrics.JobFailures.WithLabelValues(string(stage), string(s.policy)).Inc()
+	s.logger.WithFields(logrus.Fields{
+		"stage":  stage,
+		"height": height,
+		"policy": s.policy,
+	}).Error(err)
+
+	if s.policy == PolicyHalt {
+		s.haltOnce.Do(func() { close(s.halted) })
+		return ErrHalted
+	}
+	if s.policy == PolicyQuarantine {
+		s.mu.Lock()
+		s.quarantined[quarantineKey{stage: stage, height: height}] = struct{}{}
+		s.mu.Unlock()
+	}
+
+	logger := s.logger.WithFields(logrus.Fields{
+		"stage":  stage,
+		"height": height,
+	})
+	if s.deadLetters != nil {
+		if putErr := s.deadLetters.Put(stage, height, payload, err); putErr != nil {
+			logger.Error(putErr)
+		}
+	}
+	if s.repository != nil {
+		failure := &Failure{
+			BlockID: height,
+			Stage:   stage,
+			Policy:  s.policy,
+			Error:   err.Error(),
+		}
+		if saveErr := s.repository.Save(failure); saveErr != nil {
+			logger.Error(saveErr)
+		}
+	}
+	return ErrSkipped
+}
+
+func (s *Service) isQuarantined(stage Stage, height uint64) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.quarantined[quarantineKey{stage: stage, height: height}]
+	return ok
+}
@@ -0,0 +1,148 @@
+package failure
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestService(policy Policy) *Service {
+	backoffs := Backoffs{defaultStage: {Initial: time.Millisecond, Max: time.Millisecond, Attempts: 3}}
+	return NewService(policy, backoffs, nil, nil, logrus.NewEntry(logrus.New()))
+}
+
+func TestClassify(t *testing.T) {
+	_, parseErr := strconv.ParseUint("x", 10, 64)
+	cases := []struct {
+		err  error
+		kind Kind
+	}{
+		{errors.New("unknown"), KindTransient},
+		{parseErr, KindPermanent},
+		{Permanent(errors.New("bad tx")), KindPermanent},
+		{Transient(parseErr), KindTransient},
+	}
+	for _, c := range cases {
+		if kind := Classify(c.err); kind != c.kind {
+			t.Errorf("%v classified as %d, expected %d", c.err, kind, c.kind)
+		}
+	}
+	if classifyPg("23505") != KindPermanent || classifyPg("40P01") != KindTransient || classifyPg("23503") != KindTransient {
+		t.Error("Postgres errors are classified wrong")
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Initial: time.Second, Max: 5 * time.Second}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for attempt, delay := range expected {
+		if d := b.Delay(attempt); d != delay {
+			t.Errorf("Delay of attempt %d is %s, expected %s", attempt, d, delay)
+		}
+	}
+}
+
+func TestBackoffsSet(t *testing.T) {
+	b := Backoffs{}
+	if err := b.Set("default=1s:10s:3, txs=100ms:1s:0"); err != nil {
+		t.Fatal(err)
+	}
+	if b.Get(StageTxs) != (Backoff{Initial: 100 * time.Millisecond, Max: time.Second, Attempts: 0}) {
+		t.Error("Backoff of the stage is parsed wrong ", b.Get(StageTxs))
+	}
+	if b.Get(StageEvents).Attempts != 3 {
+		t.Error("Default backoff must be used for stages which are not listed")
+	}
+	if err := b.Set("txs=1s"); err == nil {
+		t.Error("Invalid backoff must be rejected")
+	}
+}
+
+func TestDoRetriesTransientErrors(t *testing.T) {
+	s := newTestService(PolicyHalt)
+	calls := 0
+	err := s.Do(StageTxs, 1, func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("connection reset")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("Job must succeed on the third attempt, err %v, calls %d", err, calls)
+	}
+}
+
+func TestDoSkipsPermanentErrors(t *testing.T) {
+	s := newTestService(PolicySkip)
+	calls := 0
+	err := s.Do(StageTxs, 1, func() error {
+		calls++
+		return Permanent(errors.New("malformed tx"))
+	})
+	if err != ErrSkipped || calls != 1 {
+		t.Errorf("Permanent error must not be retried, err %v, calls %d", err, calls)
+	}
+	if err = s.Do(StageEvents, 1, func() error { return nil }); err != nil {
+		t.Error("Other jobs of the height must run with skip policy")
+	}
+}
+
+func TestDoQuarantinesStageOfHeight(t *testing.T) {
+	s := newTestService(PolicyQuarantine)
+	s.Do(StageTxs, 5, func() error { return Permanent(errors.New("malformed tx")) })
+
+	called := false
+	err := s.Do(StageTxs, 5, func() error {
+		called = true
+		return nil
+	})
+	if err != ErrSkipped || called {
+		t.Error("Jobs of quarantined stage of the height must be skipped")
+	}
+	if err = s.Do(StageEvents, 5, func() error { return nil }); err != nil {
+		t.Error("Jobs of other stages of the height must run")
+	}
+	if err = s.Do(StageTxs, 6, func() error { return nil }); err != nil {
+		t.Error("Jobs of other heights must run")
+	}
+}
+
+func TestDoHalts(t *testing.T) {
+	s := newTestService(PolicyHalt)
+	err := s.Do(StageTxs, 1, func() error { return errors.New("connection reset") })
+	if err != ErrHalted {
+		t.Error("Job which has run out of attempts must halt ingestion, err ", err)
+	}
+	select {
+	case <-s.Halted():
+	default:
+		t.Error("Halted channel must be closed")
+	}
+}
+
+type fakeDeadLetters struct {
+	stages   []Stage
+	payloads []interface{}
+}
+
+func (f *fakeDeadLetters) Put(stage Stage, height uint64, payload interface{}, err error) error {
+	f.stages = append(f.stages, stage)
+	f.payloads = append(f.payloads, payload)
+	return nil
+}
+
+func TestDoPayloadKeepsDroppedJobs(t *testing.T) {
+	deadLetters := new(fakeDeadLetters)
+	s := NewService(PolicySkip, Backoffs{}, nil, deadLetters, logrus.NewEntry(logrus.New()))
+	err := s.DoPayload(StageCoins, 0, []string{"ABC"}, func() error { return Permanent(errors.New("no coin")) })
+	if err != ErrSkipped {
+		t.Fatal("Job must be skipped, err ", err)
+	}
+	if len(deadLetters.stages) != 1 || deadLetters.stages[0] != StageCoins || deadLetters.payloads[0] == nil {
+		t.Error("Dropped job must be kept in dead-letter store")
+	}
+}
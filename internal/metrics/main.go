@@ -0,0 +1,232 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "coin_extender"
+
+var (
+	// Result label is "hit" when the height has been fetched before it was requested,
+	// "wait" when it was still being fetched and "miss" when it has not been prefetched
+	PrefetchRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "prefetch",
+		Name:      "requests_total",
+		Help:      "Count of heights requested from prefetch stage by result",
+	}, []string{"result"})
+
+	PrefetchPending = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "prefetch",
+		Name:      "pending_heights",
+		Help:      "Count of heights which are fetched or waiting to be handled",
+	})
+
+	NodeHealthy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "node",
+		Name:      "healthy",
+		Help:      "1 if the node responds and is not catching up, 0 otherwise",
+	}, []string{"node"})
+
+	NodeLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "node",
+		Name:      "lag_blocks",
+		Help:      "Count of blocks the node is behind the highest node",
+	}, []string{"node"})
+
+	NodeFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "node",
+		Name:      "failures_total",
+		Help:      "Count of failed requests to the node",
+	}, []string{"node"})
+
+	NodeHashMismatches = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "node",
+		Name:      "hash_mismatches_total",
+		Help:      "Count of blocks with different hashes on cross-checked nodes",
+	})
+
+	NodeUnconfirmedBlocks = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "node",
+		Name:      "unconfirmed_blocks_total",
+		Help:      "Count of blocks returned without cross-check because no other node has them",
+	})
+
+	JobRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "jobs",
+		Name:      "retries_total",
+		Help:      "Count of retries of failed jobs by stage",
+	}, []string{"stage"})
+
+	// Policy label is the failure policy which has been applied to the job
+	JobFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "jobs",
+		Name:      "failures_total",
+		Help:      "Count of jobs which have failed permanently or run out of attempts by stage",
+	}, []string{"stage", "policy"})
+
+	// Kind label is one of balances, stakes, coins and validators
+	VerifyChecked = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "verify",
+		Name:      "checked_total",
+		Help:      "Count of values compared with the node by kind",
+	}, []string{"kind"})
+
+	VerifyMismatches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "verify",
+		Name:      "mismatches_total",
+		Help:      "Count of values which differ from the node by kind",
+	}, []string{"kind"})
+
+	VerifyLastMismatches = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "verify",
+		Name:      "last_mismatches",
+		Help:      "Count of values which differ from the node in the last verification by kind",
+	}, []string{"kind"})
+
+	VerifyRepairs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "verify",
+		Name:      "repairs_total",
+		Help:      "Count of mismatched values which have been repaired by kind",
+	}, []string{"kind"})
+
+	VerifyHeight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "verify",
+		Name:      "height",
+		Help:      "Height of the last verification",
+	})
+
+	BlocksProcessed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ingestion",
+		Name:      "blocks_processed_total",
+		Help:      "Count of heights which have been handled",
+	})
+
+	IngestionHeight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "ingestion",
+		Name:      "height",
+		Help:      "Last handled height",
+	})
+
+	IngestionLag = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "ingestion",
+		Name:      "lag_blocks",
+		Help:      "Count of blocks the last handled height is behind the node",
+	})
+
+	// Duration includes retries of the job
+	StageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "jobs",
+		Name:      "duration_seconds",
+		Help:      "Duration of jobs of a height by stage",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+	}, []string{"stage"})
+
+	// Method label is the method of node API client, e.g. GetBlock
+	NodeRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "node",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of requests to the node by method",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 3, 10),
+	}, []string{"method"})
+
+	NodeRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "node",
+		Name:      "request_errors_total",
+		Help:      "Count of requests to the node which have failed or returned an error by method",
+	}, []string{"method"})
+
+	QueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "jobs",
+		Name:      "queue_depth",
+		Help:      "Count of jobs waiting in the channel by queue",
+	}, []string{"queue"})
+
+	// Result label is "hit" when the value has been found in memory and "miss" when it has been read from DB
+	CacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "requests_total",
+		Help:      "Count of lookups in repository caches by cache and result",
+	}, []string{"cache", "result"})
+
+	CacheEntries = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "entries",
+		Help:      "Count of entries in repository caches by cache",
+	}, []string{"cache"})
+
+	CacheEvictions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "evictions_total",
+		Help:      "Count of least recently used entries evicted from full repository caches by cache",
+	}, []string{"cache"})
+
+	DbInsertDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "insert_duration_seconds",
+		Help:      "Duration of insert queries by table",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 4, 10),
+	}, []string{"table"})
+
+	PrunedHeight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "prune",
+		Name:      "height",
+		Help:      "Height up to which detailed data has been pruned",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		PrefetchRequests,
+		PrefetchPending,
+		NodeHealthy,
+		NodeLag,
+		NodeFailures,
+		NodeHashMismatches,
+		NodeUnconfirmedBlocks,
+		JobRetries,
+		JobFailures,
+		VerifyChecked,
+		VerifyMismatches,
+		VerifyLastMismatches,
+		VerifyRepairs,
+		VerifyHeight,
+		BlocksProcessed,
+		IngestionHeight,
+		IngestionLag,
+		StageDuration,
+		NodeRequestDuration,
+		NodeRequestErrors,
+		QueueDepth,
+		CacheRequests,
+		CacheEntries,
+		CacheEvictions,
+		DbInsertDuration,
+		PrunedHeight,
+	)
+}
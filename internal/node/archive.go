@@ -0,0 +1,91 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+var ErrNotRecorded = errors.New("response has not been recorded")
+
+// Archive keeps node responses as JSON files in a directory, one file per key
+type Archive struct {
+	dir string
+}
+
+func NewArchive(dir string) *Archive {
+	return &Archive{
+		dir: dir,
+	}
+}
+
+// Save response under the key, existing response is replaced
+func (a *Archive) Save(key string, response interface{}) error {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+
+	path := a.path(key)
+	if err = os.MkdirAll(filepath.Dir(path), 0774); err != nil {
+		return err
+	}
+	// write to a temporary file first, so a concurrent reader never sees a partial response
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load response saved under the key into response, returns ErrNotRecorded if there is no such key
+func (a *Archive) Load(key string, response interface{}) error {
+	data, err := ioutil.ReadFile(a.path(key))
+	if os.IsNotExist(err) {
+		return ErrNotRecorded
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, response)
+}
+
+func (a *Archive) path(key string) string {
+	return filepath.Join(a.dir, filepath.FromSlash(key)+".json")
+}
+
+func statusKey() string {
+	return "status"
+}
+
+func blockKey(height uint64) string {
+	return fmt.Sprintf("block/%d", height)
+}
+
+func eventsKey(height uint64) string {
+	return fmt.Sprintf("events/%d", height)
+}
+
+func candidatesKey(height uint64, stakes bool) string {
+	return fmt.Sprintf("candidates/%d-%t", height, stakes)
+}
+
+func coinKey(symbol string) string {
+	return "coin/" + symbol
+}
+
+func balanceKey(height uint64, address string) string {
+	return fmt.Sprintf("balances/%d/%s", height, address)
+}
@@ -0,0 +1,18 @@
+package node
+
+import (
+	"github.com/noah-blockchain/noah-node-go-api"
+	"github.com/noah-blockchain/noah-node-go-api/responses"
+)
+
+// Client is the part of node API used by extender
+type Client interface {
+	GetStatus() (*responses.StatusResponse, error)
+	GetBlock(height uint64) (*responses.BlockResponse, error)
+	GetBlockEvents(height uint64) (*responses.EventsResponse, error)
+	GetCandidates(height uint64, stakes bool) (*responses.BlockCandidatesResponse, error)
+	GetCoinInfo(symbol string) (*responses.CoinInfoResponse, error)
+	GetAddresses(addresses []string, height uint64) (*responses.BalancesResponse, error)
+}
+
+var _ Client = (*noah_node_go_api.NoahNodeApi)(nil)
@@ -0,0 +1,69 @@
+package node
+
+import (
+	"time"
+
+	"github.com/noah-blockchain/noah-extender/internal/metrics"
+	"github.com/noah-blockchain/noah-node-go-api/responses"
+)
+
+var _ Client = (*Metered)(nil)
+
+// Metered passes requests to the node and measures their duration and errors by method.
+// Responses with error data are counted as errors as well.
+type Metered struct {
+	client Client
+}
+
+func NewMetered(client Client) *Metered {
+	return &Metered{client: client}
+}
+
+func (m *Metered) GetStatus() (*responses.StatusResponse, error) {
+	start := time.Now()
+	response, err := m.client.GetStatus()
+	observe("GetStatus", start, err != nil || response.Error != nil)
+	return response, err
+}
+
+func (m *Metered) GetBlock(height uint64) (*responses.BlockResponse, error) {
+	start := time.Now()
+	response, err := m.client.GetBlock(height)
+	observe("GetBlock", start, err != nil || response.Error != nil)
+	return response, err
+}
+
+func (m *Metered) GetBlockEvents(height uint64) (*responses.EventsResponse, error) {
+	start := time.Now()
+	response, err := m.client.GetBlockEvents(height)
+	observe("GetBlockEvents", start, err != nil || response.Error != nil)
+	return response, err
+}
+
+func (m *Metered) GetCandidates(height uint64, stakes bool) (*responses.BlockCandidatesResponse, error) {
+	start := time.Now()
+	response, err := m.client.GetCandidates(height, stakes)
+	observe("GetCandidates", start, err != nil || response.Error != nil)
+	return response, err
+}
+
+func (m *Metered) GetCoinInfo(symbol string) (*responses.CoinInfoResponse, error) {
+	start := time.Now()
+	response, err := m.client.GetCoinInfo(symbol)
+	observe("GetCoinInfo", start, err != nil || response.Error != nil)
+	return response, err
+}
+
+func (m *Metered) GetAddresses(addresses []string, height uint64) (*responses.BalancesResponse, error) {
+	start := time.Now()
+	response, err := m.client.GetAddresses(addresses, height)
+	observe("GetAddresses", start, err != nil || response.Error != nil)
+	return response, err
+}
+
+func observe(method string, start time.Time, failed bool) {
+	metrics.NodeRequestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if failed {
+		metrics.NodeRequestErrors.WithLabelValues(method).Inc()
+	}
+}
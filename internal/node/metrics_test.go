@@ -0,0 +1,26 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/noah-blockchain/noah-extender/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMeteredCountsErrors(t *testing.T) {
+	c := &fakeClient{height: 100}
+	m := NewMetered(c)
+	errors := metrics.NodeRequestErrors.WithLabelValues("GetBlock")
+	before := testutil.ToFloat64(errors)
+
+	if _, err := m.GetBlock(1); err != nil {
+		t.Fatal(err)
+	}
+	c.down = true
+	if _, err := m.GetBlock(1); err == nil {
+		t.Fatal("Error of the node must be returned")
+	}
+	if testutil.ToFloat64(errors)-before != 1 {
+		t.Error("Only failed request must be counted as error")
+	}
+}
@@ -0,0 +1,272 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/noah-blockchain/noah-extender/internal/metrics"
+	"github.com/noah-blockchain/noah-node-go-api/responses"
+	"github.com/sirupsen/logrus"
+)
+
+var ErrBlockHashMismatch = errors.New("block hash differs between nodes")
+
+type nodeState struct {
+	link    string
+	client  Client
+	healthy bool
+	height  uint64
+}
+
+// MultiClient sends every request to the healthy node with the highest block
+// and fails over to the other nodes on errors.
+type MultiClient struct {
+	nodes        []*nodeState
+	mu           sync.RWMutex
+	retries      int
+	retryTimeout time.Duration
+	crossCheck   bool
+	logger       *logrus.Entry
+}
+
+// Clients must be given in the same order as links, the order is used as priority of nodes with equal height.
+// If crossCheck is set, block hash is confirmed by a second node before the block is returned.
+func NewMultiClient(links []string, clients []Client, retries int, retryTimeout time.Duration, crossCheck bool,
+	logger *logrus.Entry) *MultiClient {
+
+	nodes := make([]*nodeState, len(clients))
+	for i, client := range clients {
+		nodes[i] = &nodeState{link: links[i], client: client, healthy: true}
+	}
+	return &MultiClient{
+		nodes:        nodes,
+		retries:      retries,
+		retryTimeout: retryTimeout,
+		crossCheck:   crossCheck,
+		logger:       logger,
+	}
+}
+
+// Check health of all nodes every interval until ctx is done
+func (c *MultiClient) HealthWorker(ctx context.Context, interval time.Duration) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		c.CheckHealth()
+	}
+}
+
+// Request status of all nodes and update their health and lag
+func (c *MultiClient) CheckHealth() {
+	type status struct {
+		healthy bool
+		height  uint64
+	}
+	statuses := make([]status, len(c.nodes))
+
+	var wg sync.WaitGroup
+	for i, n := range c.nodes {
+		wg.Add(1)
+		go func(i int, client Client) {
+			defer wg.Done()
+			response, err := client.GetStatus()
+			if err != nil || response.Error != nil {
+				return
+			}
+			height, err := strconv.ParseUint(response.Result.LatestBlockHeight, 10, 64)
+			if err != nil {
+				return
+			}
+			statuses[i] = status{healthy: !response.Result.TmStatus.SyncInfo.CatchingUp, height: height}
+		}(i, n.client)
+	}
+	wg.Wait()
+
+	var maxHeight uint64
+	for _, s := range statuses {
+		if s.height > maxHeight {
+			maxHeight = s.height
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, n := range c.nodes {
+		if n.healthy != statuses[i].healthy {
+			c.logger.WithFields(logrus.Fields{"node": n.link, "healthy": statuses[i].healthy}).Warn("node health changed")
+		}
+		n.healthy = statuses[i].healthy
+		n.height = statuses[i].height
+
+		metrics.NodeHealthy.WithLabelValues(n.link).Set(boolToFloat(n.healthy))
+		metrics.NodeLag.WithLabelValues(n.link).Set(float64(maxHeight - n.height))
+	}
+}
+
+func (c *MultiClient) GetStatus() (*responses.StatusResponse, error) {
+	var response *responses.StatusResponse
+	_, err := c.do(func(client Client) (err error) {
+		response, err = client.GetStatus()
+		return err
+	})
+	return response, err
+}
+
+func (c *MultiClient) GetBlock(height uint64) (*responses.BlockResponse, error) {
+	var response *responses.BlockResponse
+	used, err := c.do(func(client Client) (err error) {
+		response, err = client.GetBlock(height)
+		return err
+	})
+	if err != nil || !c.crossCheck || response.Error != nil {
+		return response, err
+	}
+	return response, c.confirmBlock(height, response, used)
+}
+
+func (c *MultiClient) GetBlockEvents(height uint64) (*responses.EventsResponse, error) {
+	var response *responses.EventsResponse
+	_, err := c.do(func(client Client) (err error) {
+		response, err = client.GetBlockEvents(height)
+		return err
+	})
+	return response, err
+}
+
+func (c *MultiClient) GetCandidates(height uint64, stakes bool) (*responses.BlockCandidatesResponse, error) {
+	var response *responses.BlockCandidatesResponse
+	_, err := c.do(func(client Client) (err error) {
+		response, err = client.GetCandidates(height, stakes)
+		return err
+	})
+	return response, err
+}
+
+func (c *MultiClient) GetCoinInfo(symbol string) (*responses.CoinInfoResponse, error) {
+	var response *responses.CoinInfoResponse
+	_, err := c.do(func(client Client) (err error) {
+		response, err = client.GetCoinInfo(symbol)
+		return err
+	})
+	return response, err
+}
+
+func (c *MultiClient) GetAddresses(addresses []string, height uint64) (*responses.BalancesResponse, error) {
+	var response *responses.BalancesResponse
+	_, err := c.do(func(client Client) (err error) {
+		response, err = client.GetAddresses(addresses, height)
+		return err
+	})
+	return response, err
+}
+
+// Send request to nodes in order of preference until one of them succeeds.
+// Returns the node which has responded.
+func (c *MultiClient) do(request func(client Client) error) (*nodeState, error) {
+	var err error
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(c.retryTimeout)
+		}
+		for _, n := range c.candidates() {
+			if err = request(n.client); err == nil {
+				return n, nil
+			}
+			c.markFailed(n, err)
+		}
+	}
+	return nil, err
+}
+
+// Compare block hash with another node which has the block.
+// Block is accepted if no other node has it, such blocks are counted by the unconfirmed blocks metric.
+func (c *MultiClient) confirmBlock(height uint64, response *responses.BlockResponse, used *nodeState) error {
+	for _, n := range c.candidates() {
+		if n == used || !c.isHealthy(n) {
+			continue
+		}
+		other, err := n.client.GetBlock(height)
+		if err != nil {
+			c.markFailed(n, err)
+			continue
+		}
+		if other.Error != nil {
+			continue
+		}
+		if other.Result.Hash != response.Result.Hash {
+			metrics.NodeHashMismatches.Inc()
+			c.logger.WithFields(logrus.Fields{
+				"height":     height,
+				"node":       used.link,
+				"hash":       response.Result.Hash,
+				"other_node": n.link,
+				"other_hash": other.Result.Hash,
+			}).Error(ErrBlockHashMismatch)
+			return ErrBlockHashMismatch
+		}
+		return nil
+	}
+	metrics.NodeUnconfirmedBlocks.Inc()
+	c.logger.WithField("height", height).Warn("block hash has not been confirmed by another node")
+	return nil
+}
+
+// Return healthy nodes ordered by height, unhealthy nodes are used as the last resort
+func (c *MultiClient) candidates() []*nodeState {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	type candidate struct {
+		node    *nodeState
+		healthy bool
+		height  uint64
+	}
+	list := make([]candidate, len(c.nodes))
+	for i, n := range c.nodes {
+		list[i] = candidate{node: n, healthy: n.healthy, height: n.height}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].healthy != list[j].healthy {
+			return list[i].healthy
+		}
+		return list[i].height > list[j].height
+	})
+
+	nodes := make([]*nodeState, len(list))
+	for i, candidate := range list {
+		nodes[i] = candidate.node
+	}
+	return nodes
+}
+
+func (c *MultiClient) markFailed(n *nodeState, err error) {
+	metrics.NodeFailures.WithLabelValues(n.link).Inc()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n.healthy {
+		c.logger.WithField("node", n.link).Warn(err)
+	}
+	n.healthy = false
+	metrics.NodeHealthy.WithLabelValues(n.link).Set(0)
+}
+
+func (c *MultiClient) isHealthy(n *nodeState) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return n.healthy
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,136 @@
+package node
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/noah-blockchain/noah-extender/internal/metrics"
+	"github.com/noah-blockchain/noah-node-go-api/responses"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+type fakeClient struct {
+	height uint64
+	hash   string
+	down   bool
+	calls  int
+}
+
+func (c *fakeClient) GetStatus() (*responses.StatusResponse, error) {
+	if c.down {
+		return nil, errors.New("node is down")
+	}
+	response := new(responses.StatusResponse)
+	response.Result.LatestBlockHeight = strconv.FormatUint(c.height, 10)
+	return response, nil
+}
+
+func (c *fakeClient) GetBlock(height uint64) (*responses.BlockResponse, error) {
+	c.calls++
+	if c.down {
+		return nil, errors.New("node is down")
+	}
+	response := new(responses.BlockResponse)
+	response.Result.Height = strconv.FormatUint(height, 10)
+	response.Result.Hash = c.hash
+	return response, nil
+}
+
+func (c *fakeClient) GetBlockEvents(height uint64) (*responses.EventsResponse, error) {
+	return new(responses.EventsResponse), nil
+}
+
+func (c *fakeClient) GetCandidates(height uint64, stakes bool) (*responses.BlockCandidatesResponse, error) {
+	return new(responses.BlockCandidatesResponse), nil
+}
+
+func (c *fakeClient) GetCoinInfo(symbol string) (*responses.CoinInfoResponse, error) {
+	return new(responses.CoinInfoResponse), nil
+}
+
+func (c *fakeClient) GetAddresses(addresses []string, height uint64) (*responses.BalancesResponse, error) {
+	return new(responses.BalancesResponse), nil
+}
+
+func newTestMultiClient(crossCheck bool, clients ...*fakeClient) *MultiClient {
+	links := make([]string, len(clients))
+	list := make([]Client, len(clients))
+	for i, c := range clients {
+		links[i] = "node" + strconv.Itoa(i)
+		list[i] = c
+	}
+	return NewMultiClient(links, list, 0, 0, crossCheck, logrus.NewEntry(logrus.New()))
+}
+
+func TestMultiClientPrefersHighestHealthyNode(t *testing.T) {
+	lagging := &fakeClient{height: 90, hash: "A"}
+	highest := &fakeClient{height: 100, hash: "A"}
+	c := newTestMultiClient(false, lagging, highest)
+	c.CheckHealth()
+
+	if _, err := c.GetBlock(95); err != nil {
+		t.Fatal(err)
+	}
+	if highest.calls != 1 || lagging.calls != 0 {
+		t.Error("Block must be requested from the highest node")
+	}
+}
+
+func TestMultiClientFailsOver(t *testing.T) {
+	first := &fakeClient{height: 100, hash: "A"}
+	second := &fakeClient{height: 100, hash: "A"}
+	c := newTestMultiClient(false, first, second)
+	c.CheckHealth()
+	first.down = true
+
+	response, err := c.GetBlock(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Result.Hash != "A" || second.calls != 1 {
+		t.Error("Block must be requested from the second node")
+	}
+	if c.isHealthy(c.nodes[0]) {
+		t.Error("Failed node must be marked unhealthy")
+	}
+
+	if _, err = c.GetBlock(11); err != nil {
+		t.Fatal(err)
+	}
+	if first.calls != 1 {
+		t.Error("Unhealthy node must be requested after healthy ones, calls ", first.calls)
+	}
+}
+
+func TestMultiClientCrossCheck(t *testing.T) {
+	first := &fakeClient{height: 100, hash: "A"}
+	second := &fakeClient{height: 100, hash: "A"}
+	c := newTestMultiClient(true, first, second)
+	c.CheckHealth()
+
+	if _, err := c.GetBlock(10); err != nil {
+		t.Fatal(err)
+	}
+
+	second.hash = "B"
+	if _, err := c.GetBlock(10); err != ErrBlockHashMismatch {
+		t.Error("Different hashes must be reported but error is ", err)
+	}
+}
+
+func TestMultiClientCrossCheckUnconfirmed(t *testing.T) {
+	first := &fakeClient{height: 100, hash: "A"}
+	second := &fakeClient{height: 100, hash: "A", down: true}
+	c := newTestMultiClient(true, first, second)
+	c.CheckHealth()
+
+	before := testutil.ToFloat64(metrics.NodeUnconfirmedBlocks)
+	if _, err := c.GetBlock(10); err != nil {
+		t.Fatal(err)
+	}
+	if testutil.ToFloat64(metrics.NodeUnconfirmedBlocks)-before != 1 {
+		t.Error("Block which has not been confirmed by another node must be counted")
+	}
+}
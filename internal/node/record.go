@@ -0,0 +1,81 @@
+package node
+
+import (
+	"github.com/noah-blockchain/noah-node-go-api/responses"
+	"github.com/sirupsen/logrus"
+)
+
+var _ Client = (*Recorder)(nil)
+
+// Recorder passes requests to the node and saves successful responses into the archive.
+// Failed responses are not saved, so the archive keeps the last successful response of every request.
+type Recorder struct {
+	client  Client
+	archive *Archive
+	logger  *logrus.Entry
+}
+
+func NewRecorder(client Client, archive *Archive, logger *logrus.Entry) *Recorder {
+	return &Recorder{
+		client:  client,
+		archive: archive,
+		logger:  logger,
+	}
+}
+
+func (r *Recorder) GetStatus() (*responses.StatusResponse, error) {
+	response, err := r.client.GetStatus()
+	if err == nil && response.Error == nil {
+		r.save(statusKey(), response)
+	}
+	return response, err
+}
+
+func (r *Recorder) GetBlock(height uint64) (*responses.BlockResponse, error) {
+	response, err := r.client.GetBlock(height)
+	if err == nil && response.Error == nil {
+		r.save(blockKey(height), response)
+	}
+	return response, err
+}
+
+func (r *Recorder) GetBlockEvents(height uint64) (*responses.EventsResponse, error) {
+	response, err := r.client.GetBlockEvents(height)
+	if err == nil && response.Error == nil {
+		r.save(eventsKey(height), response)
+	}
+	return response, err
+}
+
+func (r *Recorder) GetCandidates(height uint64, stakes bool) (*responses.BlockCandidatesResponse, error) {
+	response, err := r.client.GetCandidates(height, stakes)
+	if err == nil && response.Error == nil {
+		r.save(candidatesKey(height, stakes), response)
+	}
+	return response, err
+}
+
+func (r *Recorder) GetCoinInfo(symbol string) (*responses.CoinInfoResponse, error) {
+	response, err := r.client.GetCoinInfo(symbol)
+	if err == nil && response.Error == nil {
+		r.save(coinKey(symbol), response)
+	}
+	return response, err
+}
+
+// Balances are saved per address, because addresses are requested in chunks of arbitrary order
+func (r *Recorder) GetAddresses(addresses []string, height uint64) (*responses.BalancesResponse, error) {
+	response, err := r.client.GetAddresses(addresses, height)
+	if err == nil && response.Error == nil {
+		for _, balance := range response.Result {
+			r.save(balanceKey(height, balance.Address), balance)
+		}
+	}
+	return response, err
+}
+
+func (r *Recorder) save(key string, response interface{}) {
+	if err := r.archive.Save(key, response); err != nil {
+		r.logger.WithField("key", key).Error(err)
+	}
+}
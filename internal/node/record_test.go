@@ -0,0 +1,100 @@
+package node
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/noah-blockchain/noah-explorer-tools/models"
+	"github.com/noah-blockchain/noah-node-go-api/responses"
+	"github.com/sirupsen/logrus"
+)
+
+type balancesClient struct {
+	fakeClient
+}
+
+func (c *balancesClient) GetAddresses(addresses []string, height uint64) (*responses.BalancesResponse, error) {
+	response := new(responses.BalancesResponse)
+	for _, address := range addresses {
+		response.Result = append(response.Result, responses.Balance{
+			Address: address[1 : len(address)-1],
+			Balance: map[string]string{"NOAH": "1"},
+		})
+	}
+	return response, nil
+}
+
+func newTestArchive(t *testing.T) (*Archive, func()) {
+	dir, err := ioutil.TempDir("", "node-archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewArchive(dir), func() { os.RemoveAll(dir) }
+}
+
+func TestReplayServesRecordedResponses(t *testing.T) {
+	archive, cleanup := newTestArchive(t)
+	defer cleanup()
+
+	recorder := NewRecorder(&balancesClient{fakeClient{height: 10, hash: "A"}}, archive, logrus.NewEntry(logrus.New()))
+	if _, err := recorder.GetBlock(5); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recorder.GetAddresses([]string{`"NOAHx1"`, `"NOAHx2"`}, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	replay := NewReplay(archive)
+	block, err := replay.GetBlock(5)
+	if err != nil || block.Error != nil || block.Result.Hash != "A" {
+		t.Errorf("Recorded block must be replayed, block %+v, err %v", block, err)
+	}
+	balances, err := replay.GetAddresses([]string{`"NOAHx2"`}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(balances.Result) != 1 || balances.Result[0].Address != "NOAHx2" {
+		t.Error("Balances must be replayed per address, got ", balances.Result)
+	}
+	if _, err = replay.GetAddresses([]string{`"NOAHx3"`}, 5); err == nil {
+		t.Error("Balance which has not been recorded must be an error")
+	}
+}
+
+func TestReplayReportsMissingBlock(t *testing.T) {
+	archive, cleanup := newTestArchive(t)
+	defer cleanup()
+
+	block, err := NewReplay(archive).GetBlock(1)
+	if err != nil || block.Error == nil {
+		t.Error("Block which has not been recorded must be reported as not found")
+	}
+	if _, err = NewReplay(archive).GetBlockEvents(1); err == nil {
+		t.Error("Events which have not been recorded must be an error")
+	}
+}
+
+func TestReplayDecodesTransactionData(t *testing.T) {
+	archive, cleanup := newTestArchive(t)
+	defer cleanup()
+
+	block := new(responses.BlockResponse)
+	block.Result.Transactions = []responses.Transaction{{
+		Type: models.TxTypeSend,
+		Data: json.RawMessage(`{"coin":"NOAH","to":"NOAHx1","value":"1"}`),
+	}}
+	if err := archive.Save(blockKey(1), block); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, err := NewReplay(archive).GetBlock(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, ok := replayed.Result.Transactions[0].IData.(models.SendTxData)
+	if !ok || data.To != "NOAHx1" || data.Value != "1" {
+		t.Errorf("Transaction data must be decoded, got %#v", replayed.Result.Transactions[0].IData)
+	}
+}
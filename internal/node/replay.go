@@ -0,0 +1,113 @@
+package node
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/MinterTeam/go-amino"
+	"github.com/noah-blockchain/noah-explorer-tools/models"
+	"github.com/noah-blockchain/noah-node-go-api/responses"
+)
+
+var _ Client = (*Replay)(nil)
+
+// Replay serves responses from the archive instead of the node.
+// Blocks which have not been recorded are reported as not created yet, other missing responses are errors.
+type Replay struct {
+	archive *Archive
+	cdc     *amino.Codec
+}
+
+func NewReplay(archive *Archive) *Replay {
+	return &Replay{
+		archive: archive,
+		cdc:     amino.NewCodec(),
+	}
+}
+
+func (r *Replay) GetStatus() (*responses.StatusResponse, error) {
+	response := new(responses.StatusResponse)
+	return response, r.load(statusKey(), response)
+}
+
+func (r *Replay) GetBlock(height uint64) (*responses.BlockResponse, error) {
+	response := new(responses.BlockResponse)
+	err := r.archive.Load(blockKey(height), response)
+	if err == ErrNotRecorded {
+		response.Error = &responses.ErrorData{Code: 404, Message: "Block not found"}
+		return response, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return response, r.decodeTransactions(response)
+}
+
+func (r *Replay) GetBlockEvents(height uint64) (*responses.EventsResponse, error) {
+	response := new(responses.EventsResponse)
+	return response, r.load(eventsKey(height), response)
+}
+
+func (r *Replay) GetCandidates(height uint64, stakes bool) (*responses.BlockCandidatesResponse, error) {
+	response := new(responses.BlockCandidatesResponse)
+	return response, r.load(candidatesKey(height, stakes), response)
+}
+
+func (r *Replay) GetCoinInfo(symbol string) (*responses.CoinInfoResponse, error) {
+	response := new(responses.CoinInfoResponse)
+	return response, r.load(coinKey(symbol), response)
+}
+
+// Addresses are given in the node request format, i.e. quoted
+func (r *Replay) GetAddresses(addresses []string, height uint64) (*responses.BalancesResponse, error) {
+	response := new(responses.BalancesResponse)
+	response.Result = make([]responses.Balance, len(addresses))
+	for i, address := range addresses {
+		if err := r.load(balanceKey(height, strings.Trim(address, `"`)), &response.Result[i]); err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+func (r *Replay) load(key string, response interface{}) error {
+	err := r.archive.Load(key, response)
+	if err == ErrNotRecorded {
+		return fmt.Errorf("%s: %s", key, err)
+	}
+	return err
+}
+
+// Transaction data is not serialized by the node API client, so it is decoded again the same way
+func (r *Replay) decodeTransactions(response *responses.BlockResponse) error {
+	for i, tx := range response.Result.Transactions {
+		dataType, ok := txDataTypes[tx.Type]
+		if !ok {
+			continue
+		}
+		data := reflect.New(dataType)
+		if err := r.cdc.UnmarshalJSON(tx.Data, data.Interface()); err != nil {
+			return err
+		}
+		response.Result.Transactions[i].IData = data.Elem().Interface()
+	}
+	return nil
+}
+
+var txDataTypes = map[uint8]reflect.Type{
+	models.TxTypeSend:                reflect.TypeOf(models.SendTxData{}),
+	models.TxTypeSellCoin:            reflect.TypeOf(models.SellCoinTxData{}),
+	models.TxTypeSellAllCoin:         reflect.TypeOf(models.SellAllCoinTxData{}),
+	models.TxTypeBuyCoin:             reflect.TypeOf(models.BuyCoinTxData{}),
+	models.TxTypeCreateCoin:          reflect.TypeOf(models.CreateCoinTxData{}),
+	models.TxTypeDeclareCandidacy:    reflect.TypeOf(models.DeclareCandidacyTxData{}),
+	models.TxTypeDelegate:            reflect.TypeOf(models.DelegateTxData{}),
+	models.TxTypeUnbound:             reflect.TypeOf(models.UnbondTxData{}),
+	models.TxTypeRedeemCheck:         reflect.TypeOf(models.RedeemCheckTxData{}),
+	models.TxTypeSetCandidateOnline:  reflect.TypeOf(models.SetCandidateTxData{}),
+	models.TxTypeSetCandidateOffline: reflect.TypeOf(models.SetCandidateTxData{}),
+	models.TxTypeMultiSig:            reflect.TypeOf(models.CreateMultisigTxData{}),
+	models.TxTypeMultiSend:           reflect.TypeOf(models.MultiSendTxData{}),
+	models.TxTypeEditCandidate:       reflect.TypeOf(models.EditCandidateTxData{}),
+}
@@ -0,0 +1,145 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+const newBlockQuery = "tm.event='NewBlock'"
+
+type subscribeRequest struct {
+	Jsonrpc string            `json:"jsonrpc"`
+	Method  string            `json:"method"`
+	ID      string            `json:"id"`
+	Params  map[string]string `json:"params"`
+}
+
+type newBlockEvent struct {
+	Result struct {
+		Data struct {
+			Value struct {
+				Block struct {
+					Header struct {
+						Height string `json:"height"`
+					} `json:"header"`
+				} `json:"block"`
+			} `json:"value"`
+		} `json:"data"`
+	} `json:"result"`
+}
+
+// Subscription receives heights of new blocks from Tendermint websocket of the node
+// and reconnects when the connection drops.
+type Subscription struct {
+	link           string
+	reconnectDelay time.Duration
+	logger         *logrus.Entry
+	heights        chan uint64
+	connected      int32
+}
+
+func NewSubscription(link string, reconnectDelay time.Duration, logger *logrus.Entry) *Subscription {
+	return &Subscription{
+		link:           link,
+		reconnectDelay: reconnectDelay,
+		logger:         logger,
+		heights:        make(chan uint64, 1),
+	}
+}
+
+// Heights of new blocks, only the latest height is kept if it is not received in time
+func (s *Subscription) Heights() <-chan uint64 {
+	return s.heights
+}
+
+// Returns false while the subscription is not established, new blocks have to be polled then
+func (s *Subscription) IsConnected() bool {
+	return atomic.LoadInt32(&s.connected) == 1
+}
+
+// Keep subscription until ctx is done
+func (s *Subscription) Run(ctx context.Context) {
+	for {
+		if err := s.subscribe(ctx); err != nil && ctx.Err() == nil {
+			s.logger.WithField("link", s.link).Warn(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.reconnectDelay):
+		}
+	}
+}
+
+func (s *Subscription) subscribe(ctx context.Context) error {
+	conn, _, err := websocket.DefaultDialer.Dial(s.link, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	err = conn.WriteJSON(subscribeRequest{
+		Jsonrpc: "2.0",
+		Method:  "subscribe",
+		ID:      "0",
+		Params:  map[string]string{"query": newBlockQuery},
+	})
+	if err != nil {
+		return err
+	}
+
+	atomic.StoreInt32(&s.connected, 1)
+	defer atomic.StoreInt32(&s.connected, 0)
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		var event newBlockEvent
+		if err = json.Unmarshal(message, &event); err != nil {
+			return err
+		}
+		// subscription confirmation has empty result
+		if event.Result.Data.Value.Block.Header.Height == "" {
+			continue
+		}
+		height, err := strconv.ParseUint(event.Result.Data.Value.Block.Header.Height, 10, 64)
+		if err != nil {
+			return err
+		}
+		s.push(height)
+	}
+}
+
+func (s *Subscription) push(height uint64) {
+	for {
+		select {
+		case s.heights <- height:
+			return
+		default:
+		}
+		// drop the stale height
+		select {
+		case <-s.heights:
+		default:
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// Fake Tendermint websocket which confirms subscription, sends the heights and closes the connection
+func newFakeWsServer(t *testing.T, heights ...uint64) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		var request subscribeRequest
+		if err = conn.ReadJSON(&request); err != nil {
+			t.Error(err)
+			return
+		}
+		if request.Method != "subscribe" || request.Params["query"] != newBlockQuery {
+			t.Error("Unexpected request ", request)
+			return
+		}
+
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":"0","result":{}}`))
+		for _, height := range heights {
+			event := fmt.Sprintf(`{"jsonrpc":"2.0","id":"0#event","result":{"query":"tm.event='NewBlock'",`+
+				`"data":{"type":"tendermint/event/NewBlock","value":{"block":{"header":{"height":"%d"}}}}}}`, height)
+			_ = conn.WriteMessage(websocket.TextMessage, []byte(event))
+			time.Sleep(10 * time.Millisecond)
+		}
+	}))
+}
+
+func TestSubscriptionReceivesNewBlocks(t *testing.T) {
+	server := newFakeWsServer(t, 10, 11)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := NewSubscription("ws"+strings.TrimPrefix(server.URL, "http"), 10*time.Millisecond, logrus.NewEntry(logrus.New()))
+	go s.Run(ctx)
+
+	for _, expected := range []uint64{10, 11} {
+		select {
+		case height := <-s.Heights():
+			if height != expected {
+				t.Error("Height must be ", expected, " but now ", height)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("New block has not been received")
+		}
+	}
+
+	// server closes connection after the last height, subscription is restored
+	select {
+	case height := <-s.Heights():
+		if height != 10 {
+			t.Error("Height must be 10 after reconnect but now ", height)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Subscription has not been restored")
+	}
+}
+
+func TestSubscriptionIsNotConnectedWithoutServer(t *testing.T) {
+	server := newFakeWsServer(t)
+	link := "ws" + strings.TrimPrefix(server.URL, "http")
+	server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := NewSubscription(link, 10*time.Millisecond, logrus.NewEntry(logrus.New()))
+	go s.Run(ctx)
+
+	time.Sleep(50 * time.Millisecond)
+	if s.IsConnected() {
+		t.Error("Subscription must not be connected")
+	}
+}
@@ -0,0 +1,35 @@
+package testdb
+
+import (
+	"os"
+	"testing"
+
+	"github.com/go-pg/pg"
+)
+
+// Postgres URL of a migrated test DB, tests are skipped if it is not set.
+// Every test runs within a DB transaction which is rolled back, so the DB keeps no data.
+const Env = "COIN_EXTENDER_TEST_DB"
+
+// Begin a transaction on the test DB which is rolled back when the test ends, skips the test if Env is not set
+func Tx(t *testing.T) *pg.Tx {
+	url := os.Getenv(Env)
+	if url == "" {
+		t.Skip(Env + " is not set")
+	}
+	options, err := pg.ParseURL(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := pg.Connect(options)
+	tx, err := db.Begin()
+	if err != nil {
+		db.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		tx.Rollback()
+		db.Close()
+	})
+	return tx
+}
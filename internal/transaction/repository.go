@@ -1,12 +1,24 @@
 package transaction
 
 import (
+	"encoding/json"
+
 	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
 	"github.com/noah-blockchain/coinExplorer-tools/models"
+	"github.com/noah-blockchain/noah-extender/internal/bulk"
+)
+
+var (
+	transactionColumns = []string{"id", "from_address_id", "nonce", "gas_price", "gas", "block_id", "gas_coin_id",
+		"created_at", "type", "hash", "service_data", "data", "tags", "payload", "raw_tx"}
+	txOutputColumns    = []string{"id", "transaction_id", "to_address_id", "coin_id", "value", "created_at"}
+	txValidatorColumns = []string{"transaction_id", "validator_id"}
 )
 
 type Repository struct {
-	db *pg.DB
+	db   orm.DB
+	copy bool
 }
 
 func NewRepository(db *pg.DB) *Repository {
@@ -15,6 +27,19 @@ func NewRepository(db *pg.DB) *Repository {
 	}
 }
 
+// Return copy of repository which executes all queries within DB transaction
+func (r *Repository) WithTx(tx *pg.Tx) *Repository {
+	return &Repository{
+		db:   tx,
+		copy: r.copy,
+	}
+}
+
+// Write transactions, outputs and validator links with COPY instead of multi-row INSERT
+func (r *Repository) UseCopy() {
+	r.copy = true
+}
+
 func (r *Repository) Save(transaction *models.Transaction) error {
 	_, err := r.db.Model(transaction).Insert()
 	if err != nil {
@@ -24,6 +49,9 @@ func (r *Repository) Save(transaction *models.Transaction) error {
 }
 
 func (r *Repository) SaveAll(transactions []*models.Transaction) error {
+	if r.copy {
+		return r.copyTransactions(transactions)
+	}
 	var args []interface{}
 	for _, t := range transactions {
 		args = append(args, t)
@@ -40,6 +68,9 @@ func (r *Repository) SaveAllInvalid(transactions []*models.InvalidTransaction) e
 }
 
 func (r *Repository) SaveAllTxOutputs(output []*models.TransactionOutput) error {
+	if r.copy {
+		return r.copyTxOutputs(output)
+	}
 	var args []interface{}
 	for _, t := range output {
 		args = append(args, t)
@@ -48,6 +79,15 @@ func (r *Repository) SaveAllTxOutputs(output []*models.TransactionOutput) error
 }
 
 func (r *Repository) LinkWithValidators(links []*models.TransactionValidator) error {
+	if r.copy {
+		rows := new(bulk.Rows)
+		for _, l := range links {
+			rows.Uint(l.TransactionID)
+			rows.Uint(l.ValidatorID)
+			rows.End()
+		}
+		return bulk.Copy(r.db, "transaction_validator", txValidatorColumns, rows)
+	}
 	var args []interface{}
 	for _, t := range links {
 		args = append(args, t)
@@ -55,6 +95,78 @@ func (r *Repository) LinkWithValidators(links []*models.TransactionValidator) er
 	return r.db.Insert(args...)
 }
 
+// Ids are reserved before the copy, because COPY does not return them
+func (r *Repository) copyTransactions(transactions []*models.Transaction) error {
+	var missing []*models.Transaction
+	for _, t := range transactions {
+		if t.ID == 0 {
+			missing = append(missing, t)
+		}
+	}
+	ids, err := bulk.NextIDs(r.db, "transactions_id_seq", len(missing))
+	if err != nil {
+		return err
+	}
+	for i, t := range missing {
+		t.ID = ids[i]
+	}
+
+	rows := new(bulk.Rows)
+	for _, t := range transactions {
+		var tags []byte
+		if t.Tags != nil {
+			if tags, err = json.Marshal(t.Tags); err != nil {
+				return err
+			}
+		}
+		rows.Uint(t.ID)
+		rows.Uint(t.FromAddressID)
+		rows.Uint(t.Nonce)
+		rows.Uint(t.GasPrice)
+		rows.Uint(t.Gas)
+		rows.Uint(t.BlockID)
+		rows.Uint(t.GasCoinID)
+		rows.Time(t.CreatedAt)
+		rows.Uint(uint64(t.Type))
+		rows.String(t.Hash)
+		rows.NullString(t.ServiceData)
+		rows.Text(t.Data)
+		rows.Text(tags)
+		rows.Bytes(t.Payload)
+		rows.Bytes(t.RawTx)
+		rows.End()
+	}
+	return bulk.Copy(r.db, "transactions", transactionColumns, rows)
+}
+
+func (r *Repository) copyTxOutputs(outputs []*models.TransactionOutput) error {
+	var missing []*models.TransactionOutput
+	for _, o := range outputs {
+		if o.ID == 0 {
+			missing = append(missing, o)
+		}
+	}
+	ids, err := bulk.NextIDs(r.db, "transaction_outputs_id_seq", len(missing))
+	if err != nil {
+		return err
+	}
+	for i, o := range missing {
+		o.ID = ids[i]
+	}
+
+	rows := new(bulk.Rows)
+	for _, o := range outputs {
+		rows.Uint(o.ID)
+		rows.Uint(o.TransactionID)
+		rows.Uint(o.ToAddressID)
+		rows.Uint(o.CoinID)
+		rows.String(o.Value)
+		rows.Time(o.CreatedAt)
+		rows.End()
+	}
+	return bulk.Copy(r.db, "transaction_outputs", txOutputColumns, rows)
+}
+
 func (r Repository) IndexTxAddress(txsId []uint64) error {
 	_, err := r.db.Query(nil, `
 insert into index_transaction_by_address (block_id, address_id, transaction_id)
@@ -1,6 +1,7 @@
 package transaction
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
@@ -9,11 +10,14 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/go-pg/pg"
 	"github.com/noah-blockchain/coinExplorer-tools/helpers"
 	"github.com/noah-blockchain/coinExplorer-tools/models"
 	node_models "github.com/noah-blockchain/noah-explorer-tools/models"
 	"github.com/noah-blockchain/noah-extender/internal/address"
 	"github.com/noah-blockchain/noah-extender/internal/coin"
+	"github.com/noah-blockchain/noah-extender/internal/cursor"
+	"github.com/noah-blockchain/noah-extender/internal/failure"
 	"github.com/noah-blockchain/noah-extender/internal/validator"
 	"github.com/noah-blockchain/noah-go-node/core/check"
 	"github.com/noah-blockchain/noah-node-go-api/responses"
@@ -27,25 +31,36 @@ type Service struct {
 	validatorRepository *validator.Repository
 	coinRepository      *coin.Repository
 	coinService         *coin.Service
+	cursorService       *cursor.Service
+	failureService      *failure.Service
 	jobSaveTxs          chan []*models.Transaction
 	jobSaveTxsOutput    chan []*models.Transaction
-	jobSaveValidatorTxs chan []*models.TransactionValidator
+	jobSaveValidatorTxs chan TxValidatorLinks
 	jobSaveInvalidTxs   chan []*models.InvalidTransaction
 	logger              *logrus.Entry
 }
 
+// Transaction-validator links of a block
+type TxValidatorLinks struct {
+	Height uint64
+	Links  []*models.TransactionValidator
+}
+
 func NewService(env *models.ExtenderEnvironment, repository *Repository, addressRepository *address.Repository,
-	validatorRepository *validator.Repository, coinRepository *coin.Repository, coinService *coin.Service, logger *logrus.Entry) *Service {
+	validatorRepository *validator.Repository, coinRepository *coin.Repository, coinService *coin.Service,
+	cursorService *cursor.Service, failureService *failure.Service, logger *logrus.Entry) *Service {
 	return &Service{
 		env:                 env,
 		txRepository:        repository,
 		coinRepository:      coinRepository,
 		addressRepository:   addressRepository,
 		coinService:         coinService,
+		cursorService:       cursorService,
+		failureService:      failureService,
 		validatorRepository: validatorRepository,
 		jobSaveTxs:          make(chan []*models.Transaction, env.WrkSaveTxsCount),
 		jobSaveTxsOutput:    make(chan []*models.Transaction, env.WrkSaveTxsOutputCount),
-		jobSaveValidatorTxs: make(chan []*models.TransactionValidator, env.WrkSaveValidatorTxsCount),
+		jobSaveValidatorTxs: make(chan TxValidatorLinks, env.WrkSaveValidatorTxsCount),
 		jobSaveInvalidTxs:   make(chan []*models.InvalidTransaction, env.WrkSaveInvTxsCount),
 		logger:              logger,
 	}
@@ -60,7 +75,7 @@ func (s *Service) GetSaveTxsOutputJobChannel() chan []*models.Transaction {
 func (s *Service) GetSaveInvalidTxsJobChannel() chan []*models.InvalidTransaction {
 	return s.jobSaveInvalidTxs
 }
-func (s *Service) GetSaveTxValidatorJobChannel() chan []*models.TransactionValidator {
+func (s *Service) GetSaveTxValidatorJobChannel() chan TxValidatorLinks {
 	return s.jobSaveValidatorTxs
 }
 
@@ -68,6 +83,79 @@ func (s *Service) GetSaveTxValidatorJobChannel() chan []*models.TransactionValid
 func (s *Service) HandleTransactionsFromBlockResponse(blockHeight uint64, blockCreatedAt time.Time,
 	transactions []responses.Transaction) error {
 
+	txList, invalidTxList, err := s.prepareTransactions(blockHeight, blockCreatedAt, transactions)
+	if err != nil {
+		return err
+	}
+
+	if len(txList) > 0 {
+		s.cursorService.Add(cursor.StageTxs, blockHeight)
+		s.GetSaveTxJobChannel() <- txList
+		s.coinService.GetUpdateCoinsFromTxsJobChannel() <- txList
+	}
+
+	if len(invalidTxList) > 0 {
+		s.cursorService.Add(cursor.StageTxs, blockHeight)
+		s.GetSaveInvalidTxsJobChannel() <- invalidTxList
+	}
+
+	return nil
+}
+
+//Handle transactions and save them with outputs, validator links and address index within DB transaction.
+//Returns saved transactions, coins are updated from them after commit.
+func (s *Service) HandleTransactionsTx(dbTx *pg.Tx, blockHeight uint64, blockCreatedAt time.Time,
+	transactions []responses.Transaction) ([]*models.Transaction, error) {
+
+	txList, invalidTxList, err := s.prepareTransactions(blockHeight, blockCreatedAt, transactions)
+	if err != nil {
+		return nil, err
+	}
+	repository := s.txRepository.WithTx(dbTx)
+
+	if len(invalidTxList) > 0 {
+		if err = repository.SaveAllInvalid(invalidTxList); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(txList) == 0 {
+		return nil, nil
+	}
+
+	if err = repository.SaveAll(txList); err != nil {
+		return nil, err
+	}
+
+	links, err := s.getLinksTxValidator(txList)
+	if err != nil {
+		return nil, err
+	}
+	if len(links) > 0 {
+		if err = repository.LinkWithValidators(links); err != nil {
+			return nil, err
+		}
+	}
+
+	outputs, idsList, err := s.getTxOutputs(txList)
+	if err != nil {
+		return nil, err
+	}
+	if len(outputs) > 0 {
+		if err = repository.SaveAllTxOutputs(outputs); err != nil {
+			return nil, err
+		}
+	}
+	if err = repository.IndexTxAddress(idsList); err != nil {
+		return nil, err
+	}
+
+	return txList, nil
+}
+
+func (s *Service) prepareTransactions(blockHeight uint64, blockCreatedAt time.Time,
+	transactions []responses.Transaction) ([]*models.Transaction, []*models.InvalidTransaction, error) {
+
 	var txList []*models.Transaction
 	var invalidTxList []*models.InvalidTransaction
 
@@ -76,41 +164,42 @@ func (s *Service) HandleTransactionsFromBlockResponse(blockHeight uint64, blockC
 			transaction, err := s.handleValidTransaction(tx, blockHeight, blockCreatedAt)
 			if err != nil {
 				s.logger.Error(err)
-				return err
+				return nil, nil, err
 			}
 			txList = append(txList, transaction)
 		} else {
 			transaction, err := s.handleInvalidTransaction(tx, blockHeight, blockCreatedAt)
 			if err != nil {
 				s.logger.Error(err)
-				return err
+				return nil, nil, err
 			}
 			invalidTxList = append(invalidTxList, transaction)
 		}
 	}
 
-	if len(txList) > 0 {
-		s.GetSaveTxJobChannel() <- txList
-		s.coinService.GetUpdateCoinsFromTxsJobChannel() <- txList
-	}
-
-	if len(invalidTxList) > 0 {
-		s.GetSaveInvalidTxsJobChannel() <- invalidTxList
-	}
-
-	return nil
+	return txList, invalidTxList, nil
 }
 
 func (s *Service) SaveTransactionsWorker(jobs <-chan []*models.Transaction) {
 	for transactions := range jobs {
-		err := s.txRepository.SaveAll(transactions)
+		height := transactions[0].BlockID
+		err := s.failureService.Do(failure.StageTxs, height, func() error {
+			return s.txRepository.SaveAll(transactions)
+		})
+		var links []*models.TransactionValidator
+		if err == nil {
+			err = s.failureService.Do(failure.StageTxs, height, func() (err error) {
+				links, err = s.getLinksTxValidator(transactions)
+				return err
+			})
+		}
 		if err != nil {
-			s.logger.Error(err)
+			if err == failure.ErrSkipped {
+				s.cursorService.Done(cursor.StageTxs, height)
+			}
+			continue
 		}
-		helpers.HandleError(err)
 
-		links, err := s.getLinksTxValidator(transactions)
-		helpers.HandleError(err)
 		if len(links) > 0 {
 			chunksCount := int(math.Ceil(float64(len(links)) / float64(s.env.TxChunkSize)))
 			for i := 0; i < chunksCount; i++ {
@@ -119,53 +208,81 @@ func (s *Service) SaveTransactionsWorker(jobs <-chan []*models.Transaction) {
 				if end > len(links) {
 					end = len(links)
 				}
-				s.GetSaveTxValidatorJobChannel() <- links[start:end]
+				s.cursorService.Add(cursor.StageTxValidators, height)
+				s.GetSaveTxValidatorJobChannel() <- TxValidatorLinks{Height: height, Links: links[start:end]}
 			}
 		}
 
+		s.cursorService.Add(cursor.StageTxOutputs, height)
+		s.cursorService.Add(cursor.StageAddressIndex, height)
 		s.GetSaveTxsOutputJobChannel() <- transactions
+		s.cursorService.Done(cursor.StageTxs, height)
 	}
 }
 func (s *Service) SaveTransactionsOutputWorker(jobs <-chan []*models.Transaction) {
 	for transactions := range jobs {
-		err := s.SaveAllTxOutputs(transactions)
-		if err != nil {
-			s.logger.Error(err)
+		height := transactions[0].BlockID
+		var idsList []uint64
+		err := s.failureService.Do(failure.StageTxOutputs, height, func() (err error) {
+			var list []*models.TransactionOutput
+			list, idsList, err = s.getTxOutputs(transactions)
+			if err != nil || len(list) == 0 {
+				return err
+			}
+			return s.txRepository.SaveAllTxOutputs(list)
+		})
+		if err == nil && len(idsList) > 0 {
+			err = s.failureService.Do(failure.StageTxOutputs, height, func() error {
+				return s.txRepository.IndexTxAddress(idsList)
+			})
 		}
-		helpers.HandleError(err)
+		if err == failure.ErrHalted {
+			continue
+		}
+		s.cursorService.Done(cursor.StageTxOutputs, height)
+		s.cursorService.Done(cursor.StageAddressIndex, height)
 	}
 }
 func (s *Service) SaveInvalidTransactionsWorker(jobs <-chan []*models.InvalidTransaction) {
 	for transactions := range jobs {
-		err := s.txRepository.SaveAllInvalid(transactions)
-		if err != nil {
-			s.logger.Error(err)
+		height := transactions[0].BlockID
+		err := s.failureService.Do(failure.StageTxs, height, func() error {
+			return s.txRepository.SaveAllInvalid(transactions)
+		})
+		if err == failure.ErrHalted {
+			continue
 		}
-		helpers.HandleError(err)
+		s.cursorService.Done(cursor.StageTxs, height)
 	}
 }
 
-func (s *Service) SaveTxValidatorWorker(jobs <-chan []*models.TransactionValidator) {
-	for links := range jobs {
-		err := s.txRepository.LinkWithValidators(links)
-		if err != nil {
-			s.logger.Error(err)
+func (s *Service) SaveTxValidatorWorker(jobs <-chan TxValidatorLinks) {
+	for job := range jobs {
+		err := s.failureService.Do(failure.StageTxValidators, job.Height, func() error {
+			return s.txRepository.LinkWithValidators(job.Links)
+		})
+		if err == failure.ErrHalted {
+			continue
 		}
-		helpers.HandleError(err)
+		s.cursorService.Done(cursor.StageTxValidators, job.Height)
 	}
 }
 
-func (s *Service) UpdateTxsIndexWorker() {
+func (s *Service) UpdateTxsIndexWorker(ctx context.Context) {
 	for {
 		err := s.txRepository.IndexLastNTxAddress(s.env.WrkUpdateTxsIndexNumBlocks)
 		if err != nil {
 			s.logger.Error(err)
 		}
-		time.Sleep(time.Duration(s.env.WrkUpdateTxsIndexTime) * time.Second)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(s.env.WrkUpdateTxsIndexTime) * time.Second):
+		}
 	}
 }
 
-func (s *Service) SaveAllTxOutputs(txList []*models.Transaction) error {
+func (s *Service) getTxOutputs(txList []*models.Transaction) ([]*models.TransactionOutput, []uint64, error) {
 	var (
 		list    []*models.TransactionOutput
 		idsList []uint64
@@ -173,7 +290,7 @@ func (s *Service) SaveAllTxOutputs(txList []*models.Transaction) error {
 
 	for _, tx := range txList {
 		if tx.ID == 0 {
-			return errors.New("no transaction id")
+			return nil, nil, failure.Permanent(errors.New("no transaction id"))
 		}
 
 		idsList = append(idsList, tx.ID)
@@ -184,13 +301,17 @@ func (s *Service) SaveAllTxOutputs(txList []*models.Transaction) error {
 
 		if tx.Type == node_models.TxTypeSend {
 			if tx.IData.(node_models.SendTxData).To == "" {
-				return errors.New("empty receiver of transaction")
+				return nil, nil, errors.New("empty receiver of transaction")
 			}
 
 			toId, err := s.addressRepository.FindId(helpers.RemovePrefixFromAddress(tx.IData.(node_models.SendTxData).To))
-			helpers.HandleError(err)
+			if err != nil {
+				return nil, nil, err
+			}
 			coinID, err := s.coinRepository.FindIdBySymbol(tx.IData.(node_models.SendTxData).Coin)
-			helpers.HandleError(err)
+			if err != nil {
+				return nil, nil, err
+			}
 			list = append(list, &models.TransactionOutput{
 				TransactionID: tx.ID,
 				ToAddressID:   toId,
@@ -201,9 +322,13 @@ func (s *Service) SaveAllTxOutputs(txList []*models.Transaction) error {
 		if tx.Type == node_models.TxTypeMultiSend {
 			for _, receiver := range tx.IData.(node_models.MultiSendTxData).List {
 				toId, err := s.addressRepository.FindId(helpers.RemovePrefixFromAddress(receiver.To))
-				helpers.HandleError(err)
+				if err != nil {
+					return nil, nil, err
+				}
 				coinID, err := s.coinRepository.FindIdBySymbol(receiver.Coin)
-				helpers.HandleError(err)
+				if err != nil {
+					return nil, nil, err
+				}
 				list = append(list, &models.TransactionOutput{
 					TransactionID: tx.ID,
 					ToAddressID:   toId,
@@ -238,9 +363,13 @@ func (s *Service) SaveAllTxOutputs(txList []*models.Transaction) error {
 			// We are put a creator of a check into "to" field
 			// because "from" field use for a person who created a transaction
 			toId, err := s.addressRepository.FindId(helpers.RemovePrefixFromAddress(sender.String()))
-			helpers.HandleError(err)
+			if err != nil {
+				return nil, nil, err
+			}
 			coinID, err := s.coinRepository.FindIdBySymbol(data.Coin.String())
-			helpers.HandleError(err)
+			if err != nil {
+				return nil, nil, err
+			}
 
 			list = append(list, &models.TransactionOutput{
 				TransactionID: tx.ID,
@@ -251,16 +380,7 @@ func (s *Service) SaveAllTxOutputs(txList []*models.Transaction) error {
 		}
 	}
 
-	if len(list) > 0 {
-		err := s.txRepository.SaveAllTxOutputs(list)
-		helpers.HandleError(err)
-	}
-	if len(idsList) > 0 {
-		err := s.txRepository.IndexTxAddress(idsList)
-		helpers.HandleError(err)
-	}
-
-	return nil
+	return list, idsList, nil
 }
 
 func (s *Service) handleValidTransaction(tx responses.Transaction, blockHeight uint64, blockCreatedAt time.Time) (*models.Transaction, error) {
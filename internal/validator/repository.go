@@ -1,22 +1,23 @@
 package validator
 
 import (
-	"sync"
 	"time"
 
 	"github.com/go-pg/pg"
 	"github.com/noah-blockchain/coinExplorer-tools/models"
+	"github.com/noah-blockchain/noah-extender/internal/cache"
 )
 
 type Repository struct {
 	db    *pg.DB
-	cache *sync.Map
+	cache *cache.LRU
 }
 
-func NewRepository(db *pg.DB) *Repository {
+// Cache keeps ids of up to cacheSize public keys, 0 means the cache is not bounded
+func NewRepository(db *pg.DB, cacheSize int) *Repository {
 	return &Repository{
 		db:    db,
-		cache: new(sync.Map), //TODO: добавить реализацию очистки
+		cache: cache.New("validator", cacheSize),
 	}
 }
 
@@ -24,7 +25,7 @@ func NewRepository(db *pg.DB) *Repository {
 //Return Validator ID
 func (r *Repository) FindIdByPk(pk string) (uint64, error) {
 	//First look in the cache
-	id, ok := r.cache.Load(pk)
+	id, ok := r.cache.Get(pk)
 	if ok {
 		return id.(uint64), nil
 	}
@@ -33,7 +34,7 @@ func (r *Repository) FindIdByPk(pk string) (uint64, error) {
 	if err != nil {
 		return 0, err
 	}
-	r.cache.Store(pk, validator.ID)
+	r.cache.Add(pk, validator.ID)
 	return validator.ID, nil
 }
 
@@ -47,7 +48,7 @@ func (r *Repository) FindIdByPkOrCreate(pk string) (uint64, error) {
 		if err != nil {
 			return 0, err
 		}
-		r.cache.Store(validator.PublicKey, validator.ID)
+		r.cache.Add(validator.PublicKey, validator.ID)
 		return validator.ID, nil
 	}
 	return id, nil
@@ -68,25 +69,32 @@ func (r *Repository) SaveAllIfNotExist(validators []*models.Validator) error {
 	if r.isAllAddressesInCache(validators) {
 		return nil
 	}
+	// existence is decided by found rows, cache may evict them before they are checked
+	found, err := r.FindAllByPK(validators)
+	if err != nil {
+		return err
+	}
+	exist := make(map[string]bool, len(found))
+	for _, v := range found {
+		exist[v.PublicKey] = true
+	}
 	var args []interface{}
-	// Search in DB (use for update cache)
-	_, _ = r.FindAllByPK(validators)
-	// look PK in cache
+	var vList []*models.Validator
 	for _, v := range validators {
-		_, exist := r.cache.Load(v.PublicKey)
-		if !exist {
+		if !exist[v.PublicKey] {
+			exist[v.PublicKey] = true
 			args = append(args, v)
+			vList = append(vList, v)
 		}
 	}
-	// if all PK exists in cache do nothing
+	// if all PK exist do nothing
 	if len(args) == 0 {
 		return nil
 	}
-	err := r.db.Insert(args...)
-	if err != nil {
+	if err = r.db.Insert(args...); err != nil {
 		return err
 	}
-	r.addToCache(validators)
+	r.addToCache(vList)
 	return nil
 }
 
@@ -107,6 +115,31 @@ func (r *Repository) FindAllByPK(validators []*models.Validator) ([]*models.Vali
 	return vList, err
 }
 
+// Load ids of all validators into cache, returns count of loaded validators
+func (r *Repository) LoadAllToCache() (int, error) {
+	var validators []*models.Validator
+	err := r.db.Model(&validators).Column("id", "public_key").Select()
+	if err != nil {
+		return 0, err
+	}
+	r.addToCache(validators)
+	return len(validators), nil
+}
+
+// Return all validators with reward and owner addresses
+func (r *Repository) GetAllWithAddresses() ([]*models.Validator, error) {
+	var validators []*models.Validator
+	err := r.db.Model(&validators).Column("validator.*", "RewardAddress", "OwnerAddress").Select()
+	return validators, err
+}
+
+// Return all stakes with owner address, coin and validator
+func (r *Repository) GetAllStakes() ([]*models.Stake, error) {
+	var stakes []*models.Stake
+	err := r.db.Model(&stakes).Column("stake.*", "OwnerAddress", "Coin", "Validator").Select()
+	return stakes, err
+}
+
 func (r *Repository) UpdateAll(validators []*models.Validator) error {
 	_, err := r.db.Model(&validators).
 		Column("status").
@@ -147,17 +180,14 @@ func (r *Repository) SaveAllStakes(stakes []*models.Stake) error {
 
 func (r *Repository) addToCache(validators []*models.Validator) {
 	for _, v := range validators {
-		_, exist := r.cache.Load(v.PublicKey)
-		if !exist {
-			r.cache.Store(v.PublicKey, v.ID)
-		}
+		r.cache.Add(v.PublicKey, v.ID)
 	}
 }
 
 func (r *Repository) isAllAddressesInCache(validators []*models.Validator) bool {
 	// look PK in cache
 	for _, v := range validators {
-		_, exist := r.cache.Load(v.PublicKey)
+		_, exist := r.cache.Peek(v.PublicKey)
 		if !exist {
 			return false
 		}
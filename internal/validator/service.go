@@ -11,8 +11,8 @@ import (
 	"github.com/noah-blockchain/coinExplorer-tools/models"
 	"github.com/noah-blockchain/noah-extender/internal/address"
 	"github.com/noah-blockchain/noah-extender/internal/coin"
+	"github.com/noah-blockchain/noah-extender/internal/node"
 	"github.com/noah-blockchain/noah-extender/internal/utils"
-	"github.com/noah-blockchain/noah-node-go-api"
 	"github.com/noah-blockchain/noah-node-go-api/responses"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -21,11 +21,13 @@ import (
 const (
 	precision                 = 100
 	calcUptimeValidatorBlocks = 192
+	// Stakes are updated every X block, validators are updated on other blocks
+	UpdateStakesEveryBlocks = 12
 )
 
 type Service struct {
 	env                 *models.ExtenderEnvironment
-	nodeApi             *noah_node_go_api.NoahNodeApi
+	nodeApi             node.Client
 	Repository          *Repository
 	addressRepository   *address.Repository
 	coinRepository      *coin.Repository
@@ -34,7 +36,7 @@ type Service struct {
 	logger              *logrus.Entry
 }
 
-func NewService(env *models.ExtenderEnvironment, nodeApi *noah_node_go_api.NoahNodeApi, Repository *Repository,
+func NewService(env *models.ExtenderEnvironment, nodeApi node.Client, Repository *Repository,
 	addressRepository *address.Repository, coinRepository *coin.Repository, logger *logrus.Entry) *Service {
 	return &Service{
 		env:                 env,
@@ -58,243 +60,255 @@ func (s *Service) GetUpdateStakesJobChannel() chan uint64 {
 
 func (s *Service) UpdateValidatorsWorker(jobs <-chan uint64) {
 	for height := range jobs {
-		resp, err := s.nodeApi.GetCandidates(height, false)
+		if err := s.UpdateValidators(height); err != nil {
+			s.logger.WithField("height", height).Error(err)
+		}
+	}
+}
+
+// Update state of validators from candidates of the node at the height
+func (s *Service) UpdateValidators(height uint64) error {
+	resp, err := s.nodeApi.GetCandidates(height, false)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if resp.Error != nil {
+		//s.logger.Errorf("UpdateValidatorsWorker error: message=%s and data=%s height=%d", resp.Error.Message, resp.Error.Data, height) // todo
+		return nil
+	}
+
+	if len(resp.Result) > 0 {
+		var (
+			validators   = make([]*models.Validator, len(resp.Result))
+			addressesMap = make(map[string]struct{})
+		)
+
+		// Collect all PubKey's and addresses for save it before
+		for i, vlr := range resp.Result {
+			validators[i] = &models.Validator{PublicKey: helpers.RemovePrefix(vlr.PubKey)}
+			addressesMap[helpers.RemovePrefixFromAddress(vlr.RewardAddress)] = struct{}{}
+			addressesMap[helpers.RemovePrefixFromAddress(vlr.OwnerAddress)] = struct{}{}
+		}
+
+		err = s.Repository.SaveAllIfNotExist(validators)
 		if err != nil {
-			s.logger.Println("height=%d", height)
 			s.logger.Error(errors.WithStack(err))
-			continue
 		}
 
-		if resp.Error != nil {
-			//s.logger.Errorf("UpdateValidatorsWorker error: message=%s and data=%s height=%d", resp.Error.Message, resp.Error.Data, height) // todo
-			continue
+		err = s.addressRepository.SaveFromMapIfNotExists(addressesMap)
+		if err != nil {
+			s.logger.Error(errors.WithStack(err))
 		}
 
-		if len(resp.Result) > 0 {
-			var (
-				validators   = make([]*models.Validator, len(resp.Result))
-				addressesMap = make(map[string]struct{})
-			)
-
-			// Collect all PubKey's and addresses for save it before
-			for i, vlr := range resp.Result {
-				validators[i] = &models.Validator{PublicKey: helpers.RemovePrefix(vlr.PubKey)}
-				addressesMap[helpers.RemovePrefixFromAddress(vlr.RewardAddress)] = struct{}{}
-				addressesMap[helpers.RemovePrefixFromAddress(vlr.OwnerAddress)] = struct{}{}
-			}
+		for i, validator := range resp.Result {
+			updateAt := time.Now()
+			status := validator.Status
+			totalStake := validator.TotalStake
 
-			err = s.Repository.SaveAllIfNotExist(validators)
+			id, err := s.Repository.FindIdByPkOrCreate(helpers.RemovePrefix(validator.PubKey))
 			if err != nil {
 				s.logger.Error(errors.WithStack(err))
+				continue
 			}
-
-			err = s.addressRepository.SaveFromMapIfNotExists(addressesMap)
+			commission, err := strconv.ParseUint(validator.Commission, 10, 64)
 			if err != nil {
 				s.logger.Error(errors.WithStack(err))
+				continue
 			}
-
-			for i, validator := range resp.Result {
-				updateAt := time.Now()
-				status := validator.Status
-				totalStake := validator.TotalStake
-
-				id, err := s.Repository.FindIdByPkOrCreate(helpers.RemovePrefix(validator.PubKey))
-				if err != nil {
-					s.logger.Error(errors.WithStack(err))
-					continue
-				}
-				commission, err := strconv.ParseUint(validator.Commission, 10, 64)
-				if err != nil {
-					s.logger.Error(errors.WithStack(err))
-					continue
-				}
-				rewardAddressID, err := s.addressRepository.FindIdOrCreate(helpers.RemovePrefixFromAddress(validator.RewardAddress))
-				if err != nil {
-					s.logger.Error(errors.WithStack(err))
-					continue
-				}
-				ownerAddressID, err := s.addressRepository.FindIdOrCreate(helpers.RemovePrefixFromAddress(validator.OwnerAddress))
-				if err != nil {
-					s.logger.Error(errors.WithStack(err))
-					continue
-				}
-				validators[i] = &models.Validator{
-					ID:              id,
-					Status:          &status,
-					TotalStake:      &totalStake,
-					UpdatedAt:       &updateAt,
-					Commission:      &commission,
-					RewardAddressID: &rewardAddressID,
-					OwnerAddressID:  &ownerAddressID,
-				}
-			}
-			err = s.Repository.ResetAllStatuses()
+			rewardAddressID, err := s.addressRepository.FindIdOrCreate(helpers.RemovePrefixFromAddress(validator.RewardAddress))
 			if err != nil {
 				s.logger.Error(errors.WithStack(err))
+				continue
 			}
-			err = s.Repository.UpdateAll(validators)
+			ownerAddressID, err := s.addressRepository.FindIdOrCreate(helpers.RemovePrefixFromAddress(validator.OwnerAddress))
 			if err != nil {
 				s.logger.Error(errors.WithStack(err))
+				continue
 			}
+			validators[i] = &models.Validator{
+				ID:              id,
+				Status:          &status,
+				TotalStake:      &totalStake,
+				UpdatedAt:       &updateAt,
+				Commission:      &commission,
+				RewardAddressID: &rewardAddressID,
+				OwnerAddressID:  &ownerAddressID,
+			}
+		}
+		err = s.Repository.ResetAllStatuses()
+		if err != nil {
+			s.logger.Error(errors.WithStack(err))
+		}
+		err = s.Repository.UpdateAll(validators)
+		if err != nil {
+			s.logger.Error(errors.WithStack(err))
 		}
 	}
+	return nil
 }
 
 func (s *Service) UpdateStakesWorker(jobs <-chan uint64) {
 	for height := range jobs {
-		resp, err := s.nodeApi.GetCandidates(height, true)
-		if err != nil {
-			s.logger.Error(errors.WithStack(err))
-			continue
+		if err := s.UpdateStakes(height); err != nil {
+			s.logger.WithField("height", height).Error(err)
 		}
+	}
+}
 
-		if resp.Error != nil {
-			//s.logger.Errorf("UpdateStakesWorker error: message=%s and data=%s", resp.Error.Message, resp.Error.Data) // todo
-			continue
-		}
+// Replace stakes with stakes of candidates of the node at the height
+func (s *Service) UpdateStakes(height uint64) error {
+	resp, err := s.nodeApi.GetCandidates(height, true)
+	if err != nil {
+		return errors.WithStack(err)
+	}
 
-		var (
-			stakes       []*models.Stake
-			stakesInCoin = make(map[uint64]string)
-			validatorIds = make([]uint64, len(resp.Result))
-			validators   = make([]*models.Validator, len(resp.Result))
-			addressesMap = make(map[string]struct{})
-		)
+	if resp.Error != nil {
+		//s.logger.Errorf("UpdateStakesWorker error: message=%s and data=%s", resp.Error.Message, resp.Error.Data) // todo
+		return nil
+	}
 
-		// Collect all PubKey's and addresses for save it before
-		for i, vlr := range resp.Result {
-			validators[i] = &models.Validator{PublicKey: helpers.RemovePrefix(vlr.PubKey)}
-			addressesMap[helpers.RemovePrefixFromAddress(vlr.RewardAddress)] = struct{}{}
-			addressesMap[helpers.RemovePrefixFromAddress(vlr.OwnerAddress)] = struct{}{}
-			for _, stake := range vlr.Stakes {
-				addressesMap[helpers.RemovePrefixFromAddress(stake.Owner)] = struct{}{}
-			}
+	var (
+		stakes       []*models.Stake
+		stakesInCoin = make(map[uint64]string)
+		validatorIds = make([]uint64, len(resp.Result))
+		validators   = make([]*models.Validator, len(resp.Result))
+		addressesMap = make(map[string]struct{})
+	)
+
+	// Collect all PubKey's and addresses for save it before
+	for i, vlr := range resp.Result {
+		validators[i] = &models.Validator{PublicKey: helpers.RemovePrefix(vlr.PubKey)}
+		addressesMap[helpers.RemovePrefixFromAddress(vlr.RewardAddress)] = struct{}{}
+		addressesMap[helpers.RemovePrefixFromAddress(vlr.OwnerAddress)] = struct{}{}
+		for _, stake := range vlr.Stakes {
+			addressesMap[helpers.RemovePrefixFromAddress(stake.Owner)] = struct{}{}
 		}
+	}
 
-		err = s.Repository.SaveAllIfNotExist(validators)
-		if err != nil {
-			s.logger.Error(errors.WithStack(err))
-		}
+	err = s.Repository.SaveAllIfNotExist(validators)
+	if err != nil {
+		s.logger.Error(errors.WithStack(err))
+	}
 
-		err = s.addressRepository.SaveFromMapIfNotExists(addressesMap)
+	err = s.addressRepository.SaveFromMapIfNotExists(addressesMap)
+	if err != nil {
+		s.logger.Error(errors.WithStack(err))
+	}
+
+	for i, vlr := range resp.Result {
+		id, err := s.Repository.FindIdByPkOrCreate(helpers.RemovePrefix(vlr.PubKey))
 		if err != nil {
 			s.logger.Error(errors.WithStack(err))
+			continue
 		}
+		validatorIds[i] = id
 
-		for i, vlr := range resp.Result {
-			id, err := s.Repository.FindIdByPkOrCreate(helpers.RemovePrefix(vlr.PubKey))
+		for _, stake := range vlr.Stakes {
+			ownerAddressID, err := s.addressRepository.FindIdOrCreate(helpers.RemovePrefixFromAddress(stake.Owner))
 			if err != nil {
 				s.logger.Error(errors.WithStack(err))
 				continue
 			}
-			validatorIds[i] = id
-
-			for _, stake := range vlr.Stakes {
-				ownerAddressID, err := s.addressRepository.FindIdOrCreate(helpers.RemovePrefixFromAddress(stake.Owner))
-				if err != nil {
-					s.logger.Error(errors.WithStack(err))
-					continue
-				}
-				coinID, err := s.coinRepository.FindIdBySymbol(stake.Coin)
-				if err != nil {
-					s.logger.Error(errors.WithStack(err))
-					continue
-				}
-				stakes = append(stakes, &models.Stake{
-					ValidatorID:    id,
-					OwnerAddressID: ownerAddressID,
-					CoinID:         coinID,
-					Value:          stake.Value,
-					NoahValue:      stake.NoahValue,
-				})
-
-				if stake.Coin == s.env.BaseCoin {
-					continue
-				}
+			coinID, err := s.coinRepository.FindIdBySymbol(stake.Coin)
+			if err != nil {
+				s.logger.Error(errors.WithStack(err))
+				continue
+			}
+			stakes = append(stakes, &models.Stake{
+				ValidatorID:    id,
+				OwnerAddressID: ownerAddressID,
+				CoinID:         coinID,
+				Value:          stake.Value,
+				NoahValue:      stake.NoahValue,
+			})
+
+			if stake.Coin == s.env.BaseCoin {
+				continue
+			}
 
-				v, ok := stakesInCoin[coinID]
-				if ok {
-					valueInt := utils.ConvertStringToBigInt(v)
-					stakeInt := utils.ConvertStringToBigInt(stake.Value)
-					valueInt.Add(valueInt, stakeInt)
-					stakesInCoin[coinID] = valueInt.String()
-				} else {
-					stakesInCoin[coinID] = stake.Value
-				}
+			v, ok := stakesInCoin[coinID]
+			if ok {
+				valueInt := utils.ConvertStringToBigInt(v)
+				stakeInt := utils.ConvertStringToBigInt(stake.Value)
+				valueInt.Add(valueInt, stakeInt)
+				stakesInCoin[coinID] = valueInt.String()
+			} else {
+				stakesInCoin[coinID] = stake.Value
 			}
 		}
+	}
 
-		chunksCount := int(math.Ceil(float64(len(stakes)) / float64(s.env.StakeChunkSize)))
-		for i := 0; i < chunksCount; i++ {
-			start := s.env.StakeChunkSize * i
-			end := start + s.env.StakeChunkSize
-			if end > len(stakes) {
-				end = len(stakes)
-			}
-			err = s.Repository.SaveAllStakes(stakes[start:end])
+	chunksCount := int(math.Ceil(float64(len(stakes)) / float64(s.env.StakeChunkSize)))
+	for i := 0; i < chunksCount; i++ {
+		start := s.env.StakeChunkSize * i
+		end := start + s.env.StakeChunkSize
+		if end > len(stakes) {
+			end = len(stakes)
+		}
+		err = s.Repository.SaveAllStakes(stakes[start:end])
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	stakesId := make([]uint64, len(stakes))
+	for i, stake := range stakes {
+		stakesId[i] = stake.ID
+	}
+	if err = s.Repository.DeleteStakesNotInListIds(stakesId); err != nil {
+		s.logger.Error(errors.WithStack(err))
+	}
+
+	coinsId := make([]uint64, len(stakesInCoin))
+	index := 0
+	for k, v := range stakesInCoin { // compute how much was delegated in literally coin into validators
+		coinsId[index] = k
+		index++
+
+		go func(coinID uint64, stake string) {
+			fmt.Println(fmt.Sprintf("coinID = %d stake = %s", coinID, stake))
+			currentCoin, err := s.coinRepository.FindCoinByID(coinID)
 			if err != nil {
 				s.logger.Error(errors.WithStack(err))
-				panic(err)
+				return
 			}
-		}
 
-		stakesId := make([]uint64, len(stakes))
-		for i, stake := range stakes {
-			stakesId[i] = stake.ID
-		}
-		if err = s.Repository.DeleteStakesNotInListIds(stakesId); err != nil {
-			s.logger.Error(errors.WithStack(err))
-		}
+			stakeFloat, _ := utils.NewFloat(0, precision).SetString(stake)
+			volumeFloat, _ := utils.NewFloat(0, precision).SetString(currentCoin.Volume)
+			stakeFloat.Quo(stakeFloat, volumeFloat)
+			stakeFloat.Mul(stakeFloat, big.NewFloat(100))
+			delegated, _ := stakeFloat.Uint64()
+
+			if err = s.coinRepository.UpdateCoinDelegation(coinID, utils.Min(delegated, 100)); err != nil {
+				s.logger.Error(errors.WithStack(err))
+				return
+			}
+		}(k, v)
+	}
 
-		coinsId := make([]uint64, len(stakesInCoin))
-		index := 0
-		for k, v := range stakesInCoin { // compute how much was delegated in literally coin into validators
-			coinsId[index] = k
-			index++
+	if err = s.coinRepository.ResetCoinDelegationNotInListIds(coinsId); err != nil {
+		s.logger.Error(errors.WithStack(err))
+	}
 
-			go func(coinID uint64, stake string) {
-				fmt.Println(fmt.Sprintf("coinID = %d stake = %s", coinID, stake))
-				currentCoin, err := s.coinRepository.FindCoinByID(coinID)
+	if height%calcUptimeValidatorBlocks == 0 { //update uptime
+		for _, validatorID := range validatorIds {
+			// calc count validators
+			go func(validatorID uint64) {
+				countDelegators, err := s.Repository.GetCountDelegators(validatorID)
 				if err != nil {
 					s.logger.Error(errors.WithStack(err))
 					return
 				}
 
-				stakeFloat, _ := utils.NewFloat(0, precision).SetString(stake)
-				volumeFloat, _ := utils.NewFloat(0, precision).SetString(currentCoin.Volume)
-				stakeFloat.Quo(stakeFloat, volumeFloat)
-				stakeFloat.Mul(stakeFloat, big.NewFloat(100))
-				delegated, _ := stakeFloat.Uint64()
-
-				if err = s.coinRepository.UpdateCoinDelegation(coinID, utils.Min(delegated, 100)); err != nil {
+				if err = s.Repository.UpdateCountDelegators(validatorID, countDelegators); err != nil {
 					s.logger.Error(errors.WithStack(err))
 					return
 				}
-			}(k, v)
-		}
-
-		if err = s.coinRepository.ResetCoinDelegationNotInListIds(coinsId); err != nil {
-			s.logger.Error(errors.WithStack(err))
-		}
-
-		if height%calcUptimeValidatorBlocks == 0 { //update uptime
-			for _, validatorID := range validatorIds {
-				// calc count validators
-				go func(validatorID uint64) {
-					countDelegators, err := s.Repository.GetCountDelegators(validatorID)
-					if err != nil {
-						s.logger.Error(errors.WithStack(err))
-						return
-					}
-
-					if err = s.Repository.UpdateCountDelegators(validatorID, countDelegators); err != nil {
-						s.logger.Error(errors.WithStack(err))
-						return
-					}
-				}(validatorID)
-			}
+			}(validatorID)
 		}
 	}
+	return nil
 }
 
 //Get validators PK from response and store it to validators table if not exist
@@ -0,0 +1,515 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/noah-blockchain/coinExplorer-tools/helpers"
+	"github.com/noah-blockchain/coinExplorer-tools/models"
+	"github.com/noah-blockchain/noah-extender/internal/address"
+	"github.com/noah-blockchain/noah-extender/internal/balance"
+	"github.com/noah-blockchain/noah-extender/internal/coin"
+	"github.com/noah-blockchain/noah-extender/internal/cursor"
+	"github.com/noah-blockchain/noah-extender/internal/metrics"
+	"github.com/noah-blockchain/noah-extender/internal/node"
+	"github.com/noah-blockchain/noah-extender/internal/validator"
+	"github.com/sirupsen/logrus"
+)
+
+type Kind string
+
+const (
+	KindBalances   Kind = "balances"
+	KindStakes     Kind = "stakes"
+	KindCoins      Kind = "coins"
+	KindValidators Kind = "validators"
+)
+
+var Kinds = []Kind{KindBalances, KindStakes, KindCoins, KindValidators}
+
+// Value of the field of the subject (address, coin symbol or validator public key) which differs from the node
+type Mismatch struct {
+	Kind    Kind
+	Subject string
+	Field   string
+	DB      string
+	Node    string
+}
+
+type Report struct {
+	Height     uint64
+	Checked    map[Kind]int
+	Mismatches []Mismatch
+}
+
+// Subjects to verify, validators and stakes are verified all at once
+type Sample struct {
+	Addresses  []string
+	Coins      []string
+	Validators bool
+}
+
+// Sample of subjects with mismatches of the report
+func (r *Report) Sample() Sample {
+	var sample Sample
+	seen := make(map[string]bool)
+	for _, m := range r.Mismatches {
+		if seen[string(m.Kind)+m.Subject] {
+			continue
+		}
+		seen[string(m.Kind)+m.Subject] = true
+		switch m.Kind {
+		case KindBalances:
+			sample.Addresses = append(sample.Addresses, m.Subject)
+		case KindCoins:
+			sample.Coins = append(sample.Coins, m.Subject)
+		case KindValidators, KindStakes:
+			sample.Validators = true
+		}
+	}
+	return sample
+}
+
+type key struct {
+	subject string
+	field   string
+}
+
+type values map[key]string
+
+// Service compares state in DB with the node.
+// Balances are compared at the height of balances cursor, stakes and validators at heights they have been updated last.
+// Coin info is not available by height, so coins are compared with the latest state of the node.
+type Service struct {
+	env                 *models.ExtenderEnvironment
+	nodeApi             node.Client
+	addressRepository   *address.Repository
+	balanceRepository   *balance.Repository
+	coinRepository      *coin.Repository
+	validatorRepository *validator.Repository
+	cursorService       *cursor.Service
+	balanceService      *balance.Service
+	coinService         *coin.Service
+	validatorService    *validator.Service
+	logger              *logrus.Entry
+}
+
+func NewService(env *models.ExtenderEnvironment, nodeApi node.Client, addressRepository *address.Repository,
+	balanceRepository *balance.Repository, coinRepository *coin.Repository, validatorRepository *validator.Repository,
+	cursorService *cursor.Service, balanceService *balance.Service, coinService *coin.Service,
+	validatorService *validator.Service, logger *logrus.Entry) *Service {
+	return &Service{
+		env:                 env,
+		nodeApi:             nodeApi,
+		addressRepository:   addressRepository,
+		balanceRepository:   balanceRepository,
+		coinRepository:      coinRepository,
+		validatorRepository: validatorRepository,
+		cursorService:       cursorService,
+		balanceService:      balanceService,
+		coinService:         coinService,
+		validatorService:    validatorService,
+		logger:              logger,
+	}
+}
+
+// Height of the state saved in DB
+func (s *Service) StateHeight() (uint64, error) {
+	cursors, err := s.cursorService.GetSavedCursors()
+	if err != nil {
+		return 0, err
+	}
+	height, ok := cursors[cursor.StageBalances]
+	if !ok {
+		return 0, errors.New("nothing has been ingested yet")
+	}
+	return height, nil
+}
+
+// Pick random addresses and coins
+func (s *Service) RandomSample(size int) (Sample, error) {
+	addresses, err := s.addressRepository.FindRandom(size)
+	if err != nil {
+		return Sample{}, err
+	}
+	coins, err := s.coinRepository.GetAllCoins()
+	if err != nil {
+		return Sample{}, err
+	}
+	var symbols []string
+	for _, c := range coins {
+		if c.Symbol != s.env.BaseCoin {
+			symbols = append(symbols, c.Symbol)
+		}
+	}
+	rand.Shuffle(len(symbols), func(i, j int) { symbols[i], symbols[j] = symbols[j], symbols[i] })
+	if len(symbols) > size {
+		symbols = symbols[:size]
+	}
+	return Sample{Addresses: addresses, Coins: symbols, Validators: true}, nil
+}
+
+// Compare the sample with the node at the height
+func (s *Service) Verify(height uint64, sample Sample) (*Report, error) {
+	report := &Report{
+		Height:  height,
+		Checked: make(map[Kind]int),
+	}
+
+	for start := 0; start < len(sample.Addresses); start += s.env.AddrChunkSize {
+		end := start + s.env.AddrChunkSize
+		if end > len(sample.Addresses) {
+			end = len(sample.Addresses)
+		}
+		db, nodeValues, err := s.balances(sample.Addresses[start:end], height)
+		if err != nil {
+			return nil, err
+		}
+		report.add(KindBalances, db, nodeValues)
+	}
+
+	if len(sample.Coins) > 0 {
+		db, nodeValues, err := s.coins(sample.Coins)
+		if err != nil {
+			return nil, err
+		}
+		report.add(KindCoins, db, nodeValues)
+	}
+
+	if sample.Validators {
+		db, nodeValues, err := s.validators(validatorsHeight(height))
+		if err != nil {
+			return nil, err
+		}
+		report.add(KindValidators, db, nodeValues)
+
+		db, nodeValues, err = s.stakes(stakesHeight(height))
+		if err != nil {
+			return nil, err
+		}
+		report.add(KindStakes, db, nodeValues)
+	}
+	return report, nil
+}
+
+// Update mismatched subjects with the latest state of the node
+func (s *Service) Repair(report *Report) error {
+	counts := make(map[Kind]int)
+	for _, m := range report.Mismatches {
+		counts[m.Kind]++
+	}
+	sample := report.Sample()
+
+	for start := 0; start < len(sample.Addresses); start += s.env.AddrChunkSize {
+		end := start + s.env.AddrChunkSize
+		if end > len(sample.Addresses) {
+			end = len(sample.Addresses)
+		}
+		if err := s.balanceService.UpdateBalances(sample.Addresses[start:end]); err != nil {
+			return err
+		}
+	}
+	if len(sample.Coins) > 0 {
+		if err := s.coinService.UpdateCoinsInfo(sample.Coins); err != nil {
+			return err
+		}
+	}
+	if counts[KindValidators] > 0 {
+		if err := s.validatorService.UpdateValidators(0); err != nil {
+			return err
+		}
+	}
+	if counts[KindStakes] > 0 {
+		if err := s.validatorService.UpdateStakes(0); err != nil {
+			return err
+		}
+	}
+
+	for kind, count := range counts {
+		metrics.VerifyRepairs.WithLabelValues(string(kind)).Add(float64(count))
+	}
+	return nil
+}
+
+// Export results of the verification
+func (s *Service) Observe(report *Report) {
+	counts := make(map[Kind]int)
+	for _, m := range report.Mismatches {
+		counts[m.Kind]++
+	}
+	for _, kind := range Kinds {
+		metrics.VerifyChecked.WithLabelValues(string(kind)).Add(float64(report.Checked[kind]))
+		metrics.VerifyMismatches.WithLabelValues(string(kind)).Add(float64(counts[kind]))
+		metrics.VerifyLastMismatches.WithLabelValues(string(kind)).Set(float64(counts[kind]))
+	}
+	metrics.VerifyHeight.Set(float64(report.Height))
+}
+
+// Verify random sample every interval. Ingestion goes on meanwhile,
+// so mismatches are verified again after recheckDelay and only the remaining ones are reported.
+func (s *Service) SampleWorker(ctx context.Context, interval, recheckDelay time.Duration, size int, repair bool) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+
+		report, err := s.sample(ctx, recheckDelay, size)
+		if err != nil {
+			s.logger.Error(err)
+			continue
+		}
+		if report == nil {
+			return
+		}
+		s.Observe(report)
+		for _, m := range report.Mismatches {
+			s.logger.WithFields(logrus.Fields{
+				"kind":    m.Kind,
+				"subject": m.Subject,
+				"field":   m.Field,
+				"db":      m.DB,
+				"node":    m.Node,
+				"height":  report.Height,
+			}).Warn("state differs from the node")
+		}
+		if repair && len(report.Mismatches) > 0 {
+			if err = s.Repair(report); err != nil {
+				s.logger.Error(err)
+			}
+		}
+	}
+}
+
+// Returns nil report if ctx is done
+func (s *Service) sample(ctx context.Context, recheckDelay time.Duration, size int) (*Report, error) {
+	height, err := s.StateHeight()
+	if err != nil {
+		return nil, err
+	}
+	sample, err := s.RandomSample(size)
+	if err != nil {
+		return nil, err
+	}
+	report, err := s.Verify(height, sample)
+	if err != nil || len(report.Mismatches) == 0 {
+		return report, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, nil
+	case <-time.After(recheckDelay):
+	}
+	if height, err = s.StateHeight(); err != nil {
+		return nil, err
+	}
+	recheck, err := s.Verify(height, report.Sample())
+	if err != nil {
+		return nil, err
+	}
+	// values which have been checked in the first pass are counted
+	recheck.Checked = report.Checked
+	return recheck, nil
+}
+
+func (s *Service) balances(addresses []string, height uint64) (values, values, error) {
+	db := make(values)
+	balances, err := s.balanceRepository.FindAllByAddress(addresses)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, b := range balances {
+		db[key{b.Address.Address, b.Coin.Symbol}] = b.Value
+	}
+
+	nodeAddresses := make([]string, len(addresses))
+	for i, adr := range addresses {
+		nodeAddresses[i] = `"NOAHx` + adr + `"`
+	}
+	response, err := s.nodeApi.GetAddresses(nodeAddresses, height)
+	if err != nil {
+		return nil, nil, err
+	}
+	if response.Error != nil {
+		return nil, nil, fmt.Errorf("get balances at height %d: %s", height, response.Error.Message)
+	}
+	nodeValues := make(values)
+	for _, item := range response.Result {
+		for symbol, value := range item.Balance {
+			nodeValues[key{helpers.RemovePrefixFromAddress(item.Address), symbol}] = value
+		}
+	}
+	return db, nodeValues, nil
+}
+
+func (s *Service) coins(symbols []string) (values, values, error) {
+	db, nodeValues := make(values), make(values)
+	for _, symbol := range symbols {
+		id, err := s.coinRepository.FindIdBySymbol(symbol)
+		if err != nil {
+			return nil, nil, err
+		}
+		c, err := s.coinRepository.FindCoinByID(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		db[key{symbol, "volume"}] = c.Volume
+		db[key{symbol, "reserve_balance"}] = c.ReserveBalance
+		db[key{symbol, "crr"}] = strconv.FormatUint(c.Crr, 10)
+
+		response, err := s.nodeApi.GetCoinInfo(symbol)
+		if err != nil {
+			return nil, nil, err
+		}
+		if response.Error != nil {
+			// coin does not exist on the node
+			continue
+		}
+		nodeValues[key{symbol, "volume"}] = response.Result.Volume
+		nodeValues[key{symbol, "reserve_balance"}] = response.Result.ReserveBalance
+		nodeValues[key{symbol, "crr"}] = response.Result.Crr
+	}
+	return db, nodeValues, nil
+}
+
+// Only candidates of the node are compared, DB keeps validators which are not candidates anymore
+func (s *Service) validators(height uint64) (values, values, error) {
+	response, err := s.nodeApi.GetCandidates(height, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if response.Error != nil {
+		return nil, nil, fmt.Errorf("get candidates at height %d: %s", height, response.Error.Message)
+	}
+	nodeValues := make(values)
+	for _, c := range response.Result {
+		pk := helpers.RemovePrefix(c.PubKey)
+		nodeValues[key{pk, "status"}] = strconv.Itoa(int(c.Status))
+		nodeValues[key{pk, "commission"}] = c.Commission
+		nodeValues[key{pk, "total_stake"}] = c.TotalStake
+		nodeValues[key{pk, "reward_address"}] = helpers.RemovePrefixFromAddress(c.RewardAddress)
+		nodeValues[key{pk, "owner_address"}] = helpers.RemovePrefixFromAddress(c.OwnerAddress)
+	}
+
+	validators, err := s.validatorRepository.GetAllWithAddresses()
+	if err != nil {
+		return nil, nil, err
+	}
+	db := make(values)
+	for _, v := range validators {
+		if _, ok := nodeValues[key{v.PublicKey, "status"}]; !ok {
+			continue
+		}
+		if v.Status != nil {
+			db[key{v.PublicKey, "status"}] = strconv.Itoa(int(*v.Status))
+		}
+		if v.Commission != nil {
+			db[key{v.PublicKey, "commission"}] = strconv.FormatUint(*v.Commission, 10)
+		}
+		if v.TotalStake != nil {
+			db[key{v.PublicKey, "total_stake"}] = *v.TotalStake
+		}
+		if v.RewardAddress != nil {
+			db[key{v.PublicKey, "reward_address"}] = v.RewardAddress.Address
+		}
+		if v.OwnerAddress != nil {
+			db[key{v.PublicKey, "owner_address"}] = v.OwnerAddress.Address
+		}
+	}
+	return db, nodeValues, nil
+}
+
+// Stakes are keyed by validator public key, field is owner address and coin
+func (s *Service) stakes(height uint64) (values, values, error) {
+	response, err := s.nodeApi.GetCandidates(height, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	if response.Error != nil {
+		return nil, nil, fmt.Errorf("get stakes at height %d: %s", height, response.Error.Message)
+	}
+	nodeValues := make(values)
+	for _, c := range response.Result {
+		pk := helpers.RemovePrefix(c.PubKey)
+		for _, stake := range c.Stakes {
+			nodeValues[key{pk, helpers.RemovePrefixFromAddress(stake.Owner) + "/" + stake.Coin}] = stake.Value
+		}
+	}
+
+	stakes, err := s.validatorRepository.GetAllStakes()
+	if err != nil {
+		return nil, nil, err
+	}
+	db := make(values)
+	for _, stake := range stakes {
+		if stake.Validator == nil || stake.OwnerAddress == nil || stake.Coin == nil {
+			continue
+		}
+		db[key{stake.Validator.PublicKey, stake.OwnerAddress.Address + "/" + stake.Coin.Symbol}] = stake.Value
+	}
+	return db, nodeValues, nil
+}
+
+func (r *Report) add(kind Kind, db, nodeValues values) {
+	checked, mismatches := compare(kind, db, nodeValues)
+	r.Checked[kind] += checked
+	r.Mismatches = append(r.Mismatches, mismatches...)
+}
+
+// Compare values present in DB or on the node, missing value is equal to zero amount.
+// Returns count of compared values and mismatches ordered by subject and field.
+func compare(kind Kind, db, nodeValues values) (int, []Mismatch) {
+	keys := make(map[key]struct{}, len(nodeValues))
+	for k := range db {
+		keys[k] = struct{}{}
+	}
+	for k := range nodeValues {
+		keys[k] = struct{}{}
+	}
+
+	var mismatches []Mismatch
+	for k := range keys {
+		dbValue, nodeValue := db[k], nodeValues[k]
+		if dbValue == nodeValue || isZero(dbValue) && isZero(nodeValue) {
+			continue
+		}
+		mismatches = append(mismatches, Mismatch{
+			Kind:    kind,
+			Subject: k.subject,
+			Field:   k.field,
+			DB:      dbValue,
+			Node:    nodeValue,
+		})
+	}
+	sort.Slice(mismatches, func(i, j int) bool {
+		if mismatches[i].Subject != mismatches[j].Subject {
+			return mismatches[i].Subject < mismatches[j].Subject
+		}
+		return mismatches[i].Field < mismatches[j].Field
+	})
+	return len(keys), mismatches
+}
+
+func isZero(value string) bool {
+	return value == "" || value == "0"
+}
+
+// Stakes in DB are updated every UpdateStakesEveryBlocks blocks
+func stakesHeight(height uint64) uint64 {
+	return height - height%validator.UpdateStakesEveryBlocks
+}
+
+// Validators in DB are not updated on blocks which update stakes
+func validatorsHeight(height uint64) uint64 {
+	if height > 1 && height%validator.UpdateStakesEveryBlocks == 0 {
+		return height - 1
+	}
+	return height
+}
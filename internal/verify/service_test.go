@@ -0,0 +1,54 @@
+package verify
+
+import "testing"
+
+func TestCompare(t *testing.T) {
+	db := values{
+		{"aa", "NOAH"}: "10",
+		{"aa", "TEST"}: "5",
+		{"bb", "NOAH"}: "0",
+	}
+	node := values{
+		{"aa", "NOAH"}: "10",
+		{"aa", "TEST"}: "6",
+		{"cc", "NOAH"}: "1",
+	}
+	checked, mismatches := compare(KindBalances, db, node)
+	if checked != 4 {
+		t.Error("All values of DB and node must be checked, got ", checked)
+	}
+	expected := []Mismatch{
+		{Kind: KindBalances, Subject: "aa", Field: "TEST", DB: "5", Node: "6"},
+		{Kind: KindBalances, Subject: "cc", Field: "NOAH", DB: "", Node: "1"},
+	}
+	if len(mismatches) != len(expected) {
+		t.Fatal("Missing zero value must be equal to zero, got ", mismatches)
+	}
+	for i := range expected {
+		if mismatches[i] != expected[i] {
+			t.Errorf("Mismatch %d is %+v, expected %+v", i, mismatches[i], expected[i])
+		}
+	}
+}
+
+func TestReportSample(t *testing.T) {
+	report := &Report{Mismatches: []Mismatch{
+		{Kind: KindBalances, Subject: "aa", Field: "NOAH"},
+		{Kind: KindBalances, Subject: "aa", Field: "TEST"},
+		{Kind: KindCoins, Subject: "TEST", Field: "volume"},
+		{Kind: KindStakes, Subject: "01", Field: "bb/NOAH"},
+	}}
+	sample := report.Sample()
+	if len(sample.Addresses) != 1 || len(sample.Coins) != 1 || !sample.Validators {
+		t.Errorf("Sample must contain mismatched subjects once, got %+v", sample)
+	}
+}
+
+func TestHeights(t *testing.T) {
+	if stakesHeight(25) != 24 || stakesHeight(24) != 24 {
+		t.Error("Stakes must be compared at the last height they have been updated")
+	}
+	if validatorsHeight(24) != 23 || validatorsHeight(25) != 25 {
+		t.Error("Validators must be compared at the last height they have been updated")
+	}
+}
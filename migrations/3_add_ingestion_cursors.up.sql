@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS public.ingestion_cursors
+(
+    stage      character varying(32)                  NOT NULL,
+    height     bigint                                 NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT ingestion_cursors_pkey PRIMARY KEY (stage)
+);
+
+COMMENT ON TABLE public.ingestion_cursors IS 'Last height up to which a pipeline stage has been completed without gaps';
+
+CREATE TABLE IF NOT EXISTS public.ingestion_progress
+(
+    block_id     bigint                                 NOT NULL,
+    stage        character varying(32)                  NOT NULL,
+    completed_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT ingestion_progress_pk PRIMARY KEY (block_id, stage)
+);
+
+COMMENT ON TABLE public.ingestion_progress IS 'Pipeline stages completed out of order above the stage cursor';
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS public.ingestion_failures
+(
+    id         bigserial                              NOT NULL,
+    block_id   bigint                                 NOT NULL,
+    stage      character varying(32)                  NOT NULL,
+    policy     character varying(16)                  NOT NULL,
+    error      text                                   NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT ingestion_failures_pkey PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS ingestion_failures_block_id_index
+    ON public.ingestion_failures USING btree
+    (block_id);
+
+COMMENT ON TABLE public.ingestion_failures IS 'Jobs which have been skipped or quarantined after a permanent failure';
@@ -0,0 +1,251 @@
+-- Range partitioning of the largest tables.
+-- transactions, block_validator and index_transaction_by_address are partitioned by block_id.
+-- transaction_outputs has no block_id, it is partitioned by transaction_id which grows with block_id.
+-- Partitions are named <table>_<range start>, rows outside of existing partitions go to <table>_default
+-- and are moved to their partition once it is created.
+-- Partitioned tables can not be referenced by foreign keys, so links to transactions are not enforced anymore.
+
+CREATE OR REPLACE FUNCTION public.create_range_partition(parent text, key text, range_from bigint, range_to bigint)
+    RETURNS void
+    LANGUAGE plpgsql
+AS
+$$
+DECLARE
+    partition text := format('%s_%s', parent, range_from);
+BEGIN
+    IF to_regclass('public.' || partition) IS NOT NULL THEN
+        RETURN;
+    END IF;
+    EXECUTE format('CREATE TABLE public.%I (LIKE public.%I INCLUDING DEFAULTS)', partition, parent);
+    EXECUTE format('WITH moved AS (DELETE FROM public.%I WHERE %I >= %s AND %I < %s RETURNING *) INSERT INTO public.%I SELECT * FROM moved',
+                   parent || '_default', key, range_from, key, range_to, partition);
+    EXECUTE format('ALTER TABLE public.%I ATTACH PARTITION public.%I FOR VALUES FROM (%s) TO (%s)',
+                   parent, partition, range_from, range_to);
+END
+$$;
+
+COMMENT ON FUNCTION public.create_range_partition(text, text, bigint, bigint) IS 'Create partition of the parent table for key values range_from..range_to-1 if it does not exist';
+
+CREATE OR REPLACE FUNCTION public.create_range_partitions(parent text, key text, width bigint, up_to bigint)
+    RETURNS void
+    LANGUAGE plpgsql
+AS
+$$
+DECLARE
+    range_from bigint := 0;
+BEGIN
+    WHILE range_from <= up_to
+        LOOP
+            PERFORM public.create_range_partition(parent, key, range_from, range_from + width);
+            range_from := range_from + width;
+        END LOOP;
+END
+$$;
+
+COMMENT ON FUNCTION public.create_range_partitions(text, text, bigint, bigint) IS 'Create partitions of the parent table of the given width which cover key values 0..up_to';
+
+ALTER TABLE public.coins
+    DROP CONSTRAINT IF EXISTS coins_transactions_id_fk;
+ALTER TABLE public.transaction_outputs
+    DROP CONSTRAINT IF EXISTS transaction_outputs_transactions_id_fk;
+ALTER TABLE public.transaction_validator
+    DROP CONSTRAINT IF EXISTS transaction_validator_transactions_id_fk;
+ALTER TABLE public.index_transaction_by_address
+    DROP CONSTRAINT IF EXISTS index_transaction_by_address_transactions_id_fk;
+
+--
+-- transactions
+--
+
+ALTER TABLE public.transactions
+    RENAME TO transactions_unpartitioned;
+
+CREATE TABLE public.transactions
+(
+    id              bigint                   NOT NULL,
+    from_address_id bigint                   NOT NULL,
+    nonce           bigint                   NOT NULL,
+    gas_price       bigint                   NOT NULL,
+    gas             bigint                   NOT NULL,
+    block_id        integer                  NOT NULL,
+    gas_coin_id     integer                  NOT NULL,
+    created_at      timestamp with time zone NOT NULL,
+    type            smallint                 NOT NULL,
+    hash            character varying(64)    NOT NULL,
+    service_data    text,
+    data            jsonb                    NOT NULL,
+    tags            jsonb                    NOT NULL,
+    payload         bytea,
+    raw_tx          bytea                    NOT NULL
+) PARTITION BY RANGE (block_id);
+
+CREATE TABLE public.transactions_default PARTITION OF public.transactions DEFAULT;
+
+SELECT public.create_range_partitions('transactions', 'block_id', 1000000,
+                                      (SELECT coalesce(max(block_id), 0) FROM public.transactions_unpartitioned));
+
+INSERT INTO public.transactions (id, from_address_id, nonce, gas_price, gas, block_id, gas_coin_id, created_at, type, hash,
+                                 service_data, data, tags, payload, raw_tx)
+SELECT id,
+       from_address_id,
+       nonce,
+       gas_price,
+       gas,
+       block_id,
+       gas_coin_id,
+       created_at,
+       type,
+       hash,
+       service_data,
+       data,
+       tags,
+       payload,
+       raw_tx
+FROM public.transactions_unpartitioned;
+
+ALTER SEQUENCE public.transactions_id_seq OWNED BY NONE;
+DROP TABLE public.transactions_unpartitioned;
+
+ALTER TABLE public.transactions
+    ALTER COLUMN id SET DEFAULT nextval('public.transactions_id_seq'::regclass);
+ALTER SEQUENCE public.transactions_id_seq OWNED BY public.transactions.id;
+
+ALTER TABLE public.transactions
+    ADD CONSTRAINT transactions_pkey PRIMARY KEY (id, block_id);
+CREATE INDEX transactions_id_index ON public.transactions USING btree (id);
+CREATE INDEX transactions_block_id_from_address_id_index ON public.transactions USING btree (block_id DESC, from_address_id);
+CREATE INDEX transactions_from_address_id_index ON public.transactions USING btree (from_address_id);
+CREATE INDEX transactions_hash_index ON public.transactions USING hash (hash);
+
+ALTER TABLE public.transactions
+    ADD CONSTRAINT transactions_addresses_id_fk FOREIGN KEY (from_address_id) REFERENCES public.addresses (id);
+ALTER TABLE public.transactions
+    ADD CONSTRAINT transactions_blocks_id_fk FOREIGN KEY (block_id) REFERENCES public.blocks (id);
+ALTER TABLE public.transactions
+    ADD CONSTRAINT transactions_coins_id_fk FOREIGN KEY (gas_coin_id) REFERENCES public.coins (id);
+
+COMMENT ON COLUMN public.transactions.from_address_id IS 'Link to address, from that tx was signed';
+COMMENT ON COLUMN public.transactions.block_id IS 'Link to block';
+COMMENT ON COLUMN public.transactions.created_at IS 'Timestamp of tx = timestamp of block. Duplicate data for less joins on blocks';
+COMMENT ON COLUMN public.transactions.type IS 'Integer index of tx type';
+COMMENT ON COLUMN public.transactions.hash IS 'Tx hash 64 symbols hex string without prefix(Nt****). Because of key-value-only filtering uses hash index';
+COMMENT ON COLUMN public.transactions.payload IS 'transaction payload in bytes';
+COMMENT ON COLUMN public.transactions.raw_tx IS 'Raw tx data in bytes';
+
+--
+-- transaction_outputs
+--
+
+ALTER TABLE public.transaction_outputs
+    RENAME TO transaction_outputs_unpartitioned;
+
+CREATE TABLE public.transaction_outputs
+(
+    id             bigint                                 NOT NULL,
+    transaction_id bigint                                 NOT NULL,
+    to_address_id  bigint                                 NOT NULL,
+    coin_id        integer                                NOT NULL,
+    value          numeric(70, 0)                         NOT NULL,
+    created_at     timestamp with time zone DEFAULT now() NOT NULL
+) PARTITION BY RANGE (transaction_id);
+
+CREATE TABLE public.transaction_outputs_default PARTITION OF public.transaction_outputs DEFAULT;
+
+SELECT public.create_range_partitions('transaction_outputs', 'transaction_id', 10000000,
+                                      (SELECT coalesce(max(transaction_id), 0) FROM public.transaction_outputs_unpartitioned));
+
+INSERT INTO public.transaction_outputs (id, transaction_id, to_address_id, coin_id, value, created_at)
+SELECT id, transaction_id, to_address_id, coin_id, value, created_at
+FROM public.transaction_outputs_unpartitioned;
+
+ALTER SEQUENCE public.transaction_outputs_id_seq OWNED BY NONE;
+DROP TABLE public.transaction_outputs_unpartitioned;
+
+ALTER TABLE public.transaction_outputs
+    ALTER COLUMN id SET DEFAULT nextval('public.transaction_outputs_id_seq'::regclass);
+ALTER SEQUENCE public.transaction_outputs_id_seq OWNED BY public.transaction_outputs.id;
+
+ALTER TABLE public.transaction_outputs
+    ADD CONSTRAINT transaction_outputs_pkey PRIMARY KEY (id, transaction_id);
+CREATE INDEX transaction_outputs_coin_id_index ON public.transaction_outputs USING btree (coin_id);
+CREATE INDEX transaction_outputs_transaction_id_index ON public.transaction_outputs USING btree (transaction_id);
+CREATE INDEX transaction_outputs_address_id_index ON public.transaction_outputs USING btree (to_address_id);
+
+ALTER TABLE public.transaction_outputs
+    ADD CONSTRAINT transaction_outputs_addresses_id_fk FOREIGN KEY (to_address_id) REFERENCES public.addresses (id);
+ALTER TABLE public.transaction_outputs
+    ADD CONSTRAINT transaction_outputs_coins_id_fk FOREIGN KEY (coin_id) REFERENCES public.coins (id);
+
+COMMENT ON COLUMN public.transaction_outputs.value IS 'Value of tx output';
+
+--
+-- block_validator
+--
+
+ALTER TABLE public.block_validator
+    RENAME TO block_validator_unpartitioned;
+
+CREATE TABLE public.block_validator
+(
+    block_id     bigint                                 NOT NULL,
+    validator_id integer                                NOT NULL,
+    signed       boolean                  DEFAULT false NOT NULL,
+    created_at   timestamp with time zone DEFAULT now() NOT NULL
+) PARTITION BY RANGE (block_id);
+
+CREATE TABLE public.block_validator_default PARTITION OF public.block_validator DEFAULT;
+
+SELECT public.create_range_partitions('block_validator', 'block_id', 1000000,
+                                      (SELECT coalesce(max(block_id), 0) FROM public.block_validator_unpartitioned));
+
+INSERT INTO public.block_validator (block_id, validator_id, signed, created_at)
+SELECT block_id, validator_id, signed, created_at
+FROM public.block_validator_unpartitioned;
+
+DROP TABLE public.block_validator_unpartitioned;
+
+ALTER TABLE public.block_validator
+    ADD CONSTRAINT block_validator_pk PRIMARY KEY (block_id, validator_id);
+CREATE INDEX block_validator_block_id_index ON public.block_validator USING btree (block_id);
+CREATE INDEX block_validator_validator_id_index ON public.block_validator USING btree (validator_id);
+
+ALTER TABLE public.block_validator
+    ADD CONSTRAINT block_validator_blocks_id_fk FOREIGN KEY (block_id) REFERENCES public.blocks (id);
+ALTER TABLE public.block_validator
+    ADD CONSTRAINT block_validator_validators_id_fk FOREIGN KEY (validator_id) REFERENCES public.validators (id);
+
+--
+-- index_transaction_by_address
+--
+
+ALTER TABLE public.index_transaction_by_address
+    RENAME TO index_transaction_by_address_unpartitioned;
+
+CREATE TABLE public.index_transaction_by_address
+(
+    block_id       bigint NOT NULL,
+    address_id     bigint NOT NULL,
+    transaction_id bigint NOT NULL
+) PARTITION BY RANGE (block_id);
+
+CREATE TABLE public.index_transaction_by_address_default PARTITION OF public.index_transaction_by_address DEFAULT;
+
+SELECT public.create_range_partitions('index_transaction_by_address', 'block_id', 1000000,
+                                      (SELECT coalesce(max(block_id), 0) FROM public.index_transaction_by_address_unpartitioned));
+
+INSERT INTO public.index_transaction_by_address (block_id, address_id, transaction_id)
+SELECT block_id, address_id, transaction_id
+FROM public.index_transaction_by_address_unpartitioned;
+
+DROP TABLE public.index_transaction_by_address_unpartitioned;
+
+ALTER TABLE public.index_transaction_by_address
+    ADD CONSTRAINT index_transaction_by_address_pk PRIMARY KEY (block_id, address_id, transaction_id);
+CREATE INDEX index_transaction_by_address_address_id_index ON public.index_transaction_by_address USING btree (address_id);
+CREATE INDEX index_transaction_by_address_block_id_address_id_index ON public.index_transaction_by_address USING btree (block_id, address_id);
+CREATE INDEX index_transaction_by_address_transaction_id_index ON public.index_transaction_by_address USING btree (transaction_id);
+
+ALTER TABLE public.index_transaction_by_address
+    ADD CONSTRAINT index_transaction_by_address_addresses_id_fk FOREIGN KEY (address_id) REFERENCES public.addresses (id);
+ALTER TABLE public.index_transaction_by_address
+    ADD CONSTRAINT index_transaction_by_address_blocks_id_fk FOREIGN KEY (block_id) REFERENCES public.blocks (id);
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS public.balance_history
+(
+    address_id bigint                                 NOT NULL,
+    coin_id    integer                                NOT NULL,
+    height     bigint                                 NOT NULL,
+    old_value  numeric(70, 0)                         NOT NULL,
+    new_value  numeric(70, 0)                         NOT NULL,
+    delta      numeric(70, 0)                         NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    CONSTRAINT balance_history_pk PRIMARY KEY (address_id, coin_id, height)
+);
+
+ALTER TABLE public.balance_history
+    ADD CONSTRAINT balance_history_addresses_id_fk FOREIGN KEY (address_id) REFERENCES public.addresses (id);
+
+COMMENT ON TABLE public.balance_history IS 'Changes of balances by address, coin and height. Zero new value means the balance has been removed';
+COMMENT ON COLUMN public.balance_history.coin_id IS 'Link to coin, not enforced so history of liquidated coins is kept after their coins are deleted';
+COMMENT ON COLUMN public.balance_history.height IS 'Height at which the balance has been requested from the node';
+COMMENT ON COLUMN public.balance_history.delta IS 'new_value - old_value';